@@ -0,0 +1,515 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the typed configuration of the whole service. It is loaded once
+// at startup and passed into the database, storage, KMS and token code.
+type Config struct {
+	Port     string         `yaml:"port" toml:"port"`
+	Mongo    MongoConfig    `yaml:"mongo" toml:"mongo"`
+	Storage  StorageConfig  `yaml:"storage" toml:"storage"`
+	KMS      KMSConfig      `yaml:"kms" toml:"kms"`
+	Auth     AuthConfig     `yaml:"auth" toml:"auth"`
+	Mail     MailConfig     `yaml:"mail" toml:"mail"`
+	Account  AccountConfig  `yaml:"account" toml:"account"`
+	Schedule ScheduleConfig `yaml:"schedule" toml:"schedule"`
+
+	// TrustedProxies are the addresses or CIDR ranges of the load balancers
+	// in front of the service. Only requests coming from them may set the
+	// client IP through X-Forwarded-For or X-Real-IP. With none, the client
+	// IP is always the address the request came from.
+	TrustedProxies []string `yaml:"trusted_proxies" toml:"trusted_proxies"`
+}
+
+type MongoConfig struct {
+	URI      string `yaml:"uri" toml:"uri"`
+	Database string `yaml:"database" toml:"database"`
+}
+
+// StorageConfig describes the S3-compatible bucket (DigitalOcean Spaces in
+// production) that holds profile images and recordings.
+type StorageConfig struct {
+	Endpoint   string `yaml:"endpoint" toml:"endpoint"`
+	Region     string `yaml:"region" toml:"region"`
+	Bucket     string `yaml:"bucket" toml:"bucket"`
+	CDNBaseURL string `yaml:"cdn_base_url" toml:"cdn_base_url"`
+	AccessKey  string `yaml:"access_key" toml:"access_key"`
+	SecretKey  string `yaml:"secret_key" toml:"secret_key"`
+	// UploadURLTTL is how long a presigned recording upload URL stays valid.
+	// Uploads not confirmed by then are reset every UploadSweepInterval.
+	UploadURLTTL        Duration `yaml:"upload_url_ttl" toml:"upload_url_ttl"`
+	UploadSweepInterval Duration `yaml:"upload_sweep_interval" toml:"upload_sweep_interval"`
+}
+
+// KMSConfig selects how recording keys are wrapped: with AWS KMS ("aws", the
+// default) or locally with a master key ("local") for on-prem deployments and
+// offline tests.
+type KMSConfig struct {
+	Provider  string `yaml:"provider" toml:"provider"`
+	Region    string `yaml:"region" toml:"region"`
+	KeyID     string `yaml:"key_id" toml:"key_id"`
+	AccessKey string `yaml:"access_key" toml:"access_key"`
+	SecretKey string `yaml:"secret_key" toml:"secret_key"`
+	// MasterKey is the base64 32-byte local master key. MasterKeyFile is read
+	// instead when MasterKey is empty.
+	MasterKey     string `yaml:"master_key" toml:"master_key"`
+	MasterKeyFile string `yaml:"master_key_file" toml:"master_key_file"`
+	MasterKeyID   string `yaml:"master_key_id" toml:"master_key_id"`
+}
+
+// AuthConfig covers the JWTs. Access and refresh tokens are signed with
+// separate keys: HMAC secrets for HS256 (the default), or PEM private keys for
+// RS256 and EdDSA.
+//
+// To rotate the access token key without logging everyone out, the old key
+// stays accepted for a while: old HMAC secrets go in PreviousSecretKeys, old
+// PEM keys (public halves suffice) in AccessVerifyKeyDir.
+type AuthConfig struct {
+	Algorithm             string   `yaml:"algorithm" toml:"algorithm"`
+	SecretKey             string   `yaml:"secret_key" toml:"secret_key"`
+	PreviousSecretKeys    []string `yaml:"previous_secret_keys" toml:"previous_secret_keys"`
+	RefreshSecretKey      string   `yaml:"refresh_secret_key" toml:"refresh_secret_key"`
+	AccessPrivateKeyFile  string   `yaml:"access_private_key_file" toml:"access_private_key_file"`
+	AccessVerifyKeyDir    string   `yaml:"access_verify_key_dir" toml:"access_verify_key_dir"`
+	RefreshPrivateKeyFile string   `yaml:"refresh_private_key_file" toml:"refresh_private_key_file"`
+	PublishJWKS           bool     `yaml:"publish_jwks" toml:"publish_jwks"`
+	Issuer                string   `yaml:"issuer" toml:"issuer"`
+	Audience              string   `yaml:"audience" toml:"audience"`
+	AccessTokenTTL        Duration `yaml:"access_token_ttl" toml:"access_token_ttl"`
+	RefreshTokenTTL       Duration `yaml:"refresh_token_ttl" toml:"refresh_token_ttl"`
+	// MFAIssuer names the service in authenticator apps. MFAChallengeTTL is
+	// how long the second login step may take. With RequireTherapistMFA,
+	// therapists can't use the API before enrolling in MFA.
+	MFAIssuer           string   `yaml:"mfa_issuer" toml:"mfa_issuer"`
+	MFAChallengeTTL     Duration `yaml:"mfa_challenge_ttl" toml:"mfa_challenge_ttl"`
+	RequireTherapistMFA bool     `yaml:"require_therapist_mfa" toml:"require_therapist_mfa"`
+}
+
+// MailConfig selects how emails are delivered: written to the log ("log",
+// the default), to files in Dir ("file") or sent through SMTP ("smtp").
+type MailConfig struct {
+	Provider     string `yaml:"provider" toml:"provider"`
+	From         string `yaml:"from" toml:"from"`
+	Dir          string `yaml:"dir" toml:"dir"`
+	SMTPHost     string `yaml:"smtp_host" toml:"smtp_host"`
+	SMTPPort     int    `yaml:"smtp_port" toml:"smtp_port"`
+	SMTPUsername string `yaml:"smtp_username" toml:"smtp_username"`
+	SMTPPassword string `yaml:"smtp_password" toml:"smtp_password"`
+}
+
+// AccountConfig covers the account emails, login lockout and patient
+// invites. AppURL is where the links in those emails point.
+//
+// After LoginMaxFailures failed logins for one account, or LoginIPMaxFailures
+// from one IP address, further logins are refused for LoginLockout, doubling
+// with every further failure up to LoginMaxLockout.
+//
+// Patient invites expire after InviteTTL unless the therapist picks another
+// expiry, which can be at most InviteMaxTTL away.
+//
+// Deleted accounts can be restored for DeletionGrace. The accounts past it
+// are purged every PurgeInterval.
+type AccountConfig struct {
+	AppURL               string   `yaml:"app_url" toml:"app_url"`
+	PasswordResetTTL     Duration `yaml:"password_reset_ttl" toml:"password_reset_ttl"`
+	EmailVerificationTTL Duration `yaml:"email_verification_ttl" toml:"email_verification_ttl"`
+	LoginMaxFailures     int      `yaml:"login_max_failures" toml:"login_max_failures"`
+	LoginIPMaxFailures   int      `yaml:"login_ip_max_failures" toml:"login_ip_max_failures"`
+	LoginLockout         Duration `yaml:"login_lockout" toml:"login_lockout"`
+	LoginMaxLockout      Duration `yaml:"login_max_lockout" toml:"login_max_lockout"`
+	InviteTTL            Duration `yaml:"invite_ttl" toml:"invite_ttl"`
+	InviteMaxTTL         Duration `yaml:"invite_max_ttl" toml:"invite_max_ttl"`
+	DeletionGrace        Duration `yaml:"deletion_grace" toml:"deletion_grace"`
+	PurgeInterval        Duration `yaml:"purge_interval" toml:"purge_interval"`
+}
+
+// ScheduleConfig bounds recurring assignment schedules, since creating one
+// generates its past and upcoming occurrences in the same request. Schedules
+// may start at most MaxBackdate in the past and run for at most MaxLength.
+type ScheduleConfig struct {
+	MaxBackdate Duration `yaml:"max_backdate" toml:"max_backdate"`
+	MaxLength   Duration `yaml:"max_length" toml:"max_length"`
+}
+
+// Duration is a time.Duration that is written as "24h", "15m" etc. in
+// config files and environment variables.
+type Duration struct {
+	time.Duration
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	d.Duration = parsed
+	return nil
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// Default returns the configuration used for anything not set in the config
+// file or the environment.
+func Default() Config {
+	return Config{
+		Port: "8080",
+		Mongo: MongoConfig{
+			Database: "golang-speakdb",
+		},
+		Storage: StorageConfig{
+			Endpoint:            "https://nyc3.digitaloceanspaces.com",
+			Region:              "us-east-1",
+			Bucket:              "peakspeak",
+			CDNBaseURL:          "https://peakspeak.nyc3.cdn.digitaloceanspaces.com",
+			UploadURLTTL:        Duration{15 * time.Minute},
+			UploadSweepInterval: Duration{5 * time.Minute},
+		},
+		KMS: KMSConfig{
+			Provider: "aws",
+			Region:   "us-east-2",
+		},
+		Auth: AuthConfig{
+			Algorithm:       "HS256",
+			Issuer:          "peakspeak",
+			Audience:        "peakspeak-api",
+			AccessTokenTTL:  Duration{24 * time.Hour},
+			RefreshTokenTTL: Duration{7 * 24 * time.Hour},
+			MFAIssuer:       "PeakSpeak",
+			MFAChallengeTTL: Duration{5 * time.Minute},
+		},
+		Mail: MailConfig{
+			Provider: "log",
+			From:     "PeakSpeak <no-reply@peakspeak.app>",
+			Dir:      "mail",
+			SMTPPort: 587,
+		},
+		Account: AccountConfig{
+			AppURL:               "http://localhost:3000",
+			PasswordResetTTL:     Duration{time.Hour},
+			EmailVerificationTTL: Duration{48 * time.Hour},
+			LoginMaxFailures:     5,
+			LoginIPMaxFailures:   50,
+			LoginLockout:         Duration{time.Minute},
+			LoginMaxLockout:      Duration{time.Hour},
+			InviteTTL:            Duration{7 * 24 * time.Hour},
+			InviteMaxTTL:         Duration{90 * 24 * time.Hour},
+			DeletionGrace:        Duration{30 * 24 * time.Hour},
+			PurgeInterval:        Duration{time.Hour},
+		},
+		Schedule: ScheduleConfig{
+			MaxBackdate: Duration{7 * 24 * time.Hour},
+			MaxLength:   Duration{366 * 24 * time.Hour},
+		},
+	}
+}
+
+// Load builds the configuration from the defaults, then the file named by
+// CONFIG_FILE (YAML or TOML, picked by extension) if set, then environment
+// variables, and validates the result.
+func Load() (Config, error) {
+	cfg := Default()
+
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		if err := loadFile(path, &cfg); err != nil {
+			return cfg, err
+		}
+	}
+
+	if err := loadEnv(&cfg); err != nil {
+		return cfg, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+func loadFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config: reading %s: %w", path, err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, cfg)
+	case ".toml":
+		err = toml.Unmarshal(data, cfg)
+	default:
+		return fmt.Errorf("config: unsupported file type %q, expected .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return fmt.Errorf("config: parsing %s: %w", path, err)
+	}
+	return nil
+}
+
+func loadEnv(cfg *Config) error {
+	setString := func(key string, target *string) {
+		if value, ok := os.LookupEnv(key); ok && value != "" {
+			*target = value
+		}
+	}
+	setDuration := func(key string, target *Duration) error {
+		if value, ok := os.LookupEnv(key); ok && value != "" {
+			if err := target.UnmarshalText([]byte(value)); err != nil {
+				return fmt.Errorf("config: %s: %w", key, err)
+			}
+		}
+		return nil
+	}
+
+	setInt := func(key string, target *int) error {
+		if value, ok := os.LookupEnv(key); ok && value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("config: %s: %w", key, err)
+			}
+			*target = parsed
+		}
+		return nil
+	}
+
+	setString("PORT", &cfg.Port)
+	if value, ok := os.LookupEnv("TRUSTED_PROXIES"); ok && value != "" {
+		cfg.TrustedProxies = strings.Split(value, ",")
+	}
+
+	setString("MONGO_URI", &cfg.Mongo.URI)
+	setString("MONGO_DATABASE", &cfg.Mongo.Database)
+
+	setString("SPACES_ENDPOINT", &cfg.Storage.Endpoint)
+	setString("SPACES_REGION", &cfg.Storage.Region)
+	setString("SPACES_BUCKET", &cfg.Storage.Bucket)
+	setString("SPACES_CDN_URL", &cfg.Storage.CDNBaseURL)
+	setString("SPACES_KEY", &cfg.Storage.AccessKey)
+	setString("SPACES_SECRET", &cfg.Storage.SecretKey)
+	if err := setDuration("UPLOAD_URL_TTL", &cfg.Storage.UploadURLTTL); err != nil {
+		return err
+	}
+	if err := setDuration("UPLOAD_SWEEP_INTERVAL", &cfg.Storage.UploadSweepInterval); err != nil {
+		return err
+	}
+
+	setString("KEY_PROVIDER", &cfg.KMS.Provider)
+	setString("KMS_REGION", &cfg.KMS.Region)
+	setString("KMS_KEY_ID", &cfg.KMS.KeyID)
+	setString("AWS_ACCESS", &cfg.KMS.AccessKey)
+	setString("AWS_SECRET", &cfg.KMS.SecretKey)
+	setString("LOCAL_MASTER_KEY", &cfg.KMS.MasterKey)
+	setString("LOCAL_MASTER_KEY_FILE", &cfg.KMS.MasterKeyFile)
+	setString("LOCAL_MASTER_KEY_ID", &cfg.KMS.MasterKeyID)
+
+	setString("JWT_ALGORITHM", &cfg.Auth.Algorithm)
+	setString("SECRET_KEY", &cfg.Auth.SecretKey)
+	if value, ok := os.LookupEnv("JWT_PREVIOUS_SECRET_KEYS"); ok && value != "" {
+		cfg.Auth.PreviousSecretKeys = strings.Split(value, ",")
+	}
+	setString("REFRESH_SECRET_KEY", &cfg.Auth.RefreshSecretKey)
+	setString("JWT_ACCESS_PRIVATE_KEY_FILE", &cfg.Auth.AccessPrivateKeyFile)
+	setString("JWT_ACCESS_VERIFY_KEY_DIR", &cfg.Auth.AccessVerifyKeyDir)
+	setString("JWT_REFRESH_PRIVATE_KEY_FILE", &cfg.Auth.RefreshPrivateKeyFile)
+	if value, ok := os.LookupEnv("JWT_PUBLISH_JWKS"); ok && value != "" {
+		publish, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("config: JWT_PUBLISH_JWKS: %w", err)
+		}
+		cfg.Auth.PublishJWKS = publish
+	}
+	setString("JWT_ISSUER", &cfg.Auth.Issuer)
+	setString("JWT_AUDIENCE", &cfg.Auth.Audience)
+	if err := setDuration("ACCESS_TOKEN_TTL", &cfg.Auth.AccessTokenTTL); err != nil {
+		return err
+	}
+	if err := setDuration("REFRESH_TOKEN_TTL", &cfg.Auth.RefreshTokenTTL); err != nil {
+		return err
+	}
+	setString("MFA_ISSUER", &cfg.Auth.MFAIssuer)
+	if err := setDuration("MFA_CHALLENGE_TTL", &cfg.Auth.MFAChallengeTTL); err != nil {
+		return err
+	}
+	if value, ok := os.LookupEnv("MFA_REQUIRED_FOR_THERAPISTS"); ok && value != "" {
+		require, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("config: MFA_REQUIRED_FOR_THERAPISTS: %w", err)
+		}
+		cfg.Auth.RequireTherapistMFA = require
+	}
+
+	setString("MAIL_PROVIDER", &cfg.Mail.Provider)
+	setString("MAIL_FROM", &cfg.Mail.From)
+	setString("MAIL_DIR", &cfg.Mail.Dir)
+	setString("SMTP_HOST", &cfg.Mail.SMTPHost)
+	if err := setInt("SMTP_PORT", &cfg.Mail.SMTPPort); err != nil {
+		return err
+	}
+	setString("SMTP_USERNAME", &cfg.Mail.SMTPUsername)
+	setString("SMTP_PASSWORD", &cfg.Mail.SMTPPassword)
+
+	setString("APP_URL", &cfg.Account.AppURL)
+	if err := setDuration("PASSWORD_RESET_TTL", &cfg.Account.PasswordResetTTL); err != nil {
+		return err
+	}
+	if err := setDuration("EMAIL_VERIFICATION_TTL", &cfg.Account.EmailVerificationTTL); err != nil {
+		return err
+	}
+	if err := setInt("LOGIN_MAX_FAILURES", &cfg.Account.LoginMaxFailures); err != nil {
+		return err
+	}
+	if err := setInt("LOGIN_IP_MAX_FAILURES", &cfg.Account.LoginIPMaxFailures); err != nil {
+		return err
+	}
+	if err := setDuration("LOGIN_LOCKOUT", &cfg.Account.LoginLockout); err != nil {
+		return err
+	}
+	if err := setDuration("LOGIN_MAX_LOCKOUT", &cfg.Account.LoginMaxLockout); err != nil {
+		return err
+	}
+	if err := setDuration("INVITE_TTL", &cfg.Account.InviteTTL); err != nil {
+		return err
+	}
+	if err := setDuration("INVITE_MAX_TTL", &cfg.Account.InviteMaxTTL); err != nil {
+		return err
+	}
+	if err := setDuration("ACCOUNT_DELETION_GRACE", &cfg.Account.DeletionGrace); err != nil {
+		return err
+	}
+	if err := setDuration("ACCOUNT_PURGE_INTERVAL", &cfg.Account.PurgeInterval); err != nil {
+		return err
+	}
+
+	if err := setDuration("SCHEDULE_MAX_BACKDATE", &cfg.Schedule.MaxBackdate); err != nil {
+		return err
+	}
+	return setDuration("SCHEDULE_MAX_LENGTH", &cfg.Schedule.MaxLength)
+}
+
+// Validate reports every missing or inconsistent setting at once.
+func (cfg Config) Validate() error {
+	var errs []error
+	required := func(name string, value string) {
+		if strings.TrimSpace(value) == "" {
+			errs = append(errs, fmt.Errorf("%s is required", name))
+		}
+	}
+
+	required("port (PORT)", cfg.Port)
+	for _, proxy := range cfg.TrustedProxies {
+		if net.ParseIP(proxy) == nil {
+			if _, _, err := net.ParseCIDR(proxy); err != nil {
+				errs = append(errs, fmt.Errorf("trusted_proxies (TRUSTED_PROXIES) must be IP addresses or CIDR ranges, got %q", proxy))
+			}
+		}
+	}
+	required("mongo.uri (MONGO_URI)", cfg.Mongo.URI)
+	required("mongo.database (MONGO_DATABASE)", cfg.Mongo.Database)
+	required("storage.endpoint (SPACES_ENDPOINT)", cfg.Storage.Endpoint)
+	required("storage.region (SPACES_REGION)", cfg.Storage.Region)
+	required("storage.bucket (SPACES_BUCKET)", cfg.Storage.Bucket)
+	required("storage.cdn_base_url (SPACES_CDN_URL)", cfg.Storage.CDNBaseURL)
+	switch cfg.KMS.Provider {
+	case "aws":
+		required("kms.region (KMS_REGION)", cfg.KMS.Region)
+		required("kms.key_id (KMS_KEY_ID)", cfg.KMS.KeyID)
+	case "local":
+		if cfg.KMS.MasterKey == "" && cfg.KMS.MasterKeyFile == "" {
+			errs = append(errs, errors.New("kms.master_key (LOCAL_MASTER_KEY) or kms.master_key_file (LOCAL_MASTER_KEY_FILE) is required for the local key provider"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("kms.provider (KEY_PROVIDER) must be aws or local, got %q", cfg.KMS.Provider))
+	}
+	switch cfg.Auth.Algorithm {
+	case "HS256":
+		required("auth.secret_key (SECRET_KEY)", cfg.Auth.SecretKey)
+		required("auth.refresh_secret_key (REFRESH_SECRET_KEY)", cfg.Auth.RefreshSecretKey)
+		if cfg.Auth.SecretKey != "" && cfg.Auth.SecretKey == cfg.Auth.RefreshSecretKey {
+			errs = append(errs, errors.New("auth.refresh_secret_key (REFRESH_SECRET_KEY) must differ from auth.secret_key (SECRET_KEY)"))
+		}
+		if cfg.Auth.PublishJWKS {
+			errs = append(errs, errors.New("auth.publish_jwks (JWT_PUBLISH_JWKS) needs an asymmetric auth.algorithm (JWT_ALGORITHM)"))
+		}
+	case "RS256", "EdDSA":
+		required("auth.access_private_key_file (JWT_ACCESS_PRIVATE_KEY_FILE)", cfg.Auth.AccessPrivateKeyFile)
+		required("auth.refresh_private_key_file (JWT_REFRESH_PRIVATE_KEY_FILE)", cfg.Auth.RefreshPrivateKeyFile)
+	default:
+		errs = append(errs, fmt.Errorf("auth.algorithm (JWT_ALGORITHM) must be HS256, RS256 or EdDSA, got %q", cfg.Auth.Algorithm))
+	}
+	required("auth.issuer (JWT_ISSUER)", cfg.Auth.Issuer)
+	required("auth.audience (JWT_AUDIENCE)", cfg.Auth.Audience)
+
+	if cfg.Storage.UploadURLTTL.Duration <= 0 {
+		errs = append(errs, errors.New("storage.upload_url_ttl (UPLOAD_URL_TTL) must be positive"))
+	}
+	if cfg.Storage.UploadSweepInterval.Duration <= 0 {
+		errs = append(errs, errors.New("storage.upload_sweep_interval (UPLOAD_SWEEP_INTERVAL) must be positive"))
+	}
+	if cfg.Auth.AccessTokenTTL.Duration <= 0 {
+		errs = append(errs, errors.New("auth.access_token_ttl (ACCESS_TOKEN_TTL) must be positive"))
+	}
+	if cfg.Auth.RefreshTokenTTL.Duration <= cfg.Auth.AccessTokenTTL.Duration {
+		errs = append(errs, errors.New("auth.refresh_token_ttl (REFRESH_TOKEN_TTL) must be longer than the access token ttl"))
+	}
+	required("auth.mfa_issuer (MFA_ISSUER)", cfg.Auth.MFAIssuer)
+	if cfg.Auth.MFAChallengeTTL.Duration <= 0 {
+		errs = append(errs, errors.New("auth.mfa_challenge_ttl (MFA_CHALLENGE_TTL) must be positive"))
+	}
+
+	switch cfg.Mail.Provider {
+	case "log":
+	case "file":
+		required("mail.dir (MAIL_DIR)", cfg.Mail.Dir)
+	case "smtp":
+		required("mail.smtp_host (SMTP_HOST)", cfg.Mail.SMTPHost)
+		required("mail.from (MAIL_FROM)", cfg.Mail.From)
+	default:
+		errs = append(errs, fmt.Errorf("mail.provider (MAIL_PROVIDER) must be log, file or smtp, got %q", cfg.Mail.Provider))
+	}
+	required("account.app_url (APP_URL)", cfg.Account.AppURL)
+	if cfg.Account.PasswordResetTTL.Duration <= 0 {
+		errs = append(errs, errors.New("account.password_reset_ttl (PASSWORD_RESET_TTL) must be positive"))
+	}
+	if cfg.Account.EmailVerificationTTL.Duration <= 0 {
+		errs = append(errs, errors.New("account.email_verification_ttl (EMAIL_VERIFICATION_TTL) must be positive"))
+	}
+	if cfg.Account.LoginMaxFailures <= 0 || cfg.Account.LoginIPMaxFailures <= 0 {
+		errs = append(errs, errors.New("account.login_max_failures (LOGIN_MAX_FAILURES) and account.login_ip_max_failures (LOGIN_IP_MAX_FAILURES) must be positive"))
+	}
+	if cfg.Account.LoginLockout.Duration <= 0 || cfg.Account.LoginMaxLockout.Duration < cfg.Account.LoginLockout.Duration {
+		errs = append(errs, errors.New("account.login_lockout (LOGIN_LOCKOUT) must be positive and no longer than account.login_max_lockout (LOGIN_MAX_LOCKOUT)"))
+	}
+	if cfg.Account.InviteTTL.Duration <= 0 || cfg.Account.InviteMaxTTL.Duration < cfg.Account.InviteTTL.Duration {
+		errs = append(errs, errors.New("account.invite_ttl (INVITE_TTL) must be positive and no longer than account.invite_max_ttl (INVITE_MAX_TTL)"))
+	}
+	if cfg.Account.DeletionGrace.Duration < 0 {
+		errs = append(errs, errors.New("account.deletion_grace (ACCOUNT_DELETION_GRACE) must not be negative"))
+	}
+	if cfg.Account.PurgeInterval.Duration <= 0 {
+		errs = append(errs, errors.New("account.purge_interval (ACCOUNT_PURGE_INTERVAL) must be positive"))
+	}
+	if cfg.Schedule.MaxBackdate.Duration < 0 {
+		errs = append(errs, errors.New("schedule.max_backdate (SCHEDULE_MAX_BACKDATE) must not be negative"))
+	}
+	if cfg.Schedule.MaxLength.Duration <= 0 {
+		errs = append(errs, errors.New("schedule.max_length (SCHEDULE_MAX_LENGTH) must be positive"))
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration: %w", errors.Join(errs...))
+	}
+	return nil
+}
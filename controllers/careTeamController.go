@@ -0,0 +1,330 @@
+package controllers
+
+import (
+	"context"
+	"golang-speakbackend/helpers"
+	"golang-speakbackend/models"
+	"golang-speakbackend/repositories"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CareTeamController struct {
+	store *repositories.Store
+}
+
+func NewCareTeamController(store *repositories.Store) *CareTeamController {
+	return &CareTeamController{store: store}
+}
+
+// GetCareTeam lists every relationship of the patient's care team, past and
+// present.
+func (cc *CareTeamController) GetCareTeam() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		relationships, err := cc.store.CareTeams.ListByPatient(ctx, c.Param("patient_id"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while fetching care team"})
+			return
+		}
+
+		c.JSON(http.StatusOK, relationships)
+	}
+}
+
+// AddCareTeamMember puts a therapist on the patient's care team. Admins and
+// team members who manage the team can add members. A patient has at most one
+// primary therapist at a time.
+func (cc *CareTeamController) AddCareTeamMember() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		patientID := c.Param("patient_id")
+
+		var requestBody struct {
+			TherapistID string     `json:"therapist_id" validate:"required"`
+			Role        string     `json:"role" validate:"required,oneof=primary assistant student supervisor"`
+			StartDate   *time.Time `json:"start_date"`
+			EndDate     *time.Time `json:"end_date"`
+		}
+		if err := c.BindJSON(&requestBody); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			return
+		}
+		if err := validate.Struct(requestBody); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		now := time.Now().UTC()
+		startDate := now
+		if requestBody.StartDate != nil {
+			startDate = requestBody.StartDate.UTC()
+		}
+		if requestBody.EndDate != nil && !requestBody.EndDate.After(startDate) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "End date must be after the start date"})
+			return
+		}
+
+		if !cc.canManageTeam(ctx, c, patientID) {
+			return
+		}
+
+		users, err := cc.store.Users.FindByIDs(ctx, []string{patientID, requestBody.TherapistID})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching user data"})
+			return
+		}
+		var patient, therapist *models.User
+		for i := range users {
+			switch users[i].UserID {
+			case patientID:
+				patient = &users[i]
+			case requestBody.TherapistID:
+				therapist = &users[i]
+			}
+		}
+		if patient == nil || patient.Role != helpers.RolePatient {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Patient not found"})
+			return
+		}
+		if therapist == nil || therapist.Role != helpers.RoleTherapist || helpers.IsAccountDeleted(therapist) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Therapist not found"})
+			return
+		}
+		if !helpers.IsEmailVerified(therapist) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Therapist has not verified their email yet"})
+			return
+		}
+
+		// Relationships that are active now or will become active count
+		relationships, err := cc.store.CareTeams.ListByPatient(ctx, patientID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while fetching care team"})
+			return
+		}
+		for _, relationship := range relationships {
+			if relationship.Status != models.CareStatusActive || (relationship.EndDate != nil && !relationship.EndDate.After(now)) {
+				continue
+			}
+			if relationship.TherapistID == therapist.UserID {
+				c.JSON(http.StatusConflict, gin.H{"error": "Therapist is already on the care team"})
+				return
+			}
+			if requestBody.Role == models.CareRolePrimary && relationship.Role == models.CareRolePrimary {
+				c.JSON(http.StatusConflict, gin.H{"error": "Patient already has a primary therapist"})
+				return
+			}
+		}
+
+		relationship := helpers.NewCareRelationship(patientID, therapist.UserID, requestBody.Role, startDate, requestBody.EndDate, c.GetString("user_id"))
+		if err := cc.store.CareTeams.Insert(ctx, relationship); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while adding care team member"})
+			return
+		}
+
+		c.JSON(http.StatusOK, relationship)
+	}
+}
+
+// EndCareTeamMember takes a therapist off the patient's care team. Besides
+// admins and team managers, the patient and the therapist themselves can end
+// the relationship. Patients withdraw pending link requests this way too.
+func (cc *CareTeamController) EndCareTeamMember() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		patientID := c.Param("patient_id")
+
+		relationship, err := cc.store.CareTeams.FindByID(ctx, c.Param("relationship_id"))
+		if err == repositories.ErrNotFound || (err == nil && relationship.PatientID != patientID) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Care relationship not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while fetching care relationship"})
+			return
+		}
+
+		userID := c.GetString("user_id")
+		if userID != patientID && userID != relationship.TherapistID && !cc.canManageTeam(ctx, c, patientID) {
+			return
+		}
+
+		if relationship.Status != models.CareStatusActive && relationship.Status != models.CareStatusPending {
+			c.JSON(http.StatusConflict, gin.H{"error": "Care relationship has already ended"})
+			return
+		}
+
+		if err := cc.store.CareTeams.End(ctx, relationship.RelationshipID, time.Now().UTC()); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while ending care relationship"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Care relationship ended"})
+	}
+}
+
+// GetLinkRequests lists the patients waiting for the therapist to accept
+// them, oldest first.
+func (cc *CareTeamController) GetLinkRequests() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		relationships, err := cc.store.CareTeams.ListByTherapist(ctx, c.Param("therapist_id"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while fetching link requests"})
+			return
+		}
+		pending := []models.CareRelationship{}
+		patientIDs := []string{}
+		for _, relationship := range relationships {
+			if relationship.Status == models.CareStatusPending {
+				pending = append(pending, relationship)
+				patientIDs = append(patientIDs, relationship.PatientID)
+			}
+		}
+
+		patients := map[string]models.User{}
+		if len(patientIDs) > 0 {
+			users, err := cc.store.Users.FindByIDs(ctx, patientIDs)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching user data"})
+				return
+			}
+			for _, user := range users {
+				patients[user.UserID] = user
+			}
+		}
+
+		requests := []gin.H{}
+		for _, relationship := range pending {
+			patient, ok := patients[relationship.PatientID]
+			if !ok {
+				continue
+			}
+			requests = append(requests, gin.H{
+				"relationship_id": relationship.RelationshipID,
+				"patient_id":      relationship.PatientID,
+				"first_name":      patient.FirstName,
+				"last_name":       patient.LastName,
+				"email":           patient.Email,
+				"requested_at":    relationship.CreatedAt,
+			})
+		}
+
+		c.JSON(http.StatusOK, requests)
+	}
+}
+
+// AcceptLinkRequest makes the therapist the patient's primary therapist,
+// taking over from any previous one.
+func (cc *CareTeamController) AcceptLinkRequest() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		relationship, ok := cc.pendingLinkRequest(ctx, c)
+		if !ok {
+			return
+		}
+
+		active, err := helpers.ActiveCareRelationship(ctx, cc.store, relationship.TherapistID, relationship.PatientID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while fetching care team"})
+			return
+		}
+		if active != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "Therapist is already on the care team"})
+			return
+		}
+
+		err = helpers.AcceptLinkRequest(ctx, cc.store, relationship)
+		if err == repositories.ErrConflict {
+			c.JSON(http.StatusConflict, gin.H{"error": "Link request is no longer pending"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while accepting link request"})
+			return
+		}
+
+		c.JSON(http.StatusOK, relationship)
+	}
+}
+
+// DeclineLinkRequest turns the patient's link request down.
+func (cc *CareTeamController) DeclineLinkRequest() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		relationship, ok := cc.pendingLinkRequest(ctx, c)
+		if !ok {
+			return
+		}
+
+		err := cc.store.CareTeams.Decline(ctx, relationship.RelationshipID, time.Now().UTC())
+		if err == repositories.ErrConflict {
+			c.JSON(http.StatusConflict, gin.H{"error": "Link request is no longer pending"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while declining link request"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Link request declined"})
+	}
+}
+
+// pendingLinkRequest fetches the link request named by the route and checks
+// it is pending and addressed to the caller. It writes the error response and
+// returns false otherwise.
+func (cc *CareTeamController) pendingLinkRequest(ctx context.Context, c *gin.Context) (*models.CareRelationship, bool) {
+	relationship, err := cc.store.CareTeams.FindByID(ctx, c.Param("relationship_id"))
+	if err == repositories.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Link request not found"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while fetching link request"})
+		return nil, false
+	}
+	if err := helpers.MatchUserTypeToUid(c, relationship.TherapistID); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if relationship.Status != models.CareStatusPending {
+		c.JSON(http.StatusConflict, gin.H{"error": "Link request is no longer pending"})
+		return nil, false
+	}
+	return relationship, true
+}
+
+// canManageTeam lets admins and team members whose role manages the team
+// through. It writes the error response and returns false otherwise.
+func (cc *CareTeamController) canManageTeam(ctx context.Context, c *gin.Context, patientID string) bool {
+	switch c.GetString("role") {
+	case helpers.RoleAdmin:
+		return true
+	case helpers.RoleTherapist:
+		allowed, err := helpers.HasCareCapability(ctx, cc.store, c.GetString("user_id"), patientID, helpers.CareCapabilityManageTeam)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while fetching care team"})
+			return false
+		}
+		if allowed {
+			return true
+		}
+	}
+	c.JSON(http.StatusForbidden, gin.H{"error": helpers.ErrUnauthorized.Error()})
+	return false
+}
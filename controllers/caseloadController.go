@@ -0,0 +1,192 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"golang-speakbackend/helpers"
+	"golang-speakbackend/models"
+	"golang-speakbackend/repositories"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CaseloadController struct {
+	store *repositories.Store
+}
+
+func NewCaseloadController(store *repositories.Store) *CaseloadController {
+	return &CaseloadController{store: store}
+}
+
+// TransferCaseload hands some or all of a therapist's patients to another
+// therapist and responds with the transfer report. Therapists can transfer
+// their own patients from the care teams they manage, admins anyone's.
+func (cc *CaseloadController) TransferCaseload() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 60*time.Second)
+		defer cancel()
+
+		var requestBody struct {
+			FromTherapistID string   `json:"from_therapist_id" validate:"required"`
+			ToTherapistID   string   `json:"to_therapist_id" validate:"required"`
+			PatientIDs      []string `json:"patient_ids" validate:"omitempty,dive,required"`
+		}
+		if err := c.BindJSON(&requestBody); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			return
+		}
+		if err := validate.Struct(requestBody); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := helpers.MatchUserTypeToUid(c, requestBody.FromTherapistID); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+
+		transfer, ok := transferCaseload(ctx, c, cc.store, requestBody.FromTherapistID, requestBody.ToTherapistID, requestBody.PatientIDs)
+		if !ok {
+			return
+		}
+
+		c.JSON(http.StatusOK, transfer)
+	}
+}
+
+// GetTransfer returns a transfer report to admins and the therapists
+// involved.
+func (cc *CaseloadController) GetTransfer() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		transfer, err := cc.store.Transfers.FindByID(ctx, c.Param("transfer_id"))
+		if err == repositories.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Transfer not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while fetching transfer"})
+			return
+		}
+
+		if helpers.MatchUserTypeToUid(c, transfer.FromTherapistID) != nil && helpers.MatchUserTypeToUid(c, transfer.ToTherapistID) != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": helpers.ErrUnauthorized.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, transfer)
+	}
+}
+
+// GetTransfers lists the transfers from and to the therapist, newest first.
+func (cc *CaseloadController) GetTransfers() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		transfers, err := cc.store.Transfers.ListByTherapist(ctx, c.Param("therapist_id"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while fetching transfers"})
+			return
+		}
+
+		c.JSON(http.StatusOK, transfers)
+	}
+}
+
+// canTransferPatient reports whether the caller may hand the patient of the
+// relationship to someone else. Only admins and team managers can.
+func canTransferPatient(c *gin.Context, relationship models.CareRelationship) bool {
+	return c.GetString("role") == helpers.RoleAdmin || helpers.CareRoleAllows(relationship.Role, helpers.CareCapabilityManageTeam)
+}
+
+// transferCaseload checks both therapists and the patients, then runs the
+// transfer. An empty patientIDs transfers every patient the caller may
+// transfer. It writes the error response and returns false on failure.
+func transferCaseload(ctx context.Context, c *gin.Context, store *repositories.Store, fromTherapistID string, toTherapistID string, patientIDs []string) (*models.CaseloadTransfer, bool) {
+	if fromTherapistID == toTherapistID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot transfer patients to the same therapist"})
+		return nil, false
+	}
+
+	users, err := store.Users.FindByIDs(ctx, []string{fromTherapistID, toTherapistID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching user data"})
+		return nil, false
+	}
+	var from, to *models.User
+	for i := range users {
+		switch users[i].UserID {
+		case fromTherapistID:
+			from = &users[i]
+		case toTherapistID:
+			to = &users[i]
+		}
+	}
+	if from == nil || from.Role != helpers.RoleTherapist {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Therapist not found"})
+		return nil, false
+	}
+	if to == nil || to.Role != helpers.RoleTherapist || helpers.IsAccountDeleted(to) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Receiving therapist not found"})
+		return nil, false
+	}
+	if !helpers.IsEmailVerified(to) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Receiving therapist has not verified their email yet"})
+		return nil, false
+	}
+
+	relationships, err := helpers.ActiveCareRelationshipsOfTherapist(ctx, store, fromTherapistID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while fetching care team"})
+		return nil, false
+	}
+	byPatient := map[string]models.CareRelationship{}
+	for _, relationship := range relationships {
+		byPatient[relationship.PatientID] = relationship
+	}
+
+	transferable := func(relationship models.CareRelationship) bool {
+		return canTransferPatient(c, relationship)
+	}
+
+	selected := []models.CareRelationship{}
+	if len(patientIDs) == 0 {
+		for _, relationship := range relationships {
+			if transferable(relationship) {
+				selected = append(selected, relationship)
+			}
+		}
+	}
+	seen := map[string]bool{}
+	for _, patientID := range patientIDs {
+		if seen[patientID] {
+			continue
+		}
+		seen[patientID] = true
+		relationship, ok := byPatient[patientID]
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Patient %s is not on the therapist's caseload", patientID)})
+			return nil, false
+		}
+		if !transferable(relationship) {
+			c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("A %s on the care team cannot transfer patient %s", relationship.Role, patientID)})
+			return nil, false
+		}
+		selected = append(selected, relationship)
+	}
+
+	transfer, err := helpers.TransferCaseload(ctx, store, selected, fromTherapistID, toTherapistID, c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while transferring patients"})
+		return nil, false
+	}
+	log.Printf("Transferred %d patients from therapist %s to %s (transfer %s, requested by %s)",
+		len(transfer.Patients), fromTherapistID, toTherapistID, transfer.TransferID, transfer.RequestedBy)
+	return transfer, true
+}
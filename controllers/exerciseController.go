@@ -3,24 +3,31 @@ package controllers
 import (
 	"context"
 	"fmt"
-	"golang-speakbackend/database"
+	"golang-speakbackend/helpers"
 	"golang-speakbackend/models"
+	"golang-speakbackend/repositories"
+	"log"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-var exerciseCollection *mongo.Collection = database.OpenCollection(database.Client, "exercise")
 var validate = validator.New()
 
-func GetExercises() gin.HandlerFunc {
+type ExerciseController struct {
+	store   *repositories.Store
+	storage *helpers.Storage
+}
+
+func NewExerciseController(store *repositories.Store, storage *helpers.Storage) *ExerciseController {
+	return &ExerciseController{store: store, storage: storage}
+}
+
+func (ec *ExerciseController) GetExercises() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
@@ -37,55 +44,8 @@ func GetExercises() gin.HandlerFunc {
 
 		startIndex := (page - 1) * recordPerPage
 
-		// Match stage
-		matchStage := bson.D{{Key: "$match", Value: bson.D{}}}
-
-		// Count total number of exercises
-		countStage := bson.D{{Key: "$count", Value: "total"}}
-
-		// Skip and Limit for pagination
-		skipStage := bson.D{{Key: "$skip", Value: startIndex}}
-		limitStage := bson.D{{Key: "$limit", Value: recordPerPage}}
-
-		// Project stage to include necessary fields
-		projectStage := bson.D{
-			{Key: "$project", Value: bson.D{
-				{Key: "_id", Value: 1},
-				{Key: "name", Value: 1},
-				{Key: "description", Value: 1},
-				{Key: "video_url", Value: 1},
-				{Key: "tags", Value: 1},
-				{Key: "created_at", Value: 1},
-				{Key: "updated_at", Value: 1},
-				{Key: "exercise_id", Value: 1},
-			}},
-		}
-
-		// Aggregate total exercises count separately
-		countResult, err := exerciseCollection.Aggregate(ctx, mongo.Pipeline{matchStage, countStage})
+		exercises, totalCount, err := ec.store.Exercises.List(ctx, int64(startIndex), int64(recordPerPage))
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while counting exercises"})
-			return
-		}
-		var countData []bson.M
-		if err = countResult.All(ctx, &countData); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while counting exercises"})
-			return
-		}
-		totalCount := 0
-		if len(countData) > 0 {
-			totalCount = int(countData[0]["total"].(int32)) // Convert int32 to int
-		}
-
-		// Aggregate exercises with pagination
-		result, err := exerciseCollection.Aggregate(ctx, mongo.Pipeline{matchStage, skipStage, limitStage, projectStage})
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while fetching exercises"})
-			return
-		}
-
-		var exercises []bson.M
-		if err = result.All(ctx, &exercises); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while fetching exercises"})
 			return
 		}
@@ -99,15 +59,18 @@ func GetExercises() gin.HandlerFunc {
 	}
 }
 
-
-func GetExercise() gin.HandlerFunc {
+func (ec *ExerciseController) GetExercise() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
 		exerciseID := c.Param("exercise_id")
-		var exercise models.Exercise
 
-		err := exerciseCollection.FindOne(ctx, bson.M{"exercise_id": exerciseID}).Decode(&exercise)
-		defer cancel()
+		exercise, err := ec.store.Exercises.FindByID(ctx, exerciseID)
+		if err == repositories.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Exercise not found"})
+			return
+		}
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while fetching exercise"})
 			return
@@ -116,12 +79,13 @@ func GetExercise() gin.HandlerFunc {
 	}
 }
 
-func CreateExercise() gin.HandlerFunc {
+func (ec *ExerciseController) CreateExercise() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
 		var exercise models.Exercise
 
-		defer cancel()
 		if err := c.BindJSON(&exercise); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
@@ -137,77 +101,215 @@ func CreateExercise() gin.HandlerFunc {
 		exercise.UpdatedAt, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 		exercise.ID = primitive.NewObjectID()
 		exercise.ExerciseID = exercise.ID.Hex()
+		exercise.CreatedBy = c.GetString("user_id")
+		exercise.ArchivedAt = nil
 
-		result, insertErr := exerciseCollection.InsertOne(ctx, exercise)
+		insertErr := ec.store.Exercises.Insert(ctx, &exercise)
 		if insertErr != nil {
 			msg := fmt.Sprintf("Error while inserting exercise: %s", insertErr)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
 			return
 		}
-		defer cancel()
-		c.JSON(http.StatusOK, result)
+		c.JSON(http.StatusOK, gin.H{"InsertedID": exercise.ID})
 	}
 }
 
-func UpdateExercise() gin.HandlerFunc {
+func (ec *ExerciseController) UpdateExercise() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
 		var exercise models.Exercise
 
-		defer cancel()
 		if err := c.BindJSON(&exercise); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
 		exerciseID := c.Param("exercise_id")
-		filter := bson.M{"exercise_id": exerciseID}
 
-		var updateObj primitive.D
-
-		if exercise.Name != nil {
-			updateObj = append(updateObj, bson.E{Key: "name", Value: exercise.Name})
+		existing, err := ec.store.Exercises.FindByID(ctx, exerciseID)
+		if err == repositories.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Exercise not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while fetching exercise"})
+			return
 		}
-		if exercise.Description != nil {
-			updateObj = append(updateObj, bson.E{Key: "description", Value: exercise.Description})
+		if !isExerciseAuthor(c, existing) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only the exercise's author or an admin can change it"})
+			return
+		}
+
+		update := repositories.ExerciseUpdate{
+			Name:        exercise.Name,
+			Description: exercise.Description,
+			Tags:        exercise.Tags,
 		}
 		if exercise.VideoURL != "" {
-			updateObj = append(updateObj, bson.E{Key: "video_url", Value: exercise.VideoURL})
+			update.VideoURL = &exercise.VideoURL
 		}
-		if exercise.Tags != nil {
-			updateObj = append(updateObj, bson.E{Key: "tags", Value: exercise.Tags})
+
+		err = ec.store.Exercises.Update(ctx, exerciseID, update)
+		if err == repositories.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Exercise not found"})
+			return
+		}
+		if err != nil {
+			msg := fmt.Sprintf("Exercise update failed: %s", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+			return
 		}
 
-		exercise.UpdatedAt, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
-		updateObj = append(updateObj, bson.E{Key: "updated_at", Value: exercise.UpdatedAt})
+		c.JSON(http.StatusOK, gin.H{"message": "Exercise updated successfully"})
+	}
+}
+
+// isExerciseAuthor allows admins and the therapist who created the exercise.
+// Exercises from before authors were recorded are left to admins.
+func isExerciseAuthor(c *gin.Context, exercise *models.Exercise) bool {
+	if c.GetString("role") == helpers.RoleAdmin {
+		return true
+	}
+	return exercise.CreatedBy != "" && exercise.CreatedBy == c.GetString("user_id")
+}
 
-		upsert := true
+// DeleteExercise removes an exercise from the catalogue without breaking the
+// assignments that use it. An unused exercise is deleted. One only referenced
+// by archived assignments and ended schedules is archived instead, so their
+// history stays readable. One still in use is refused with 409 unless
+// ?archive=true asks to archive it anyway. Admins can pass ?force=true to
+// delete it together with everything that references it. Only the exercise's
+// author and admins can archive or delete it.
+func (ec *ExerciseController) DeleteExercise() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
 
-		opt := options.UpdateOptions{
-			Upsert: &upsert,
+		exerciseID := c.Param("exercise_id")
+		force := c.Query("force") == "true"
+		if force && c.GetString("role") != helpers.RoleAdmin {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only admins can force-delete exercises"})
+			return
 		}
 
-		result, err := exerciseCollection.UpdateOne(
-			ctx,
-			filter,
-			bson.D{
-				{Key: "$set", Value: updateObj},
-			},
-			&opt,
-		)
+		exercise, err := ec.store.Exercises.FindByID(ctx, exerciseID)
+		if err == repositories.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Exercise not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while fetching exercise"})
+			return
+		}
+		if !isExerciseAuthor(c, exercise) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only the exercise's author or an admin can remove it"})
+			return
+		}
 
+		patientExercises, err := ec.store.PatientExercises.ListByExercise(ctx, exerciseID)
 		if err != nil {
-			msg := fmt.Sprintf("Exercise update failed: %s", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while fetching patient exercises"})
+			return
+		}
+		schedules, err := ec.store.Schedules.ListByExercise(ctx, exerciseID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while fetching schedules"})
+			return
 		}
 
-		defer cancel()
-		c.JSON(http.StatusOK, result)
+		if force {
+			if err := ec.deleteExerciseCascade(ctx, exerciseID, patientExercises); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while deleting exercise"})
+				return
+			}
+			log.Printf("Exercise %s force-deleted by %s with %d patient exercises and %d schedules",
+				exerciseID, c.GetString("user_id"), len(patientExercises), len(schedules))
+			c.JSON(http.StatusOK, gin.H{"message": "Exercise deleted", "deleted_patient_exercises": len(patientExercises), "deleted_schedules": len(schedules)})
+			return
+		}
+
+		if len(patientExercises) == 0 && len(schedules) == 0 {
+			if err := ec.store.Exercises.Delete(ctx, exerciseID); err != nil && err != repositories.ErrNotFound {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while deleting exercise"})
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"message": "Exercise deleted"})
+			return
+		}
+
+		activeAssignments := 0
+		for _, patientExercise := range patientExercises {
+			if helpers.NormalizeStatus(patientExercise.Status) != models.StatusArchived {
+				activeAssignments++
+			}
+		}
+		today := helpers.StartOfDay(time.Now().UTC())
+		activeSchedules := 0
+		for _, schedule := range schedules {
+			if !schedule.EndDate.Before(today) {
+				activeSchedules++
+			}
+		}
+		if (activeAssignments > 0 || activeSchedules > 0) && c.Query("archive") != "true" {
+			c.JSON(http.StatusConflict, gin.H{
+				"error":              "Exercise is still in use, archive it with ?archive=true instead",
+				"active_assignments": activeAssignments,
+				"active_schedules":   activeSchedules,
+			})
+			return
+		}
+
+		if exercise.ArchivedAt == nil {
+			if err := ec.store.Exercises.Archive(ctx, exerciseID, time.Now().UTC()); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while archiving exercise"})
+				return
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Exercise archived, it stays readable for existing assignments"})
 	}
 }
 
-func DeleteExercise() gin.HandlerFunc {
-	return func(c *gin.Context) {
-
+// deleteExerciseCascade deletes the exercise with its schedules, the patient
+// exercises assigned from it and their recordings, recording attempts and
+// feedback.
+//
+// Like an account purge, the recordings are removed first and the documents
+// in one transaction at the end, so an interrupted delete is simply run
+// again.
+func (ec *ExerciseController) deleteExerciseCascade(ctx context.Context, exerciseID string, patientExercises []models.PatientExercise) error {
+	patientExerciseIDs := make([]string, 0, len(patientExercises))
+	for _, patientExercise := range patientExercises {
+		patientExerciseIDs = append(patientExerciseIDs, patientExercise.PatientExerciseID)
+		// Matches both the single recording of old and the attempts
+		if _, err := ec.storage.DeletePrefix(ctx, "recordings/"+patientExercise.PatientExerciseID); err != nil {
+			return err
+		}
 	}
+
+	return ec.store.WithTransaction(ctx, func(ctx context.Context) error {
+		// Schedules go first so nothing generates new occurrences meanwhile
+		if err := ec.store.Schedules.DeleteByExercise(ctx, exerciseID); err != nil {
+			return err
+		}
+
+		if len(patientExerciseIDs) > 0 {
+			if err := ec.store.Feedback.DeleteByPatientExercises(ctx, patientExerciseIDs); err != nil {
+				return err
+			}
+			if err := ec.store.Attempts.DeleteByPatientExercises(ctx, patientExerciseIDs); err != nil {
+				return err
+			}
+		}
+
+		if err := ec.store.PatientExercises.DeleteByExercise(ctx, exerciseID); err != nil {
+			return err
+		}
+		err := ec.store.Exercises.Delete(ctx, exerciseID)
+		if err == repositories.ErrNotFound {
+			return nil
+		}
+		return err
+	})
 }
@@ -0,0 +1,98 @@
+package controllers
+
+import (
+	"context"
+	"golang-speakbackend/helpers"
+	"golang-speakbackend/models"
+	"golang-speakbackend/repositories"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type FeedbackController struct {
+	store *repositories.Store
+}
+
+func NewFeedbackController(store *repositories.Store) *FeedbackController {
+	return &FeedbackController{store: store}
+}
+
+// CreateFeedback records a review of a submitted recording by a therapist
+// whose care team role lets them assign the patient's exercises, and moves
+// the patient exercise to reviewed or needs_redo.
+func (fc *FeedbackController) CreateFeedback() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		patientExerciseID := c.Param("id")
+		patientExercise, err := fc.store.PatientExercises.FindByID(ctx, patientExerciseID)
+		if err == repositories.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Patient exercise not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while fetching patient exercise"})
+			return
+		}
+
+		var feedback models.Feedback
+		if err := c.BindJSON(&feedback); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		validationError := validate.Struct(feedback)
+		if validationError != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationError.Error()})
+			return
+		}
+
+		if err := helpers.CheckTransition(patientExercise.Status, feedback.Decision, c.GetString("role")); err != nil {
+			respondTransitionError(c, err, "")
+			return
+		}
+
+		feedback.PatientExerciseID = patientExerciseID
+		feedback.TherapistID = c.GetString("user_id")
+		feedback.CreatedAt, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		feedback.ID = primitive.NewObjectID()
+		feedback.FeedbackID = feedback.ID.Hex()
+
+		// The feedback is only kept if the exercise gets its decision, so a
+		// concurrent status change doesn't leave a review behind
+		err = fc.store.WithTransaction(ctx, func(ctx context.Context) error {
+			err := helpers.TransitionPatientExercise(ctx, fc.store, patientExercise, feedback.Decision,
+				c.GetString("user_id"), c.GetString("role"), repositories.PatientExerciseUpdate{})
+			if err != nil {
+				return err
+			}
+			return fc.store.Feedback.Insert(ctx, &feedback)
+		})
+		if err != nil {
+			respondTransitionError(c, err, "Error while saving feedback")
+			return
+		}
+
+		c.JSON(http.StatusOK, feedback)
+	}
+}
+
+// GetFeedback lists all feedback on a patient exercise, oldest first.
+func (fc *FeedbackController) GetFeedback() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		feedback, err := fc.store.Feedback.ListByPatientExercise(ctx, c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while fetching feedback"})
+			return
+		}
+
+		c.JSON(http.StatusOK, feedback)
+	}
+}
@@ -0,0 +1,270 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"golang-speakbackend/config"
+	"golang-speakbackend/helpers"
+	"golang-speakbackend/models"
+	"golang-speakbackend/repositories"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type InviteController struct {
+	store   *repositories.Store
+	mailer  helpers.Mailer
+	account config.AccountConfig
+}
+
+func NewInviteController(store *repositories.Store, mailer helpers.Mailer, account config.AccountConfig) *InviteController {
+	return &InviteController{store: store, mailer: mailer, account: account}
+}
+
+// CreateInvite mints an invite code for the calling therapist. The code is
+// only returned this once. An invite made out to an email address is mailed
+// there and can only be redeemed by that account.
+func (ic *InviteController) CreateInvite() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		var requestBody struct {
+			Email       string     `json:"email" validate:"omitempty,email"`
+			ExerciseIDs []string   `json:"exercise_ids" validate:"omitempty,dive,required"`
+			MaxUses     int        `json:"max_uses" validate:"omitempty,min=1,max=1000"`
+			ExpiresAt   *time.Time `json:"expires_at"`
+		}
+		if err := c.BindJSON(&requestBody); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			return
+		}
+		if err := validate.Struct(requestBody); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		now := time.Now().UTC()
+		expiresAt := now.Add(ic.account.InviteTTL.Duration)
+		if requestBody.ExpiresAt != nil {
+			expiresAt = requestBody.ExpiresAt.UTC()
+			if !expiresAt.After(now) || expiresAt.After(now.Add(ic.account.InviteMaxTTL.Duration)) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Expiry must be in the future and at most %s away", ic.account.InviteMaxTTL.Duration)})
+				return
+			}
+		}
+		maxUses := requestBody.MaxUses
+		if maxUses == 0 {
+			maxUses = 1
+		}
+
+		therapist, err := ic.store.Users.FindByID(ctx, c.GetString("user_id"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching user data"})
+			return
+		}
+		if !helpers.IsEmailVerified(therapist) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Verify your email before inviting patients"})
+			return
+		}
+
+		exerciseIDs := []string{}
+		seen := map[string]bool{}
+		for _, exerciseID := range requestBody.ExerciseIDs {
+			if !seen[exerciseID] {
+				seen[exerciseID] = true
+				exerciseIDs = append(exerciseIDs, exerciseID)
+			}
+		}
+		if len(exerciseIDs) > 0 {
+			exercises, err := ic.store.Exercises.FindByIDs(ctx, exerciseIDs)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching exercise data"})
+				return
+			}
+			if len(exercises) != len(exerciseIDs) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Some exercises not found"})
+				return
+			}
+			for _, exercise := range exercises {
+				if exercise.ArchivedAt != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Exercise %s is archived", exercise.ExerciseID)})
+					return
+				}
+			}
+		}
+
+		code, codeHash, err := helpers.GenerateInviteCode()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while creating invite"})
+			return
+		}
+
+		invite := models.Invite{
+			ID:          primitive.NewObjectID(),
+			TherapistID: therapist.UserID,
+			CodeHash:    codeHash,
+			Email:       strings.ToLower(requestBody.Email),
+			ExerciseIDs: exerciseIDs,
+			MaxUses:     maxUses,
+			Redemptions: []models.InviteRedemption{},
+			ExpiresAt:   &expiresAt,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		}
+		invite.InviteID = invite.ID.Hex()
+		if err := ic.store.Invites.Insert(ctx, &invite); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while creating invite"})
+			return
+		}
+
+		// The therapist gets the code either way, so a failed email is not fatal
+		if invite.Email != "" {
+			if err := ic.sendInviteEmail(ctx, therapist, &invite, code); err != nil {
+				log.Printf("Error sending invite %s to %s: %v", invite.InviteID, invite.Email, err)
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{"code": code, "invite": invite})
+	}
+}
+
+func (ic *InviteController) sendInviteEmail(ctx context.Context, therapist *models.User, invite *models.Invite, code string) error {
+	link := fmt.Sprintf("%s/invite?code=%s", strings.TrimRight(ic.account.AppURL, "/"), code)
+	return ic.mailer.Send(ctx, helpers.MailMessage{
+		To:      invite.Email,
+		Subject: "You're invited to PeakSpeak",
+		Body: fmt.Sprintf("Hi,\n\n%s %s invited you to practise with them on PeakSpeak. Sign up with this email address and enter the code %s, or follow the link below. The invite expires on %s.\n\n%s\n",
+			*therapist.FirstName, *therapist.LastName, code, invite.ExpiresAt.Format("2 January 2006"), link),
+	})
+}
+
+// GetInvites lists the calling therapist's invites with their redemptions,
+// newest first. The reference code shows up as a legacy invite.
+func (ic *InviteController) GetInvites() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		therapist, err := ic.store.Users.FindByID(ctx, c.GetString("user_id"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching user data"})
+			return
+		}
+		if _, err := helpers.LegacyInvite(ctx, ic.store, therapist); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while fetching invites"})
+			return
+		}
+
+		invites, err := ic.store.Invites.ListByTherapist(ctx, therapist.UserID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while fetching invites"})
+			return
+		}
+
+		c.JSON(http.StatusOK, invites)
+	}
+}
+
+// RevokeInvite stops an invite from being redeemed. Patients who already
+// redeemed it stay linked. Revoking the legacy invite retires the reference
+// code.
+func (ic *InviteController) RevokeInvite() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		invite, err := ic.store.Invites.FindByID(ctx, c.Param("invite_id"))
+		if err == repositories.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Invite not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while fetching invite"})
+			return
+		}
+
+		if c.GetString("role") != helpers.RoleAdmin && c.GetString("user_id") != invite.TherapistID {
+			c.JSON(http.StatusForbidden, gin.H{"error": helpers.ErrUnauthorized.Error()})
+			return
+		}
+		if invite.RevokedAt != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "Invite has already been revoked"})
+			return
+		}
+
+		if err := ic.store.Invites.Revoke(ctx, invite.InviteID, time.Now().UTC()); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while revoking invite"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Invite revoked"})
+	}
+}
+
+// RedeemInvite links the calling patient to the therapist who made the
+// invite. Therapists' reference codes are accepted too, but only send the
+// therapist a link request.
+func (ic *InviteController) RedeemInvite() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		var requestBody struct {
+			Code string `json:"code" binding:"required"`
+		}
+		if err := c.BindJSON(&requestBody); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			return
+		}
+
+		patient, err := ic.store.Users.FindByID(ctx, c.GetString("user_id"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching user data"})
+			return
+		}
+
+		redeemInvite(ctx, c, ic.store, requestBody.Code, patient)
+	}
+}
+
+// redeemInvite redeems the code for the patient and writes the response.
+func redeemInvite(ctx context.Context, c *gin.Context, store *repositories.Store, code string, patient *models.User) {
+	relationship, redemption, err := helpers.RedeemInvite(ctx, store, code, patient)
+	switch {
+	case errors.Is(err, helpers.ErrInvalidInvite):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired invite code"})
+		return
+	case errors.Is(err, helpers.ErrInviteEmailMismatch):
+		c.JSON(http.StatusForbidden, gin.H{"error": "This invite is for another email address"})
+		return
+	case errors.Is(err, helpers.ErrInviteEmailUnverified):
+		c.JSON(http.StatusForbidden, gin.H{"error": "Verify your email address to redeem this invite"})
+		return
+	case errors.Is(err, helpers.ErrTherapistUnverified):
+		c.JSON(http.StatusConflict, gin.H{"error": "Therapist has not verified their email yet"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while linking to therapist"})
+		return
+	}
+
+	message := "Linked to therapist successfully"
+	if relationship.Status == models.CareStatusPending {
+		message = "Link request sent, the therapist needs to accept it"
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message":              message,
+		"status":               relationship.Status,
+		"role":                 relationship.Role,
+		"therapist_id":         relationship.TherapistID,
+		"relationship_id":      relationship.RelationshipID,
+		"patient_exercise_ids": redemption.PatientExerciseIDs,
+	})
+}
@@ -0,0 +1,25 @@
+package controllers
+
+import (
+	"golang-speakbackend/helpers"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type JWKSController struct {
+	tokens *helpers.TokenMaker
+}
+
+func NewJWKSController(tokens *helpers.TokenMaker) *JWKSController {
+	return &JWKSController{tokens: tokens}
+}
+
+// GetJWKS publishes the public keys access tokens are signed with, so other
+// services can verify them without sharing a secret.
+func (jc *JWKSController) GetJWKS() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Cache-Control", "public, max-age=300")
+		c.JSON(http.StatusOK, jc.tokens.JWKS())
+	}
+}
@@ -0,0 +1,299 @@
+package controllers
+
+import (
+	"context"
+	"golang-speakbackend/config"
+	"golang-speakbackend/helpers"
+	"golang-speakbackend/models"
+	"golang-speakbackend/repositories"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// recoveryCodeCount is how many recovery codes a user gets at a time.
+const recoveryCodeCount = 10
+
+type MFAController struct {
+	store  *repositories.Store
+	tokens *helpers.TokenMaker
+	keys   helpers.KeyWrapper
+	auth   config.AuthConfig
+	guard  *helpers.LoginGuard
+}
+
+func NewMFAController(store *repositories.Store, tokens *helpers.TokenMaker, keys helpers.KeyWrapper, auth config.AuthConfig, guard *helpers.LoginGuard) *MFAController {
+	return &MFAController{store: store, tokens: tokens, keys: keys, auth: auth, guard: guard}
+}
+
+type mfaCodeRequest struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// StartEnrollment generates a TOTP secret for the user. It only takes effect
+// once ConfirmEnrollment sees a code from the authenticator app.
+func (mc *MFAController) StartEnrollment() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		user, err := mc.store.Users.FindByID(ctx, c.GetString("user_id"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "User not found"})
+			return
+		}
+		if user.MFA.Enabled {
+			c.JSON(http.StatusConflict, gin.H{"error": "MFA is already enabled"})
+			return
+		}
+
+		secret, err := helpers.GenerateTOTPSecret()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while generating secret"})
+			return
+		}
+		wrapped, err := mc.keys.WrapKey(ctx, []byte(secret))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while protecting secret"})
+			return
+		}
+
+		mfa := models.MFASettings{PendingSecret: wrapped}
+		if err := mc.store.Users.Update(ctx, user.UserID, repositories.UserUpdate{MFA: &mfa}); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while starting enrollment"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"secret":           secret,
+			"provisioning_uri": helpers.TOTPProvisioningURI(mc.auth.MFAIssuer, *user.Email, secret),
+		})
+	}
+}
+
+// ConfirmEnrollment enables MFA once the user proves their authenticator app
+// works. It returns the recovery codes, which are shown only this once, and
+// tokens for a session that counts as MFA in place of the current one.
+func (mc *MFAController) ConfirmEnrollment() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		var requestBody mfaCodeRequest
+		if err := c.BindJSON(&requestBody); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			return
+		}
+
+		user, err := mc.store.Users.FindByID(ctx, c.GetString("user_id"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "User not found"})
+			return
+		}
+		if user.MFA.Enabled {
+			c.JSON(http.StatusConflict, gin.H{"error": "MFA is already enabled"})
+			return
+		}
+		if user.MFA.PendingSecret == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Start the enrollment first"})
+			return
+		}
+
+		secret, err := mc.keys.UnwrapKey(ctx, user.MFA.PendingSecret)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while reading secret"})
+			return
+		}
+		step, ok := helpers.VerifyTOTP(string(secret), requestBody.Code, time.Now())
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": helpers.ErrInvalidMFACode.Error()})
+			return
+		}
+
+		codes, hashes, err := helpers.GenerateRecoveryCodes(recoveryCodeCount)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while generating recovery codes"})
+			return
+		}
+
+		now := time.Now().UTC()
+		mfa := models.MFASettings{
+			Enabled:       true,
+			EnabledAt:     &now,
+			Secret:        user.MFA.PendingSecret,
+			RecoveryCodes: hashes,
+			LastStep:      step,
+		}
+		if err := mc.store.Users.Update(ctx, user.UserID, repositories.UserUpdate{MFA: &mfa}); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while enabling MFA"})
+			return
+		}
+
+		if err := helpers.RevokeSession(ctx, mc.store, c.GetString("family_id"), models.RevokedMFAEnrolled); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while revoking session"})
+			return
+		}
+		token, refreshToken, err := helpers.StartSession(ctx, mc.store, mc.tokens, user, helpers.AMRPassword, helpers.AMRMFA)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while generating token"})
+			return
+		}
+		helpers.UpdateAllTokens(mc.store.Users, token, refreshToken, user.UserID)
+
+		c.JSON(http.StatusOK, gin.H{"recovery_codes": codes, "token": token, "refresh_token": refreshToken})
+	}
+}
+
+// VerifyLogin is the second login step. It exchanges the challenge from Login
+// and a TOTP or recovery code for the real tokens.
+func (mc *MFAController) VerifyLogin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		var requestBody struct {
+			MFAToken string `json:"mfa_token" validate:"required"`
+			mfaCodeRequest
+		}
+		if err := c.BindJSON(&requestBody); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			return
+		}
+		if err := validate.Struct(requestBody); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		claims, msg := mc.tokens.ValidateToken(requestBody.MFAToken, helpers.TokenTypeMFAChallenge)
+		if msg != "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": msg})
+			return
+		}
+
+		// Codes are throttled like passwords, against the same counters
+		ip := c.ClientIP()
+		if respondIfLoginLocked(ctx, c, mc.guard, claims.Email, ip) {
+			return
+		}
+
+		user, err := mc.store.Users.FindByID(ctx, claims.UserID)
+		if err == repositories.ErrNotFound {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": helpers.ErrInvalidMFACode.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+
+		err = helpers.VerifySecondFactor(ctx, mc.store, mc.keys, user, requestBody.Code, requestBody.RecoveryCode)
+		if err == helpers.ErrInvalidMFACode {
+			failLogin(ctx, c, mc.guard, claims.Email, ip, err.Error())
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while checking the code"})
+			return
+		}
+
+		if err := mc.guard.Succeed(ctx, claims.Email); err != nil {
+			log.Printf("Could not reset failed logins for user %s: %v", user.UserID, err)
+		}
+		respondWithSession(ctx, c, mc.store, mc.tokens, user, helpers.AMRPassword, helpers.AMRMFA)
+	}
+}
+
+// RegenerateRecoveryCodes replaces every recovery code, for users who used up
+// or lost theirs. It needs a current code.
+func (mc *MFAController) RegenerateRecoveryCodes() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		user, ok := mc.verifiedUser(ctx, c)
+		if !ok {
+			return
+		}
+
+		codes, hashes, err := helpers.GenerateRecoveryCodes(recoveryCodeCount)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while generating recovery codes"})
+			return
+		}
+
+		// Re-read so the replay protection from VerifySecondFactor is kept
+		user, err = mc.store.Users.FindByID(ctx, user.UserID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "User not found"})
+			return
+		}
+		mfa := user.MFA
+		mfa.RecoveryCodes = hashes
+		if err := mc.store.Users.Update(ctx, user.UserID, repositories.UserUpdate{MFA: &mfa}); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while saving recovery codes"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+	}
+}
+
+// Disable turns MFA off. It needs a current code, and therapists can't
+// disable it while the clinic enforces MFA.
+func (mc *MFAController) Disable() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		if mc.auth.RequireTherapistMFA && c.GetString("role") == helpers.RoleTherapist {
+			c.JSON(http.StatusForbidden, gin.H{"error": "MFA is required for therapist accounts"})
+			return
+		}
+
+		user, ok := mc.verifiedUser(ctx, c)
+		if !ok {
+			return
+		}
+
+		if err := mc.store.Users.Update(ctx, user.UserID, repositories.UserUpdate{MFA: &models.MFASettings{}}); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while disabling MFA"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "MFA disabled"})
+	}
+}
+
+// verifiedUser loads the current user and checks the code in the request
+// body, writing the error response if that fails.
+func (mc *MFAController) verifiedUser(ctx context.Context, c *gin.Context) (*models.User, bool) {
+	var requestBody mfaCodeRequest
+	if err := c.BindJSON(&requestBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return nil, false
+	}
+
+	user, err := mc.store.Users.FindByID(ctx, c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "User not found"})
+		return nil, false
+	}
+	if !user.MFA.Enabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "MFA is not enabled"})
+		return nil, false
+	}
+
+	err = helpers.VerifySecondFactor(ctx, mc.store, mc.keys, user, requestBody.Code, requestBody.RecoveryCode)
+	if err == helpers.ErrInvalidMFACode {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while checking the code"})
+		return nil, false
+	}
+	return user, true
+}
@@ -3,196 +3,415 @@ package controllers
 import (
 	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
-	"golang-speakbackend/database"
 	"golang-speakbackend/helpers"
 	"golang-speakbackend/models"
+	"golang-speakbackend/repositories"
+	"io"
 	"log"
 	"net/http"
-	"os"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/service/kms"
-	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/gin-gonic/gin"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-var patientExerciseCollection *mongo.Collection = database.OpenCollection(database.Client, "patient_exercise")
+type PatientExerciseController struct {
+	store   *repositories.Store
+	storage *helpers.Storage
+	keys    helpers.KeyWrapper
+}
 
-func wrapKey(key, kmsKeyID string) (string, error) {
-	kmsClient := helpers.GetKMSClient()
+func NewPatientExerciseController(store *repositories.Store, storage *helpers.Storage, keys helpers.KeyWrapper) *PatientExerciseController {
+	return &PatientExerciseController{store: store, storage: storage, keys: keys}
+}
 
-	input := &kms.EncryptInput{
-		KeyId:     aws.String(kmsKeyID),
-		Plaintext: []byte(key),
-	}
+func (pc *PatientExerciseController) RecordingPresignPost() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
 
-	result, err := kmsClient.Encrypt(context.Background(), input)
-	if err != nil {
-		log.Printf("Error encrypting key: %v", err)
-		return "", err
-	}
+		patientExerciseID := c.Param("patient_exercise_id")
 
-	return base64.StdEncoding.EncodeToString(result.CiphertextBlob), nil
-}
+		// Keys are generated here; a client that still sends its own would
+		// encrypt with a key we never stored
+		var requestBody struct {
+			AESKey string `json:"aes_key"`
+		}
+		if err := c.ShouldBindJSON(&requestBody); err != nil && !errors.Is(err, io.EOF) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+		if requestBody.AESKey != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "aes_key is no longer accepted, use the key returned by the server"})
+			return
+		}
 
-func unwrapKey(wrappedKey, kmsKeyID string) (string, error) {
-	kmsClient := helpers.GetKMSClient()
+		patientExercise, err := pc.store.PatientExercises.FindByID(ctx, patientExerciseID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Patient exercise not found"})
+			return
+		}
 
-	ciphertextBlob, err := base64.StdEncoding.DecodeString(wrappedKey)
-	if err != nil {
-		log.Printf("Error decoding wrapped key: %v", err)
-		return "", err
-	}
+		// Refuse before handing out an upload URL if the exercise can't be submitted
+		if err := helpers.CheckTransition(patientExercise.Status, models.StatusUploadPending, c.GetString("role")); err != nil {
+			respondTransitionError(c, err, "")
+			return
+		}
 
-	input := &kms.DecryptInput{
-		KeyId:             aws.String(kmsKeyID),
-		CiphertextBlob:    ciphertextBlob,
-		EncryptionContext: nil, // Add encryption context if used during wrapping
-	}
+		// Generate the recording key and wrap it with the master key
+		dataKey, err := helpers.GenerateDataKey(ctx, pc.keys)
+		if err != nil {
+			log.Printf("Error generating data key: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate encryption key"})
+			return
+		}
 
-	result, err := kmsClient.Decrypt(context.Background(), input)
-	if err != nil {
-		log.Printf("Error decrypting key: %v", err)
-		return "", err
-	}
+		// Every upload is a new attempt with its own object and key
+		attempt, err := helpers.NewRecordingAttempt(ctx, pc.store, patientExerciseID, "mp4")
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while creating recording attempt"})
+			return
+		}
+		attempt.WrappedKey = dataKey.Wrapped
+		attempt.Encryption = &dataKey.Encryption
 
-	return string(result.Plaintext), nil
+		// Generate signed URL with necessary headers
+		expiresIn := pc.storage.UploadURLTTL()
+		presignedURL, err := pc.storage.PresignPut(ctx, attempt.ObjectKey, helpers.RecordingContentType, expiresIn)
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate signed URL"})
+			return
+		}
+
+		if err := pc.store.Attempts.Insert(ctx, attempt); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while creating recording attempt"})
+			return
+		}
+
+		// Wait for the client to confirm the upload
+		expiresAt := time.Now().UTC().Add(expiresIn)
+		err = helpers.TransitionPatientExercise(ctx, pc.store, patientExercise, models.StatusUploadPending,
+			c.GetString("user_id"), c.GetString("role"),
+			repositories.PatientExerciseUpdate{CurrentAttemptID: &attempt.AttemptID, UploadExpiresAt: &expiresAt},
+		)
+		if err != nil {
+			helpers.ExpireAttempt(ctx, pc.store, attempt.AttemptID)
+			respondTransitionError(c, err, "Failed to update patient exercise with recording attempt")
+			return
+		}
+
+		// A new upload URL replaces one that was still pending
+		if patientExercise.Status == models.StatusUploadPending && patientExercise.CurrentAttemptID != "" {
+			if err := helpers.ExpireAttempt(ctx, pc.store, patientExercise.CurrentAttemptID); err != nil {
+				log.Printf("Error expiring recording attempt %s: %v", patientExercise.CurrentAttemptID, err)
+			}
+		}
+
+		if err := pc.logKeyRelease(ctx, c, patientExercise, attempt.AttemptID, "upload"); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record key access"})
+			return
+		}
+
+		// The plaintext key is only ever returned here
+		c.JSON(http.StatusOK, gin.H{
+			"upload_url":   presignedURL,
+			"aes_key":      base64.StdEncoding.EncodeToString(dataKey.Plaintext),
+			"encryption":   dataKey.Encryption,
+			"attempt_id":   attempt.AttemptID,
+			"content_type": helpers.RecordingContentType,
+			"expires_at":   expiresAt,
+		})
+	}
 }
 
-func RecordingPresignPost() gin.HandlerFunc {
+// ConfirmRecordingUpload is called by the client once its upload to the
+// presigned URL finished. The recording is only submitted once the object is
+// verified to be in the bucket.
+func (pc *PatientExerciseController) ConfirmRecordingUpload() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		s3Client := helpers.GetS3Client()
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
 
 		patientExerciseID := c.Param("patient_exercise_id")
 
-		type RequestBody struct {
-			AESKey string `json:"aes_key"`
+		// The recording's duration can't be read from the encrypted object,
+		// so the client reports it
+		var requestBody struct {
+			DurationMs int64 `json:"duration_ms" validate:"min=0"`
 		}
-
-		var requestBody RequestBody
-		if err := c.BindJSON(&requestBody); err != nil {
+		if err := c.ShouldBindJSON(&requestBody); err != nil && !errors.Is(err, io.EOF) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
 			return
 		}
+		if err := validate.Struct(requestBody); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 
-		// Wrap the AES key using AWS KMS
-		kmsKeyID := os.Getenv("KMS_KEY_ID")
-		wrappedKey, err := wrapKey(requestBody.AESKey, kmsKeyID)
+		patientExercise, err := pc.store.PatientExercises.FindByID(ctx, patientExerciseID)
+		if err == repositories.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Patient exercise not found"})
+			return
+		}
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to wrap encryption key"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while fetching patient exercise"})
 			return
 		}
 
-		// Generate signed URL with necessary headers
-		presignClient := s3.NewPresignClient(s3Client)
-
-		presignedURL, err := presignClient.PresignPutObject(ctx, &s3.PutObjectInput{
-			Bucket: aws.String("peakspeak"),
-			Key:    aws.String(fmt.Sprintf("recordings/%s.mp4", patientExerciseID)),
-		}, s3.WithPresignExpires(15*time.Minute))
+		if patientExercise.Status != models.StatusUploadPending || patientExercise.CurrentAttemptID == "" {
+			c.JSON(http.StatusConflict, gin.H{"error": "No upload is pending for this patient exercise"})
+			return
+		}
 
+		attempt, err := pc.store.Attempts.FindByID(ctx, patientExercise.CurrentAttemptID)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate signed URL"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while fetching recording attempt"})
 			return
 		}
 
-		// Store the wrapped key and other metadata
-		updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
-		update := bson.D{
-			{Key: "$set", Value: bson.D{
-				{Key: "wrapped_key", Value: wrappedKey},
-				{Key: "updated_at", Value: updatedAt},
-				{Key: "status", Value: "completed"},
-			}},
+		info, err := helpers.VerifyRecordingUpload(ctx, pc.storage, attempt.ObjectKey)
+		if errors.Is(err, helpers.ErrObjectNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Recording has not been uploaded"})
+			return
+		}
+		if errors.Is(err, helpers.ErrInvalidUpload) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify uploaded recording"})
+			return
 		}
 
-		upsert := true
-
-		opt := options.UpdateOptions{
-			Upsert: &upsert,
+		uploaded := models.AttemptUploaded
+		uploadedAt := time.Now().UTC()
+		err = pc.store.Attempts.Update(ctx, attempt.AttemptID, repositories.RecordingAttemptUpdate{
+			Status:     &uploaded,
+			Size:       &info.Size,
+			DurationMs: &requestBody.DurationMs,
+			UploadedAt: &uploadedAt,
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while updating recording attempt"})
+			return
 		}
-		_, err = patientExerciseCollection.UpdateOne(
-			ctx,
-			bson.M{"patient_exercise_id": patientExerciseID},
-			update,
-			&opt,
+
+		err = helpers.TransitionPatientExercise(ctx, pc.store, patientExercise, models.StatusSubmitted,
+			c.GetString("user_id"), c.GetString("role"), repositories.PatientExerciseUpdate{},
 		)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update patient exercise with wrapped key"})
+			respondTransitionError(c, err, "Error while updating patient exercise status")
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"upload_url": presignedURL.URL})
+		c.JSON(http.StatusOK, gin.H{"message": "Recording upload confirmed", "attempt_id": attempt.AttemptID, "size": info.Size})
 	}
 }
 
-func GetRecordingPresignURL() gin.HandlerFunc {
+func (pc *PatientExerciseController) GetRecordingPresignURL() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		s3Client := helpers.GetS3Client()
-		kmsKeyID := os.Getenv("KMS_KEY_ID")
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
 
 		patientExerciseID := c.Param("patient_exercise_id")
 
 		// Retrieve the wrapped key and other metadata from your database
-		var patientExercise models.PatientExercise
-		err := patientExerciseCollection.FindOne(ctx, bson.M{"patient_exercise_id": patientExerciseID}).Decode(&patientExercise)
+		patientExercise, err := pc.store.PatientExercises.FindByID(ctx, patientExerciseID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Patient exercise not found"})
 			return
 		}
 
-		// Unwrap the AES key using AWS KMS
-		unwrappedKey, err := unwrapKey(patientExercise.WrappedKey, kmsKeyID)
+		// Serve the latest attempt, or the recording from before attempts existed
+		attempt, err := helpers.LatestUploadedAttempt(ctx, pc.store, patientExerciseID)
 		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while fetching recording attempts"})
+			return
+		}
+		if attempt == nil {
+			attempt = &models.RecordingAttempt{
+				ObjectKey:  helpers.RecordingKey(patientExerciseID),
+				WrappedKey: patientExercise.WrappedKey,
+			}
+		}
+
+		pc.respondWithDownload(ctx, c, patientExercise, attempt)
+	}
+}
+
+// respondWithDownload returns a presigned download URL for the attempt's
+// recording together with its unwrapped AES key, if it was encrypted. The key
+// is only released once the release has been written to the access log.
+func (pc *PatientExerciseController) respondWithDownload(ctx context.Context, c *gin.Context, patientExercise *models.PatientExercise, attempt *models.RecordingAttempt) {
+	response := gin.H{}
+
+	if attempt.WrappedKey != "" {
+		purpose := strings.TrimSpace(c.Query("purpose"))
+		if purpose == "" {
+			purpose = "download"
+		}
+		if len(purpose) > 200 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "purpose must be at most 200 characters"})
+			return
+		}
+
+		// Unwrap the AES key with the master key
+		unwrappedKey, err := pc.keys.UnwrapKey(ctx, attempt.WrappedKey)
+		if err != nil {
+			log.Printf("Error decrypting key: %v", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unwrap encryption key"})
 			return
 		}
 
-		// Generate a pre-signed URL for the GET request
-		presignClient := s3.NewPresignClient(s3Client)
+		if err := pc.logKeyRelease(ctx, c, patientExercise, attempt.AttemptID, purpose); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record key access"})
+			return
+		}
+		if attempt.Encryption != nil {
+			response["aes_key"] = base64.StdEncoding.EncodeToString(unwrappedKey)
+			response["encryption"] = attempt.Encryption
+		} else {
+			// Keys supplied by older clients are returned as they were sent
+			response["aes_key"] = string(unwrappedKey)
+		}
+	}
+
+	// Generate a pre-signed URL for the GET request
+	presignedURL, err := pc.storage.PresignGet(ctx, attempt.ObjectKey, 15*time.Minute)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate signed URL"})
+		return
+	}
+	response["download_url"] = presignedURL
+
+	// Return the pre-signed URL and the unwrapped AES key for downloading and decrypting the video
+	c.JSON(http.StatusOK, response)
+}
+
+// logKeyRelease appends a release of the patient exercise's recording key to
+// the access log.
+func (pc *PatientExerciseController) logKeyRelease(ctx context.Context, c *gin.Context, patientExercise *models.PatientExercise, attemptID string, purpose string) error {
+	entry := &models.KeyAccessLog{
+		ID:                primitive.NewObjectID(),
+		PatientID:         *patientExercise.PatientID,
+		PatientExerciseID: patientExercise.PatientExerciseID,
+		AttemptID:         attemptID,
+		ActorID:           c.GetString("user_id"),
+		ActorRole:         c.GetString("role"),
+		IP:                c.ClientIP(),
+		Purpose:           purpose,
+		At:                time.Now().UTC(),
+	}
+	if err := pc.store.KeyAccessLog.Insert(ctx, entry); err != nil {
+		log.Printf("Error writing key access log: %v", err)
+		return err
+	}
+	return nil
+}
+
+// GetKeyAccessLog lets a therapist see who was given the recording keys of
+// one of their patients, and when.
+func (pc *PatientExerciseController) GetKeyAccessLog() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		recordPerPage, err := strconv.Atoi(c.Query("recordPerPage"))
+		if err != nil || recordPerPage < 1 {
+			recordPerPage = 10
+		}
+
+		page, err := strconv.Atoi(c.Query("page"))
+		if err != nil || page < 1 {
+			page = 1
+		}
 
-		presignedURL, err := presignClient.PresignGetObject(ctx, &s3.GetObjectInput{
-			Bucket: aws.String("peakspeak"),
-			Key:    aws.String(fmt.Sprintf("recordings/%s.mp4", patientExerciseID)),
-		}, s3.WithPresignExpires(15*time.Minute))
+		startIndex := (page - 1) * recordPerPage
 
+		entries, totalCount, err := pc.store.KeyAccessLog.ListByPatient(ctx, c.Param("patient_id"), int64(startIndex), int64(recordPerPage))
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate signed URL"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while fetching key access log"})
 			return
 		}
 
-		// Return the pre-signed URL and the unwrapped AES key for downloading and decrypting the video
 		c.JSON(http.StatusOK, gin.H{
-			"download_url": presignedURL.URL,
-			"aes_key":      unwrappedKey,
+			"total":   totalCount,
+			"entries": entries,
 		})
 	}
 }
 
-func UploadRecording() gin.HandlerFunc {
+// ListRecordingAttempts returns every recording attempt of a patient
+// exercise, oldest first.
+func (pc *PatientExerciseController) ListRecordingAttempts() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		attempts, err := pc.store.Attempts.ListByPatientExercise(ctx, c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while fetching recording attempts"})
+			return
+		}
+
+		c.JSON(http.StatusOK, attempts)
+	}
+}
+
+// GetRecordingAttemptURL returns a download URL and key for one attempt.
+func (pc *PatientExerciseController) GetRecordingAttemptURL() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		attempt, err := pc.store.Attempts.FindByID(ctx, c.Param("attempt_id"))
+		if err == repositories.ErrNotFound || (err == nil && attempt.PatientExerciseID != c.Param("id")) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Recording attempt not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while fetching recording attempt"})
+			return
+		}
+
+		if attempt.Status != models.AttemptUploaded {
+			c.JSON(http.StatusConflict, gin.H{"error": "Recording attempt was not uploaded"})
+			return
+		}
+
+		patientExercise, err := pc.store.PatientExercises.FindByID(ctx, attempt.PatientExerciseID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Patient exercise not found"})
+			return
+		}
+
+		pc.respondWithDownload(ctx, c, patientExercise, attempt)
+	}
+}
+
+func (pc *PatientExerciseController) UploadRecording() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
 
 		patientExerciseID := c.Param("patient_exercise_id")
-		var patientExercise models.PatientExercise
-		err := patientExerciseCollection.FindOne(ctx, bson.M{"patient_exercise_id": patientExerciseID}).Decode(&patientExercise)
+		patientExercise, err := pc.store.PatientExercises.FindByID(ctx, patientExerciseID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Patient exercise not found"})
 			return
 		}
 
+		if err := helpers.CheckTransition(patientExercise.Status, models.StatusSubmitted, c.GetString("role")); err != nil {
+			respondTransitionError(c, err, "")
+			return
+		}
+
 		err = c.Request.ParseMultipartForm(100 << 20) // 100 MB
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to parse multipart form"})
@@ -206,52 +425,74 @@ func UploadRecording() gin.HandlerFunc {
 		}
 		defer file.Close()
 
-		fmt.Printf("Uploaded File: %+v\n", handler.Filename)
-		fmt.Printf("File Size: %+v\n", handler.Size)
-		fmt.Printf("MIME Header: %+v\n", handler.Header)
-
-		fileExtension := strings.Split(handler.Filename, ".")[1]
+		fileExtension, ok := helpers.RecordingExtension(handler.Filename)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Recordings must be mp4, mov, m4a or webm files"})
+			return
+		}
 
-		// Key should be recordings/patientExerciseID.extension
-		key := fmt.Sprintf("recordings/%s.%s", patientExerciseID, fileExtension)
+		// Key should be recordings/patientExerciseID/attemptID.extension
+		attempt, err := helpers.NewRecordingAttempt(ctx, pc.store, patientExerciseID, fileExtension)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while creating recording attempt"})
+			return
+		}
+		key := attempt.ObjectKey
 
 		// Upload the file to S3
-		err = helpers.UploadFileToS3(ctx, helpers.GetS3Client(), "peakspeak", key, file)
+		err = pc.storage.UploadFile(ctx, key, file)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to upload file, %v", err)})
 			return
 		}
 
-		videoURL := fmt.Sprintf("https://peakspeak.nyc3.cdn.digitaloceanspaces.com/recordings/%s.%s", patientExerciseID, fileExtension)
-		updatedAt := time.Now().Format(time.RFC3339)
-		update := bson.D{
-			{Key: "$set", Value: bson.D{
-				{Key: "recording", Value: videoURL},
-				{Key: "updated_at", Value: updatedAt},
-				{Key: "status", Value: "completed"},
-			}},
+		uploadedAt := time.Now().UTC()
+		attempt.Status = models.AttemptUploaded
+		attempt.Size = handler.Size
+		attempt.UploadedAt = &uploadedAt
+		if durationMs, err := strconv.ParseInt(c.Request.FormValue("duration_ms"), 10, 64); err == nil && durationMs > 0 {
+			attempt.DurationMs = durationMs
+		}
+		if err := pc.store.Attempts.Insert(ctx, attempt); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while saving recording attempt"})
+			return
 		}
 
-		_, err = patientExerciseCollection.UpdateOne(ctx, bson.M{"patient_exercise_id": patientExerciseID}, update)
+		videoURL := pc.storage.PublicURL(key)
+		err = helpers.TransitionPatientExercise(ctx, pc.store, patientExercise, models.StatusSubmitted,
+			c.GetString("user_id"), c.GetString("role"),
+			repositories.PatientExerciseUpdate{Recording: &videoURL, CurrentAttemptID: &attempt.AttemptID},
+		)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while updating patient exercise recording"})
+			respondTransitionError(c, err, "Error while updating patient exercise recording")
 			return
 		}
 
-		fmt.Printf("File uploaded to, %s\n", videoURL)
+		// The direct upload replaces a presigned one that was still pending
+		if patientExercise.Status == models.StatusUploadPending && patientExercise.CurrentAttemptID != "" {
+			if err := helpers.ExpireAttempt(ctx, pc.store, patientExercise.CurrentAttemptID); err != nil {
+				log.Printf("Error expiring recording attempt %s: %v", patientExercise.CurrentAttemptID, err)
+			}
+		}
+
+		log.Printf("Uploaded recording attempt %s of patient exercise %s (%d bytes)", attempt.AttemptID, patientExerciseID, handler.Size)
 
-		c.JSON(http.StatusOK, gin.H{"message": "File uploaded successfully", "location": videoURL})
+		c.JSON(http.StatusOK, gin.H{"message": "File uploaded successfully", "location": videoURL, "attempt_id": attempt.AttemptID})
 	}
 }
 
-func GetPatientExercise() gin.HandlerFunc {
+func (pc *PatientExerciseController) GetPatientExercise() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
-		patientExerciseID := c.Param("patient_exercise_id")
-		var patientExercise models.PatientExercise
-
-		err := patientExerciseCollection.FindOne(ctx, bson.M{"patient_exercise_id": patientExerciseID}).Decode(&patientExercise)
 		defer cancel()
+
+		patientExerciseID := c.Param("id")
+
+		patientExercise, err := pc.store.PatientExercises.FindByID(ctx, patientExerciseID)
+		if err == repositories.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Patient exercise not found"})
+			return
+		}
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while fetching exercise"})
 			return
@@ -260,7 +501,7 @@ func GetPatientExercise() gin.HandlerFunc {
 	}
 }
 
-func GetPatientExercisesByUser() gin.HandlerFunc {
+func (pc *PatientExerciseController) GetPatientExercisesByUser() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
@@ -272,42 +513,57 @@ func GetPatientExercisesByUser() gin.HandlerFunc {
 			return
 		}
 
+		// "today" is taken in the caller's time zone when one is given
+		location := time.UTC
+		if tz := c.Query("tz"); tz != "" {
+			loaded, err := time.LoadLocation(tz)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid time zone"})
+				return
+			}
+			location = loaded
+		}
+		today := helpers.StartOfDay(time.Now().In(location))
+
+		view := c.Query("view")
+		if view != "" && view != viewDueToday && view != viewOverdue && view != viewUpcoming {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "View must be due_today, overdue or upcoming"})
+			return
+		}
+
+		// Make sure recurring schedules have their occurrences generated
+		through := time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.UTC).Add(helpers.ScheduleHorizon)
+		err := helpers.GenerateScheduledExercises(ctx, pc.store, patientID, through, models.StatusAssigned)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while generating scheduled exercises"})
+			return
+		}
+
 		// Find all patient exercises for the given patient ID
-		filter := bson.M{"patient_id": patientID}
-		result, err := patientExerciseCollection.Find(ctx, filter)
+		patientExercises, err := pc.store.PatientExercises.ListByPatient(ctx, patientID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while fetching patient exercises"})
 			return
 		}
 
-		var patientExercises []bson.M
-		if err = result.All(ctx, &patientExercises); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while decoding patient exercises"})
-			return
+		if view != "" {
+			patientExercises = filterByDueView(patientExercises, view, today)
 		}
 
 		// Fetch exercise details for each patient exercise
-		var detailedExercises []bson.M
+		detailedExercises := []gin.H{}
 		for _, patientExercise := range patientExercises {
-			exerciseID := patientExercise["exercise_id"].(string)
-			var exercise bson.M
-			err = exerciseCollection.FindOne(ctx, bson.M{"exercise_id": exerciseID}).Decode(&exercise)
+			exercise, err := pc.store.Exercises.FindByID(ctx, *patientExercise.ExerciseID)
 			if err != nil {
 				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while fetching exercise details"})
 				return
 			}
 
-			// Ensure the exercise has an ID field
-			exerciseIDField, exists := exercise["_id"]
-			if !exists {
-				exerciseIDField = exerciseID
-			}
-
-			detailedExercise := bson.M{
-				"id":               patientExercise["_id"],
+			detailedExercise := gin.H{
+				"id":               patientExercise.ID,
 				"patient_exercise": patientExercise,
 				"exercise":         exercise,
-				"exercise_id":      exerciseIDField,
+				"exercise_id":      exercise.ID,
 			}
 			detailedExercises = append(detailedExercises, detailedExercise)
 		}
@@ -316,81 +572,49 @@ func GetPatientExercisesByUser() gin.HandlerFunc {
 	}
 }
 
-func ExercisesByPatient(id string) (PatientExercises []primitive.M, err error) {
-	var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
-
-	matchStage := bson.D{{Key: "$match", Value: bson.D{{Key: "patient_exercise_id", Value: id}}}}
-	lookupStage := bson.D{{Key: "$lookup", Value: bson.D{{Key: "from", Value: "patient"}, {Key: "localField", Value: "patient_id"}, {Key: "foreignField", Value: "patient_id"}, {Key: "as", Value: "patient"}}}}
-	unwindStage := bson.D{{Key: "$unwind", Value: bson.D{{Key: "path", Value: "$patient"}, {Key: "preserveNullAndEmptyArrays", Value: true}}}}
-
-	lookupPatientExerciseStage := bson.D{{Key: "$lookup", Value: bson.D{{Key: "from", Value: "patient_exercise"}, {Key: "localField", Value: "patient_exercise_id"}, {Key: "foreignField", Value: "patient_exercise_id"}, {Key: "as", Value: "patient_exercise"}}}}
-	unwindPatientExerciseStage := bson.D{{Key: "$unwind", Value: bson.D{{Key: "path", Value: "$patient_exercise"}, {Key: "preserveNullAndEmptyArrays", Value: true}}}}
-
-	lookupPatientStage := bson.D{{Key: "$lookup", Value: bson.D{{Key: "from", Value: "user"}, {Key: "localField", Value: "patient_exercise.patient_id"}, {Key: "foreignField", Value: "user_id"}, {Key: "as", Value: "patient"}}}}
-	unwindPatientStage := bson.D{{Key: "$unwind", Value: bson.D{{Key: "path", Value: "$patient"}, {Key: "preserveNullAndEmptyArrays", Value: true}}}}
-
-	lookupTherapistStage := bson.D{{Key: "$lookup", Value: bson.D{{Key: "from", Value: "user"}, {Key: "localField", Value: "patient_exercise.therapist_id"}, {Key: "foreignField", Value: "user_id"}, {Key: "as", Value: "therapist"}}}}
-	unwindTherapistStage := bson.D{{Key: "$unwind", Value: bson.D{{Key: "path", Value: "$therapist"}, {Key: "preserveNullAndEmptyArrays", Value: true}}}}
-
-	lookupExerciseStage := bson.D{{Key: "$lookup", Value: bson.D{{Key: "from", Value: "exercise"}, {Key: "localField", Value: "patient_exercise.exercise_id"}, {Key: "foreignField", Value: "exercise_id"}, {Key: "as", Value: "exercise"}}}}
-	unwindExerciseStage := bson.D{{Key: "$unwind", Value: bson.D{{Key: "path", Value: "$exercise"}, {Key: "preserveNullAndEmptyArrays", Value: true}}}}
-
-	projectStage := bson.D{
-		{Key: "$project", Value: bson.D{
-			{Key: "_id", Value: 0},
-			{Key: "exercise_name", Value: "$exercise.name"},
-			{Key: "exercise_description", Value: "$exercise.description"},
-			{Key: "exercise_video_url", Value: "$exercise.video_url"},
-			{Key: "patient_id", Value: "$patient.user_id"},
-			{Key: "patient_name", Value: "$patient.name"},
-			{Key: "therapist_id", Value: "$therapist.user_id"},
-			{Key: "therapist_name", Value: "$therapist.name"},
-			{Key: "patient_exercise_id", Value: "$patient_exercise.patient_exercise_id"},
-			{Key: "patient_exercise_status", Value: "$patient_exercise.status"},
-		}}}
-
-	groupStage := bson.D{{Key: "$group", Value: bson.D{{Key: "_id", Value: bson.D{{Key: "patient_exercise_id", Value: "$patient_exercise_id"}, {Key: "patient_id", Value: "$patient_id"}, {Key: "therapist_id", Value: "$therapist_id"}, {Key: "exercise_id", Value: "$exercise_id"}}}, {Key: "exercises", Value: bson.D{{Key: "$push", Value: "$$ROOT"}}}}}}
-
-	projectStage2 := bson.D{
-		{Key: "$project", Value: bson.D{
-			{Key: "id", Value: 0},
-			{Key: "patient_exercise_id", Value: "$_id.patient_exercise_id"},
-			{Key: "patient_id", Value: "$_id.patient_id"},
-			{Key: "therapist_id", Value: "$_id.therapist_id"},
-			{Key: "exercise_id", Value: "$_id.exercise_id"},
-			{Key: "exercises", Value: 1},
-		}}}
-
-	result, err := patientExerciseCollection.Aggregate(ctx, mongo.Pipeline{
-		matchStage,
-		lookupStage,
-		unwindStage,
-		lookupPatientExerciseStage,
-		unwindPatientExerciseStage,
-		lookupPatientStage,
-		unwindPatientStage,
-		lookupTherapistStage,
-		unwindTherapistStage,
-		lookupExerciseStage,
-		unwindExerciseStage,
-		projectStage,
-		groupStage,
-		projectStage2,
-	})
+const (
+	viewDueToday = "due_today"
+	viewOverdue  = "overdue"
+	viewUpcoming = "upcoming"
+)
 
-	if err != nil {
-		panic(err)
-	}
+// filterByDueView keeps the dated patient exercises that belong in the view,
+// ordered by due date. Due dates are calendar days, so they are compared
+// against today's date in the caller's time zone.
+func filterByDueView(patientExercises []models.PatientExercise, view string, today time.Time) []models.PatientExercise {
+	todayDate := time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.UTC)
 
-	if err = result.All(ctx, &PatientExercises); err != nil {
-		panic(err)
+	filtered := []models.PatientExercise{}
+	for _, patientExercise := range patientExercises {
+		if patientExercise.DueDate == nil {
+			continue
+		}
+		dueDate := patientExercise.DueDate.UTC()
+		open := helpers.IsOpenStatus(patientExercise.Status)
+
+		switch view {
+		case viewDueToday:
+			if dueDate.Equal(todayDate) {
+				filtered = append(filtered, patientExercise)
+			}
+		case viewOverdue:
+			if open && dueDate.Before(todayDate) {
+				filtered = append(filtered, patientExercise)
+			}
+		case viewUpcoming:
+			if dueDate.After(todayDate) {
+				filtered = append(filtered, patientExercise)
+			}
+		}
 	}
 
-	defer cancel()
-	return PatientExercises, err
+	sort.SliceStable(filtered, func(i, j int) bool {
+		return filtered[i].DueDate.Before(*filtered[j].DueDate)
+	})
+	return filtered
 }
 
-func CreatePatientExercise() gin.HandlerFunc {
+func (pc *PatientExerciseController) CreatePatientExercise() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
@@ -401,9 +625,6 @@ func CreatePatientExercise() gin.HandlerFunc {
 			ExerciseIDs []string `json:"exercise_ids" validate:"required,dive,required"`
 		}
 
-		// var patient models.User
-		// var therapist models.User
-
 		if err := c.BindJSON(&requestBody); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
@@ -415,70 +636,31 @@ func CreatePatientExercise() gin.HandlerFunc {
 			return
 		}
 
-		// Fetch therapist and patient information
-		var users []models.User
-		userCursor, userErr := userCollection.Find(ctx, bson.M{
-			"user_id": bson.M{"$in": []string{requestBody.TherapistID, requestBody.PatientID}},
-		})
-		if userErr != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching user data"})
-			return
-		}
-		defer userCursor.Close(ctx)
-		if err := userCursor.All(ctx, &users); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error decoding user data"})
-			return
-		}
-
-		var therapist, patient *models.User
-		for _, user := range users {
-			if user.UserID == requestBody.TherapistID {
-				therapist = &user
-			}
-			if user.UserID == requestBody.PatientID {
-				patient = &user
-			}
-		}
-
-		if therapist == nil || patient == nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Therapist or Patient not found"})
+		if !authorizeAssignment(ctx, c, pc.store, requestBody.TherapistID, requestBody.PatientID) {
 			return
 		}
 
-		// Check if the therapist has the role of 'therapist'
-		if therapist.Role != "therapist" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Only users with the role of therapist can create patient exercises"})
-			return
-		}
-
-		// Validate Patient ID
-		// patientErr := userCollection.FindOne(ctx, bson.M{"user_id": requestBody.PatientID}).Decode(&patient)
-		// if patientErr != nil {
-		// 	msg := fmt.Sprintf("Patient with ID %s not found", *patientExercise.PatientID)
-		// 	c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
-		// 	return
-		// }
-
 		// Fetch exercises
-		var exercises []models.Exercise
-		exerciseCursor, exerciseErr := exerciseCollection.Find(ctx, bson.M{"exercise_id": bson.M{"$in": requestBody.ExerciseIDs}})
+		exercises, exerciseErr := pc.store.Exercises.FindByIDs(ctx, requestBody.ExerciseIDs)
 		if exerciseErr != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching exercise data"})
 			return
 		}
-		defer exerciseCursor.Close(ctx)
-		if err := exerciseCursor.All(ctx, &exercises); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error decoding exercise data"})
-			return
-		}
 
 		if len(exercises) != len(requestBody.ExerciseIDs) {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Some exercises not found"})
 			return
 		}
+		for _, exercise := range exercises {
+			if exercise.ArchivedAt != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Exercise %s is archived", exercise.ExerciseID)})
+				return
+			}
+		}
 
 		// Create Patient Exercise documents
-		var patientExercises []interface{}
+		var patientExercises []models.PatientExercise
+		var insertedIDs []primitive.ObjectID
 		for _, exerciseID := range requestBody.ExerciseIDs {
 			created_at, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 			updated_at, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
@@ -487,31 +669,110 @@ func CreatePatientExercise() gin.HandlerFunc {
 				PatientID:   &requestBody.PatientID,
 				TherapistID: &requestBody.TherapistID,
 				ExerciseID:  &exerciseID,
-				Status:      "pending",
+				Status:      models.StatusAssigned,
 				Recording:   "",
 				CreatedAt:   created_at,
 				UpdatedAt:   updated_at,
 			}
 			patientExercise.PatientExerciseID = patientExercise.ID.Hex()
 			patientExercises = append(patientExercises, patientExercise)
+			insertedIDs = append(insertedIDs, patientExercise.ID)
 		}
 
-		insertResult, insertErr := patientExerciseCollection.InsertMany(ctx, patientExercises)
+		insertErr := pc.store.PatientExercises.InsertMany(ctx, patientExercises)
 		if insertErr != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error inserting patient exercises"})
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"inserted_ids": insertResult.InsertedIDs})
+		c.JSON(http.StatusOK, gin.H{"inserted_ids": insertedIDs})
 	}
 }
 
-func UpdatePatientExercise() gin.HandlerFunc {
+// respondTransitionError writes the response for a failed status transition:
+// 409 for moves the lifecycle doesn't allow or that lost a race, 404 for a
+// missing patient exercise and 500 with msg otherwise.
+func respondTransitionError(c *gin.Context, err error, msg string) {
+	switch {
+	case errors.Is(err, helpers.ErrIllegalTransition):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, repositories.ErrConflict):
+		c.JSON(http.StatusConflict, gin.H{"error": "Patient exercise status changed, please retry"})
+	case errors.Is(err, repositories.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Patient exercise not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+	}
+}
+
+// authorizeAssignment checks that the therapist exists and is the caller (or
+// the caller is an admin) and that they are on the patient's care team in a
+// role that may assign exercises. It writes the error response and returns
+// false otherwise.
+func authorizeAssignment(ctx context.Context, c *gin.Context, store *repositories.Store, therapistID string, patientID string) bool {
+	// Fetch therapist and patient information
+	users, userErr := store.Users.FindByIDs(ctx, []string{therapistID, patientID})
+	if userErr != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching user data"})
+		return false
+	}
+
+	var therapist, patient *models.User
+	for _, user := range users {
+		if user.UserID == therapistID {
+			therapist = &user
+		}
+		if user.UserID == patientID {
+			patient = &user
+		}
+	}
+
+	if therapist == nil || patient == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Therapist or Patient not found"})
+		return false
+	}
+
+	// Check if the therapist has the role of 'therapist'
+	if therapist.Role != helpers.RoleTherapist {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Only users with the role of therapist can create patient exercises"})
+		return false
+	}
+
+	// Therapists can only assign exercises as themselves, to their own patients
+	if err := helpers.MatchUserTypeToUid(c, therapist.UserID); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return false
+	}
+	if patient.Role != helpers.RolePatient {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Patient is not linked to this therapist"})
+		return false
+	}
+	relationship, err := helpers.ActiveCareRelationship(ctx, store, therapist.UserID, patient.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching care team"})
+		return false
+	}
+	if relationship == nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Patient is not linked to this therapist"})
+		return false
+	}
+	if !helpers.CareRoleAllows(relationship.Role, helpers.CareCapabilityAssign) {
+		c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("A %s on the care team cannot assign exercises", relationship.Role)})
+		return false
+	}
+
+	return true
+}
+
+// UpdatePatientExercise changes the status of a patient exercise. Work is
+// only submitted by uploading a recording, so neither upload_pending nor
+// submitted nor the recording can be set here.
+func (pc *PatientExerciseController) UpdatePatientExercise() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
 
-		patientExerciseID := c.Param("patient_exercise_id")
+		patientExerciseID := c.Param("id")
 		if patientExerciseID == "" {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Patient exercise ID is required"})
 			return
@@ -523,69 +784,60 @@ func UpdatePatientExercise() gin.HandlerFunc {
 			return
 		}
 
-		var updateObj primitive.D
-
-		if patientExercise.Status != "" {
-			updateObj = append(updateObj, bson.E{Key: "status", Value: patientExercise.Status})
-		}
-
 		if patientExercise.Recording != "" {
-			updateObj = append(updateObj, bson.E{Key: "recording", Value: patientExercise.Recording})
+			c.JSON(http.StatusBadRequest, gin.H{"error": "The recording can only be set by uploading it"})
+			return
 		}
-
-		patientExercise.UpdatedAt = time.Now()
-		updateObj = append(updateObj, bson.E{Key: "updated_at", Value: patientExercise.UpdatedAt})
-
-		filter := bson.M{"patient_exercise_id": patientExerciseID}
-		update := bson.D{
-			{Key: "$set", Value: updateObj},
+		if patientExercise.Status == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Status is required"})
+			return
 		}
 
-		result, err := patientExerciseCollection.UpdateOne(ctx, filter, update)
+		current, err := pc.store.PatientExercises.FindByID(ctx, patientExerciseID)
+		if err == repositories.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Patient exercise not found"})
+			return
+		}
 		if err != nil {
-			msg := fmt.Sprintf("Error while updating patient exercise: %s", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while fetching patient exercise"})
 			return
 		}
 
-		if result.MatchedCount == 0 {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Patient exercise not found"})
+		// Status changes go through the lifecycle rules
+		if err := helpers.CheckManualTransition(current.Status, patientExercise.Status, c.GetString("role")); err != nil {
+			respondTransitionError(c, err, "")
+			return
+		}
+		err = helpers.TransitionPatientExercise(ctx, pc.store, current, patientExercise.Status,
+			c.GetString("user_id"), c.GetString("role"), repositories.PatientExerciseUpdate{})
+		if err != nil {
+			respondTransitionError(c, err, "Error while updating patient exercise")
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"message": "Patient exercise updated successfully", "result": result})
+		c.JSON(http.StatusOK, gin.H{"message": "Patient exercise updated successfully"})
 	}
 }
 
-func DeletePatientExercise() gin.HandlerFunc {
+func (pc *PatientExerciseController) DeletePatientExercise() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
-		patientExerciseID := c.Param("id")
-		// turn patientExerciseID into an ObjectID
-		objID, err := primitive.ObjectIDFromHex(patientExerciseID)
 		defer cancel()
-		if err != nil {
-			msg := fmt.Sprintf("Object ID %s is invalid", patientExerciseID)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
-			return
-		}
+
+		patientExerciseID := c.Param("id")
 
 		// delete the patient exercise
-		_, err = patientExerciseCollection.DeleteOne(ctx, bson.M{"_id": objID})
+		err := pc.store.PatientExercises.Delete(ctx, patientExerciseID)
+		if err == repositories.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Patient exercise not found"})
+			return
+		}
 		if err != nil {
 			msg := fmt.Sprintf("Error while deleting patient exercise: %s", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
 			return
 		}
 
-		// _, err := patientExerciseCollection.DeleteOne(ctx, bson.M{"_id": objID})
-		// defer cancel()
-		// if err != nil {
-		// 	msg := fmt.Sprintf("Error while deleting exercise: %s", err)
-		// 	c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
-		// 	return
-		// }
-
 		c.JSON(http.StatusOK, gin.H{"message": "Deleted exercise successfully"})
 	}
 }
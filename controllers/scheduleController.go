@@ -0,0 +1,186 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"golang-speakbackend/config"
+	"golang-speakbackend/helpers"
+	"golang-speakbackend/models"
+	"golang-speakbackend/repositories"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type ScheduleController struct {
+	store    *repositories.Store
+	schedule config.ScheduleConfig
+}
+
+func NewScheduleController(store *repositories.Store, schedule config.ScheduleConfig) *ScheduleController {
+	return &ScheduleController{store: store, schedule: schedule}
+}
+
+func (sc *ScheduleController) CreateSchedule() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		var schedule models.AssignmentSchedule
+		if err := c.BindJSON(&schedule); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		validationError := validate.Struct(schedule)
+		if validationError != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationError.Error()})
+			return
+		}
+
+		if schedule.Frequency == helpers.FrequencyWeekly && len(schedule.DaysOfWeek) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Weekly schedules need at least one day of week"})
+			return
+		}
+
+		if !authorizeAssignment(ctx, c, sc.store, *schedule.TherapistID, *schedule.PatientID) {
+			return
+		}
+
+		exercise, err := sc.store.Exercises.FindByID(ctx, *schedule.ExerciseID)
+		if err == repositories.ErrNotFound {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Exercise not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching exercise data"})
+			return
+		}
+		if exercise.ArchivedAt != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Exercise is archived"})
+			return
+		}
+
+		schedule.AuthoredBy = ""
+
+		// Schedules work on whole calendar days
+		schedule.StartDate = helpers.StartOfDay(schedule.StartDate.UTC())
+		schedule.EndDate = helpers.StartOfDay(schedule.EndDate.UTC())
+
+		// Past occurrences are generated right away, so keep their number bounded
+		today := helpers.StartOfDay(time.Now().UTC())
+		if schedule.StartDate.Before(today.Add(-sc.schedule.MaxBackdate.Duration)) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Start date can be at most %s in the past", sc.schedule.MaxBackdate.Duration)})
+			return
+		}
+		if schedule.EndDate.Sub(schedule.StartDate) > sc.schedule.MaxLength.Duration {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Schedule can run for at most %s", sc.schedule.MaxLength.Duration)})
+			return
+		}
+
+		schedule.GeneratedThrough = schedule.StartDate.AddDate(0, 0, -1)
+
+		schedule.CreatedAt, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		schedule.UpdatedAt, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		schedule.ID = primitive.NewObjectID()
+		schedule.ScheduleID = schedule.ID.Hex()
+
+		if err := sc.store.Schedules.Insert(ctx, &schedule); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error inserting schedule"})
+			return
+		}
+
+		// Generate the first occurrences right away so they show up immediately
+		through := helpers.StartOfDay(time.Now().UTC()).Add(helpers.ScheduleHorizon)
+		err = helpers.GenerateScheduledExercises(ctx, sc.store, *schedule.PatientID, through, models.StatusAssigned)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while generating scheduled exercises"})
+			return
+		}
+
+		c.JSON(http.StatusOK, schedule)
+	}
+}
+
+func (sc *ScheduleController) GetSchedule() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		schedule, err := sc.store.Schedules.FindByID(ctx, c.Param("schedule_id"))
+		if err == repositories.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Schedule not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while fetching schedule"})
+			return
+		}
+
+		if err := helpers.CanAccessPatient(ctx, c, sc.store, *schedule.PatientID); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, schedule)
+	}
+}
+
+func (sc *ScheduleController) GetSchedulesByPatient() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		schedules, err := sc.store.Schedules.ListByPatient(ctx, c.Param("patient_id"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while fetching schedules"})
+			return
+		}
+
+		c.JSON(http.StatusOK, schedules)
+	}
+}
+
+// EndSchedule stops a schedule as of today. Occurrences after today that
+// have not been started are removed; history is kept.
+func (sc *ScheduleController) EndSchedule() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		scheduleID := c.Param("schedule_id")
+		schedule, err := sc.store.Schedules.FindByID(ctx, scheduleID)
+		if err == repositories.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Schedule not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while fetching schedule"})
+			return
+		}
+
+		// Anyone on the care team who may assign exercises may end it
+		if err := helpers.CanActForPatient(ctx, c, sc.store, *schedule.PatientID, helpers.CareCapabilityAssign); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+
+		today := helpers.StartOfDay(time.Now().UTC())
+		if schedule.EndDate.After(today) {
+			if err := sc.store.Schedules.End(ctx, scheduleID, today); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while ending schedule"})
+				return
+			}
+		}
+
+		err = sc.store.PatientExercises.DeleteBySchedule(ctx, scheduleID, today, models.StatusAssigned)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while removing future occurrences"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Schedule ended successfully"})
+	}
+}
@@ -2,35 +2,45 @@ package controllers
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"golang-speakbackend/database"
+	"golang-speakbackend/config"
 	"golang-speakbackend/helpers"
 	"golang-speakbackend/models"
+	"golang-speakbackend/repositories"
 	"log"
-	"math/rand"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
 	"golang.org/x/crypto/bcrypt"
 )
 
-var userCollection *mongo.Collection = database.OpenCollection(database.Client, "user")
+type UserController struct {
+	store   *repositories.Store
+	tokens  *helpers.TokenMaker
+	storage *helpers.Storage
+	mailer  helpers.Mailer
+	account config.AccountConfig
+	guard   *helpers.LoginGuard
+}
+
+func NewUserController(store *repositories.Store, tokens *helpers.TokenMaker, storage *helpers.Storage, mailer helpers.Mailer, account config.AccountConfig, guard *helpers.LoginGuard) *UserController {
+	return &UserController{store: store, tokens: tokens, storage: storage, mailer: mailer, account: account, guard: guard}
+}
 
-func UploadProfile() gin.HandlerFunc {
+func (uc *UserController) UploadProfile() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
 
 		userID := c.Param("user_id")
-		var user models.User
 
-		err := userCollection.FindOne(ctx, bson.M{"user_id": userID}).Decode(&user)
+		_, err := uc.store.Users.FindByID(ctx, userID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "User not found"})
 			return
@@ -49,13 +59,17 @@ func UploadProfile() gin.HandlerFunc {
 		}
 		defer file.Close()
 
-		fileExtension := strings.Split(handler.Filename, ".")[1]
+		fileExtension, ok := helpers.ProfileImageExtension(handler.Filename)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Profile images must be jpg, png or webp files"})
+			return
+		}
 
 		// Key should be profile/userID.extension
 		key := fmt.Sprintf("profile/%s.%s", userID, fileExtension)
 
 		// Upload the file to S3
-		err = helpers.UploadFileToS3(ctx, helpers.GetS3Client(), "peakspeak", key, file)
+		err = uc.storage.UploadFile(ctx, key, file)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to upload file, %v", err)})
 			return
@@ -65,14 +79,8 @@ func UploadProfile() gin.HandlerFunc {
 		cacheBuster := time.Now().UnixNano()
 
 		// Update the profile image URL in the database
-		profileImageURL := fmt.Sprintf("https://peakspeak.nyc3.cdn.digitaloceanspaces.com/profile/%s.%s?cb=%d", userID, fileExtension, cacheBuster)
-		update := bson.D{
-			{Key: "$set", Value: bson.D{
-				{Key: "profile_image", Value: profileImageURL},
-			}},
-		}
-
-		_, err = userCollection.UpdateOne(ctx, bson.M{"user_id": userID}, update)
+		profileImageURL := fmt.Sprintf("%s?cb=%d", uc.storage.PublicURL(key), cacheBuster)
+		err = uc.store.Users.Update(ctx, userID, repositories.UserUpdate{ProfileImage: &profileImageURL})
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while updating profile image"})
 			return
@@ -82,25 +90,52 @@ func UploadProfile() gin.HandlerFunc {
 	}
 }
 
-func GetUser() gin.HandlerFunc {
+func (uc *UserController) GetUser() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
-		userID := c.Param("user_id")
-
-		var user models.User
+		defer cancel()
 
-		err := userCollection.FindOne(ctx, bson.M{"user_id": userID}).Decode(&user)
+		userID := c.Param("user_id")
 
-		defer cancel()
+		user, err := uc.store.Users.FindByID(ctx, userID)
+		if err == repositories.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing the users"})
+			return
 		}
 
+		// Therapists and admins only get the profile, the user also their
+		// own account settings. Secrets are never returned.
+		if c.GetString("user_id") != user.UserID {
+			c.JSON(http.StatusOK, userProfile(user))
+			return
+		}
+		user.Password = nil
+		user.Token = nil
+		user.RefreshToken = nil
 		c.JSON(http.StatusOK, user)
 	}
 }
 
-func GetUsers() gin.HandlerFunc {
+// userProfile is what other users get to see of an account.
+func userProfile(user *models.User) gin.H {
+	return gin.H{
+		"id":            user.ID,
+		"first_name":    user.FirstName,
+		"last_name":     user.LastName,
+		"email":         user.Email,
+		"role":          user.Role,
+		"profile_image": user.ProfileImage,
+		"created_at":    user.CreatedAt,
+		"updated_at":    user.UpdatedAt,
+		"user_id":       user.UserID,
+	}
+}
+
+func (uc *UserController) GetUsers() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
@@ -117,98 +152,88 @@ func GetUsers() gin.HandlerFunc {
 
 		startIndex := (page - 1) * recordPerPage
 
-		// Match stage
-		matchStage := bson.D{{Key: "$match", Value: bson.D{}}}
-
-		// Count total number of users
-		countStage := bson.D{{Key: "$count", Value: "total"}}
-
-		// Skip and Limit for pagination
-		skipStage := bson.D{{Key: "$skip", Value: startIndex}}
-		limitStage := bson.D{{Key: "$limit", Value: recordPerPage}}
-
-		// Project stage to include necessary fields
-		projectStage := bson.D{
-			{Key: "$project", Value: bson.D{
-				{Key: "_id", Value: 1},
-				{Key: "first_name", Value: 1},
-				{Key: "last_name", Value: 1},
-				{Key: "email", Value: 1},
-				{Key: "role", Value: 1},
-				{Key: "profile_image", Value: 1},
-				{Key: "created_at", Value: 1},
-				{Key: "updated_at", Value: 1},
-				{Key: "user_id", Value: 1},
-			}},
-		}
-
-		// Aggregate total users count separately
-		countResult, err := userCollection.Aggregate(ctx, mongo.Pipeline{matchStage, countStage})
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while counting users"})
-			return
-		}
-		var countData []bson.M
-		if err = countResult.All(ctx, &countData); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while counting users"})
-			return
-		}
-		totalCount := 0
-		if len(countData) > 0 {
-			totalCount = int(countData[0]["total"].(int32)) // Convert int32 to int
-		}
-
-		// Aggregate users with pagination
-		result, err := userCollection.Aggregate(ctx, mongo.Pipeline{matchStage, skipStage, limitStage, projectStage})
+		users, totalCount, err := uc.store.Users.List(ctx, int64(startIndex), int64(recordPerPage))
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while fetching users"})
 			return
 		}
 
-		var users []bson.M
-		if err = result.All(ctx, &users); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while fetching users"})
-			return
+		// Only expose the public profile fields
+		projected := []gin.H{}
+		for _, user := range users {
+			projected = append(projected, gin.H{
+				"_id":           user.ID,
+				"first_name":    user.FirstName,
+				"last_name":     user.LastName,
+				"email":         user.Email,
+				"role":          user.Role,
+				"profile_image": user.ProfileImage,
+				"created_at":    user.CreatedAt,
+				"updated_at":    user.UpdatedAt,
+				"user_id":       user.UserID,
+				"deleted_at":    user.DeletedAt,
+			})
 		}
 
 		response := gin.H{
 			"total": totalCount,
-			"users": users,
+			"users": projected,
 		}
 
 		c.JSON(http.StatusOK, response)
 	}
 }
 
-func SignUp() gin.HandlerFunc {
+func (uc *UserController) SignUp() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
 
-		var user models.User
+		// Only these are up to the client; everything else about a new
+		// account is set here
+		var requestBody struct {
+			FirstName string `json:"first_name" validate:"required,min=2,max=100"`
+			LastName  string `json:"last_name" validate:"required,min=2,max=100"`
+			Email     string `json:"email" validate:"email,required"`
+			Password  string `json:"password" validate:"required,min=6"`
+			Role      string `json:"role"`
+		}
 
-		if err := c.BindJSON(&user); err != nil {
+		if err := c.BindJSON(&requestBody); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
 			return
 		}
 
-		validationErr := validate.Struct(user)
+		validationErr := validate.Struct(requestBody)
 		if validationErr != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
 			return
 		}
 
-		count, err := userCollection.CountDocuments(ctx, bson.M{"email": user.Email})
+		// Admin accounts are never created through the public signup route
+		if requestBody.Role != helpers.RolePatient && requestBody.Role != helpers.RoleTherapist {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Role must be patient or therapist"})
+			return
+		}
+
+		user := models.User{
+			FirstName: &requestBody.FirstName,
+			LastName:  &requestBody.LastName,
+			Email:     &requestBody.Email,
+			Role:      requestBody.Role,
+		}
+
+		exists, err := uc.store.Users.EmailExists(ctx, *user.Email)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
 			return
 		}
-		if count > 0 {
+		if exists {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Email already exists"})
 			return
 		}
 
-		password := HashPassword(*user.Password)
+		password := HashPassword(requestBody.Password)
 		user.Password = &password
 
 		user.CreatedAt, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
@@ -216,80 +241,261 @@ func SignUp() gin.HandlerFunc {
 		user.ID = primitive.NewObjectID()
 		user.UserID = user.ID.Hex()
 
-		token, refreshToken, _ := helpers.GenerateAllTokens(*user.Email, *user.FirstName, *user.LastName, user.UserID)
-		user.Token = &token
-		user.RefreshToken = &refreshToken
-
-		if user.Role == "patient" {
-			user.ReferenceCode = ""
-		} else if user.Role == "therapist" {
-			user.ReferenceCode = generateUniqueReferenceCode(ctx)
-		}
+		// Therapists get their reference code once they verify their email
+		user.AccountStatus = models.AccountUnverified
 
-		result, insertErr := userCollection.InsertOne(ctx, user)
+		insertErr := uc.store.Users.Insert(ctx, &user)
 		if insertErr != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "User could not be created"})
 			return
 		}
 
+		// The account exists either way; the email can be requested again
+		if err := uc.sendVerificationEmail(ctx, &user); err != nil {
+			log.Printf("Error sending verification email to user %s: %v", user.UserID, err)
+		}
+
 		// return status OK and send result back
-		c.JSON(http.StatusOK, result)
+		c.JSON(http.StatusOK, gin.H{"InsertedID": user.ID})
 	}
 }
 
-func generateUniqueReferenceCode(ctx context.Context) string {
-	const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-	var code string
+func (uc *UserController) sendVerificationEmail(ctx context.Context, user *models.User) error {
+	token, err := helpers.IssueOneTimeToken(ctx, uc.store, user.UserID, models.TokenPurposeEmailVerification, uc.account.EmailVerificationTTL.Duration)
+	if err != nil {
+		return err
+	}
 
-	for {
-		code = generateRandomString(8, charset)
-		count, _ := userCollection.CountDocuments(ctx, bson.M{"reference_code": code})
-		if count == 0 {
-			break
+	link := fmt.Sprintf("%s/verify-email?token=%s", strings.TrimRight(uc.account.AppURL, "/"), token)
+	return uc.mailer.Send(ctx, helpers.MailMessage{
+		To:      *user.Email,
+		Subject: "Confirm your PeakSpeak email address",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease confirm your email address with the link below. It expires in %s.\n\n%s\n",
+			*user.FirstName, uc.account.EmailVerificationTTL.Duration, link),
+	})
+}
+
+// VerifyEmail activates the account with a token from the verification
+// email. Therapists get their reference code at this point.
+func (uc *UserController) VerifyEmail() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		var requestBody struct {
+			Token string `json:"token" validate:"required"`
+		}
+		if err := c.BindJSON(&requestBody); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			return
+		}
+		if err := validate.Struct(requestBody); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		token, err := helpers.RedeemOneTimeToken(ctx, uc.store, models.TokenPurposeEmailVerification, requestBody.Token)
+		if err == helpers.ErrInvalidOneTimeToken {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+
+		user, err := uc.store.Users.FindByID(ctx, token.UserID)
+		if err == repositories.ErrNotFound {
+			c.JSON(http.StatusBadRequest, gin.H{"error": helpers.ErrInvalidOneTimeToken.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
 		}
+
+		active := models.AccountActive
+		update := repositories.UserUpdate{AccountStatus: &active}
+		if user.Role == helpers.RoleTherapist && user.ReferenceCode == "" {
+			referenceCode, err := uc.generateUniqueReferenceCode(ctx)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+				return
+			}
+			update.ReferenceCode = &referenceCode
+		}
+
+		if err := uc.store.Users.Update(ctx, user.UserID, update); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while verifying email"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Email verified successfully"})
 	}
-	return code
 }
 
-func generateRandomString(length int, charset string) string {
-	b := make([]byte, length)
-	for i := range b {
-		b[i] = charset[rand.Intn(len(charset))]
+// ResendVerificationEmail sends a new verification link. Like
+// ForgotPassword, it answers the same way for unknown addresses.
+func (uc *UserController) ResendVerificationEmail() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		var requestBody struct {
+			Email string `json:"email" validate:"required,email"`
+		}
+		if err := c.BindJSON(&requestBody); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			return
+		}
+		if err := validate.Struct(requestBody); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		response := gin.H{"message": "If an unverified account exists for this email, a verification link has been sent"}
+
+		user, err := uc.store.Users.FindByEmail(ctx, requestBody.Email)
+		if err == repositories.ErrNotFound || (err == nil && helpers.IsEmailVerified(user)) {
+			c.JSON(http.StatusOK, response)
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+
+		if err := uc.sendVerificationEmail(ctx, user); err != nil {
+			log.Printf("Error sending verification email to user %s: %v", user.UserID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while sending verification email"})
+			return
+		}
+
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+func (uc *UserController) generateUniqueReferenceCode(ctx context.Context) (string, error) {
+	const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+	for {
+		code, err := helpers.RandomString(8, charset)
+		if err != nil {
+			return "", err
+		}
+		exists, err := uc.store.Users.ReferenceCodeExists(ctx, code)
+		if err != nil {
+			return "", err
+		}
+		if !exists {
+			return code, nil
+		}
 	}
-	return string(b)
 }
 
-func Login() gin.HandlerFunc {
+func (uc *UserController) Login() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
 
 		var user models.User
-		var foundUser models.User
 
-		if err := c.BindJSON(&user); err != nil {
+		if err := c.BindJSON(&user); err != nil || user.Email == nil || user.Password == nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
 			return
 		}
 
-		err := userCollection.FindOne(ctx, bson.M{"email": user.Email}).Decode(&foundUser)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Email not found"})
+		ip := c.ClientIP()
+		if respondIfLoginLocked(ctx, c, uc.guard, *user.Email, ip) {
 			return
 		}
 
-		passwordIsValid, msg := VerifyPassword(*user.Password, *foundUser.Password)
-		if !passwordIsValid {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": msg})
+		// Unknown emails and wrong passwords get the same answer, after the
+		// same bcrypt work, so neither reveals whether the account exists
+		foundUser, err := uc.store.Users.FindByEmail(ctx, *user.Email)
+		if err != nil && err != repositories.ErrNotFound {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		passwordHash := dummyPasswordHash
+		if foundUser != nil {
+			passwordHash = *foundUser.Password
+		}
+		if passwordIsValid, _ := VerifyPassword(*user.Password, passwordHash); !passwordIsValid || foundUser == nil {
+			failLogin(ctx, c, uc.guard, *user.Email, ip, invalidCredentialsMessage)
 			return
 		}
 
-		token, refreshToken, _ := helpers.GenerateAllTokens(*foundUser.Email, *foundUser.FirstName, *foundUser.LastName, foundUser.UserID)
-		helpers.UpdateAllTokens(token, refreshToken, foundUser.UserID)
+		// With MFA the password only earns a challenge for the second step,
+		// and the account's failures are only cleared after it
+		if foundUser.MFA.Enabled {
+			challenge, err := uc.tokens.GenerateMFAChallenge(foundUser.UserID, *foundUser.Email)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while generating token"})
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"mfa_required": true, "mfa_token": challenge})
+			return
+		}
 
-		// return status OK and send result back
-		c.JSON(http.StatusOK, foundUser)
+		if err := uc.guard.Succeed(ctx, *user.Email); err != nil {
+			log.Printf("Could not reset failed logins for user %s: %v", foundUser.UserID, err)
+		}
+		respondWithSession(ctx, c, uc.store, uc.tokens, foundUser, helpers.AMRPassword)
+	}
+}
+
+// invalidCredentialsMessage answers both unknown emails and wrong passwords.
+const invalidCredentialsMessage = "Invalid email or password"
+
+// dummyPasswordHash is checked against when the email is unknown, so such
+// logins take as long as a wrong password.
+var dummyPasswordHash = HashPassword("not-a-real-password")
+
+// respondIfLoginLocked answers with 429 and reports true while the email or
+// IP address is locked out.
+func respondIfLoginLocked(ctx context.Context, c *gin.Context, guard *helpers.LoginGuard, email string, ip string) bool {
+	err := guard.Check(ctx, email, ip)
+	if err == nil {
+		return false
+	}
+	var locked *helpers.LoginLockedError
+	if errors.As(err, &locked) {
+		c.Header("Retry-After", strconv.Itoa(int(locked.RetryAfter.Seconds()+1)))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": locked.Error()})
+		return true
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+	return true
+}
+
+// failLogin counts a failed login attempt and answers with 401.
+func failLogin(ctx context.Context, c *gin.Context, guard *helpers.LoginGuard, email string, ip string, msg string) {
+	if err := guard.Fail(ctx, email, ip); err != nil {
+		log.Printf("Could not record failed login from %s: %v", ip, err)
+	}
+	c.JSON(http.StatusUnauthorized, gin.H{"error": msg})
+}
+
+// respondWithSession finishes a login: it starts a session and returns the
+// user together with the session's tokens. Deleted accounts are turned away.
+func respondWithSession(ctx context.Context, c *gin.Context, store *repositories.Store, tokens *helpers.TokenMaker, user *models.User, amr ...string) {
+	if helpers.IsAccountDeleted(user) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "This account has been deleted, use the link in the deletion email to restore it", "purge_after": user.PurgeAfter})
+		return
+	}
+
+	token, refreshToken, err := helpers.StartSession(ctx, store, tokens, user, amr...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while generating token"})
+		return
 	}
+	helpers.UpdateAllTokens(store.Users, token, refreshToken, user.UserID)
+	user.Token = &token
+	user.RefreshToken = &refreshToken
+
+	// return status OK and send result back
+	c.JSON(http.StatusOK, user)
 }
 
 func HashPassword(password string) string {
@@ -311,15 +517,251 @@ func VerifyPassword(userPassword string, providedPassword string) (bool, string)
 	return check, msg
 }
 
-func UpdateUser() gin.HandlerFunc {
+// updatableUserFields are the only fields UpdateUser accepts. Everything else,
+// such as the role, reference code, tokens and IDs, is managed elsewhere.
+var updatableUserFields = map[string]bool{
+	"first_name":       true,
+	"last_name":        true,
+	"email":            true,
+	"password":         true,
+	"current_password": true,
+}
+
+// UpdateUser applies a partial update to the user. Names change directly,
+// validated like on signup. A new password needs the current one and logs
+// out every other session. A new email only takes effect once it is
+// confirmed with a link sent to it.
+func (uc *UserController) UpdateUser() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		body, err := c.GetRawData()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			return
+		}
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(body, &fields); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			return
+		}
+		for field := range fields {
+			if !updatableUserFields[field] {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s cannot be changed", field)})
+				return
+			}
+		}
 
+		var requestBody struct {
+			FirstName       *string `json:"first_name"`
+			LastName        *string `json:"last_name"`
+			Email           *string `json:"email"`
+			Password        *string `json:"password"`
+			CurrentPassword string  `json:"current_password"`
+		}
+		if err := json.Unmarshal(body, &requestBody); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			return
+		}
+
+		userID := c.Param("user_id")
+		user, err := uc.store.Users.FindByID(ctx, userID)
+		if err == repositories.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+
+		// Validate the changed fields against the model's own rules
+		candidate := *user
+		changed := []string{}
+		if requestBody.FirstName != nil {
+			candidate.FirstName = requestBody.FirstName
+			changed = append(changed, "FirstName")
+		}
+		if requestBody.LastName != nil {
+			candidate.LastName = requestBody.LastName
+			changed = append(changed, "LastName")
+		}
+		emailChanged := requestBody.Email != nil && *requestBody.Email != *user.Email
+		if emailChanged {
+			candidate.Email = requestBody.Email
+			changed = append(changed, "Email")
+		}
+		if requestBody.Password != nil {
+			candidate.Password = requestBody.Password
+			changed = append(changed, "Password")
+		}
+		if len(changed) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Nothing to update"})
+			return
+		}
+		if err := validate.StructPartial(candidate, changed...); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		update := repositories.UserUpdate{FirstName: requestBody.FirstName, LastName: requestBody.LastName}
+
+		if requestBody.Password != nil {
+			ip := c.ClientIP()
+			if respondIfLoginLocked(ctx, c, uc.guard, *user.Email, ip) {
+				return
+			}
+			if ok, _ := VerifyPassword(requestBody.CurrentPassword, *user.Password); !ok {
+				failLogin(ctx, c, uc.guard, *user.Email, ip, "Current password is incorrect")
+				return
+			}
+			password := HashPassword(*requestBody.Password)
+			update.Password = &password
+		}
+
+		newEmail := ""
+		if emailChanged {
+			newEmail = *requestBody.Email
+			exists, err := uc.store.Users.EmailExists(ctx, newEmail)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+				return
+			}
+			if exists {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Email already exists"})
+				return
+			}
+			update.PendingEmail = &newEmail
+		}
+
+		if err := uc.store.Users.Update(ctx, userID, update); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while updating user"})
+			return
+		}
+
+		if newEmail != "" {
+			if err := uc.sendEmailChangeEmail(ctx, user, newEmail); err != nil {
+				log.Printf("Error sending email change confirmation to user %s: %v", userID, err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while sending confirmation email"})
+				return
+			}
+		}
+
+		updatedUser, err := uc.store.Users.FindByID(ctx, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+
+		// Other devices have to log in with the new password. A user changing
+		// their own gets a fresh session on this one, an admin changing
+		// someone else's just logs them out.
+		self := c.GetString("user_id") == userID
+		if update.Password != nil {
+			if err := helpers.RevokeAllSessions(ctx, uc.store, userID, models.RevokedPasswordChange); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while revoking sessions"})
+				return
+			}
+			if self {
+				respondWithSession(ctx, c, uc.store, uc.tokens, updatedUser, c.GetStringSlice("amr")...)
+				return
+			}
+		}
+
+		if !self {
+			c.JSON(http.StatusOK, userProfile(updatedUser))
+			return
+		}
+		updatedUser.Password = nil
+		updatedUser.Token = nil
+		updatedUser.RefreshToken = nil
+		c.JSON(http.StatusOK, updatedUser)
+	}
+}
+
+func (uc *UserController) sendEmailChangeEmail(ctx context.Context, user *models.User, newEmail string) error {
+	token, err := helpers.IssueOneTimeToken(ctx, uc.store, user.UserID, models.TokenPurposeEmailChange, uc.account.EmailVerificationTTL.Duration)
+	if err != nil {
+		return err
 	}
+
+	link := fmt.Sprintf("%s/verify-email/change?token=%s", strings.TrimRight(uc.account.AppURL, "/"), token)
+	return uc.mailer.Send(ctx, helpers.MailMessage{
+		To:      newEmail,
+		Subject: "Confirm your new PeakSpeak email address",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease confirm your new email address with the link below. It expires in %s. Until then you keep logging in with %s.\n\n%s\n",
+			*user.FirstName, uc.account.EmailVerificationTTL.Duration, *user.Email, link),
+	})
 }
 
-func DeleteUser() gin.HandlerFunc {
+// ConfirmEmailChange switches the account to its pending email address with
+// a token from the confirmation email.
+func (uc *UserController) ConfirmEmailChange() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		var ctx, cancel = context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		var requestBody struct {
+			Token string `json:"token" validate:"required"`
+		}
+		if err := c.BindJSON(&requestBody); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			return
+		}
+		if err := validate.Struct(requestBody); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		token, err := helpers.RedeemOneTimeToken(ctx, uc.store, models.TokenPurposeEmailChange, requestBody.Token)
+		if err == helpers.ErrInvalidOneTimeToken {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+
+		user, err := uc.store.Users.FindByID(ctx, token.UserID)
+		if err == repositories.ErrNotFound || (err == nil && user.PendingEmail == "") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": helpers.ErrInvalidOneTimeToken.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+
+		// Someone may have signed up with the address in the meantime
+		exists, err := uc.store.Users.EmailExists(ctx, user.PendingEmail)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		if exists {
+			c.JSON(http.StatusConflict, gin.H{"error": "Email already exists"})
+			return
+		}
+
+		email, empty := user.PendingEmail, ""
+		if err := uc.store.Users.Update(ctx, user.UserID, repositories.UserUpdate{Email: &email, PendingEmail: &empty}); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while changing email"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Email changed successfully"})
+	}
+}
+
+// DeleteUser deletes the account. It can be restored with the link mailed to
+// the user until ACCOUNT_DELETION_GRACE is over, after which its data is
+// purged. Therapists can pass ?transfer_to= with another therapist's ID to
+// transfer their patients to them first.
+func (uc *UserController) DeleteUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 60*time.Second)
 		defer cancel()
 
 		userID := c.Param("user_id")
@@ -328,68 +770,203 @@ func DeleteUser() gin.HandlerFunc {
 			return
 		}
 
-		var user models.User
-		err := userCollection.FindOne(ctx, bson.M{"user_id": userID}).Decode(&user)
+		user, err := uc.store.Users.FindByID(ctx, userID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "User not found"})
 			return
 		}
+		if helpers.IsAccountDeleted(user) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Account has already been deleted"})
+			return
+		}
 
-		if user.Role == "patient" {
-			err = deletePatientAndExercises(ctx, user.UserID)
-		} else if user.Role == "therapist" {
-			err = deleteTherapistAndPatients(ctx, user.UserID, user.ReferenceCode)
+		response := gin.H{"message": "User deleted successfully"}
+
+		// A therapist can only leave once nobody is in their care anymore:
+		// they hand their patients over with ?transfer_to= or end the care
+		// relationships they can't transfer first
+		if user.Role == helpers.RoleTherapist {
+			relationships, err := helpers.ActiveCareRelationshipsOfTherapist(ctx, uc.store, user.UserID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while fetching care team"})
+				return
+			}
+			transferTo := c.Query("transfer_to")
+			remaining := 0
+			for _, relationship := range relationships {
+				if transferTo == "" || !canTransferPatient(c, relationship) {
+					remaining++
+				}
+			}
+			if remaining > 0 {
+				c.JSON(http.StatusConflict, gin.H{
+					"error":           "Therapist still has patients in their care, transfer them with ?transfer_to= or end the care relationships first",
+					"active_patients": remaining,
+				})
+				return
+			}
+			if transferTo != "" {
+				transfer, ok := transferCaseload(ctx, c, uc.store, user.UserID, transferTo, nil)
+				if !ok {
+					return
+				}
+				response["transfer"] = transfer
+			}
 		}
 
+		purgeAfter, err := helpers.DeleteAccount(ctx, uc.store, user, c.GetString("user_id"), uc.account.DeletionGrace.Duration)
+		if err == repositories.ErrConflict {
+			c.JSON(http.StatusConflict, gin.H{"error": "Account has already been deleted"})
+			return
+		}
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while deleting user"})
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"message": "User deleted successfully"})
+		empty := ""
+		if err := uc.store.Users.Update(ctx, user.UserID, repositories.UserUpdate{Token: &empty, RefreshToken: &empty}); err != nil {
+			log.Printf("Could not clear tokens for user %s: %v", user.UserID, err)
+		}
+		if err := uc.sendRestoreEmail(ctx, user, purgeAfter); err != nil {
+			log.Printf("Error sending restore email to user %s: %v", user.UserID, err)
+		}
+
+		response["purge_after"] = purgeAfter
+		c.JSON(http.StatusOK, response)
 	}
 }
 
-func deletePatientAndExercises(ctx context.Context, userID string) error {
-	// Delete patient from user collection
-	_, err := userCollection.DeleteOne(ctx, bson.M{"user_id": userID})
-	if err != nil {
-		return err
+func (uc *UserController) sendRestoreEmail(ctx context.Context, user *models.User, purgeAfter time.Time) error {
+	if user.Email == nil {
+		return nil
 	}
-
-	// Delete exercises associated with the patient
-	_, err = patientExerciseCollection.DeleteMany(ctx, bson.M{"patient_id": userID})
+	token, err := helpers.IssueOneTimeToken(ctx, uc.store, user.UserID, models.TokenPurposeAccountRestore, time.Until(purgeAfter))
 	if err != nil {
 		return err
 	}
 
-	return nil
+	link := fmt.Sprintf("%s/restore-account?token=%s", strings.TrimRight(uc.account.AppURL, "/"), token)
+	return uc.mailer.Send(ctx, helpers.MailMessage{
+		To:      *user.Email,
+		Subject: "Your PeakSpeak account has been deleted",
+		Body: fmt.Sprintf("Hi %s,\n\nYour PeakSpeak account has been deleted. Its data will be removed for good on %s. If this was a mistake, restore the account with the link below before then.\n\n%s\n",
+			*user.FirstName, purgeAfter.Format("2 January 2006"), link),
+	})
 }
 
-func deleteTherapistAndPatients(ctx context.Context, therapistID string, referenceCode string) error {
-	// Delete therapist from user collection
-	_, err := userCollection.DeleteOne(ctx, bson.M{"user_id": therapistID})
-	if err != nil {
-		return err
+// RestoreAccount undoes a deletion with the token from the email sent when
+// the account was deleted.
+func (uc *UserController) RestoreAccount() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		var requestBody struct {
+			Token string `json:"token" validate:"required"`
+		}
+		if err := c.BindJSON(&requestBody); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			return
+		}
+		if err := validate.Struct(requestBody); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		token, err := helpers.RedeemOneTimeToken(ctx, uc.store, models.TokenPurposeAccountRestore, requestBody.Token)
+		if err == helpers.ErrInvalidOneTimeToken {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+
+		restoreAccount(ctx, c, uc.store, token.UserID)
 	}
+}
 
-	// Unlink patients associated with the therapist
-    _, err = userCollection.UpdateMany(ctx, bson.M{"reference_code": referenceCode}, bson.M{"$unset": bson.M{"reference_code": ""}})
-	if err != nil {
-		return err
+// RestoreUser lets admins undo a deletion.
+func (uc *UserController) RestoreUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		restoreAccount(ctx, c, uc.store, c.Param("user_id"))
 	}
+}
 
-	// Delete all associated patient exercises
-	_, err = patientExerciseCollection.DeleteMany(ctx, bson.M{"therapist_id": therapistID})
+// restoreAccount restores the deleted account and writes the response.
+func restoreAccount(ctx context.Context, c *gin.Context, store *repositories.Store, userID string) {
+	err := store.Users.Restore(ctx, userID, time.Now().UTC())
+	if err == repositories.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if err == repositories.ErrConflict {
+		c.JSON(http.StatusConflict, gin.H{"error": "Account is not deleted or can no longer be restored"})
+		return
+	}
 	if err != nil {
-		return err
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while restoring account"})
+		return
 	}
 
-	return nil
+	c.JSON(http.StatusOK, gin.H{"message": "Account restored, log in again to continue"})
+}
+
+// GetDeletionCertificates lists the certificates of purged accounts, newest
+// first.
+func (uc *UserController) GetDeletionCertificates() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		recordPerPage, err := strconv.Atoi(c.Query("recordPerPage"))
+		if err != nil || recordPerPage < 1 {
+			recordPerPage = 10
+		}
+
+		page, err := strconv.Atoi(c.Query("page"))
+		if err != nil || page < 1 {
+			page = 1
+		}
+
+		startIndex := (page - 1) * recordPerPage
+
+		certificates, totalCount, err := uc.store.Certificates.List(ctx, int64(startIndex), int64(recordPerPage))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while fetching deletion certificates"})
+			return
+		}
 
+		c.JSON(http.StatusOK, gin.H{"total": totalCount, "certificates": certificates})
+	}
 }
 
-func RefreshToken() gin.HandlerFunc {
+// GetDeletionCertificate returns the certificate of a purged account.
+func (uc *UserController) GetDeletionCertificate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		certificate, err := uc.store.Certificates.FindByUserID(ctx, c.Param("user_id"))
+		if err == repositories.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Deletion certificate not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while fetching deletion certificate"})
+			return
+		}
+
+		c.JSON(http.StatusOK, certificate)
+	}
+}
+
+func (uc *UserController) RefreshToken() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
@@ -404,86 +981,224 @@ func RefreshToken() gin.HandlerFunc {
 			return
 		}
 
-		claims, msg := helpers.ValidateToken(req.RefreshToken)
+		claims, msg := uc.tokens.ValidateToken(req.RefreshToken, helpers.TokenTypeRefresh)
 		if msg != "" {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": msg})
 			return
 		}
 
-		userID := claims.UserID
-		var user models.User
-		err := userCollection.FindOne(ctx, bson.M{"user_id": userID}).Decode(&user)
+		user, err := uc.store.Users.FindByID(ctx, claims.UserID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "User not found"})
 			return
 		}
 
-		token, refreshToken, err := helpers.GenerateAllTokens(*user.Email, *user.FirstName, *user.LastName, user.UserID)
+		// Refresh tokens issued before a password change no longer work
+		if user.PasswordChangedAt != nil && claims.IssuedAt < user.PasswordChangedAt.Unix() {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "the token has been revoked"})
+			return
+		}
+
+		token, refreshToken, err := helpers.RotateSession(ctx, uc.store, uc.tokens, claims, user)
+		if err == helpers.ErrTokenRevoked || err == helpers.ErrTokenReused {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while generating token"})
 			return
 		}
 
-		helpers.UpdateAllTokens(token, refreshToken, user.UserID)
+		helpers.UpdateAllTokens(uc.store.Users, token, refreshToken, user.UserID)
 
 		c.JSON(http.StatusOK, gin.H{"token": token, "refresh_token": refreshToken})
 	}
 }
 
-func LinkToTherapist() gin.HandlerFunc {
+// Logout revokes the session of the access token used for the request, both
+// its access and its refresh token.
+func (uc *UserController) Logout() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
 
-		type RequestBody struct {
-			ReferenceCode string `json:"reference_code" bson:"reference_code" binding:"required"`
+		if err := helpers.RevokeSession(ctx, uc.store, c.GetString("family_id"), models.RevokedLogout); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while logging out"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Logged out"})
+	}
+}
+
+// LogoutAll revokes every session of the user, on every device.
+func (uc *UserController) LogoutAll() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		userID := c.GetString("user_id")
+		if err := helpers.RevokeAllSessions(ctx, uc.store, userID, models.RevokedLogoutAll); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while logging out"})
+			return
 		}
-		var requestBody RequestBody
 
+		empty := ""
+		if err := uc.store.Users.Update(ctx, userID, repositories.UserUpdate{Token: &empty, RefreshToken: &empty}); err != nil && err != repositories.ErrNotFound {
+			log.Printf("Could not clear tokens for user %s: %v", userID, err)
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Logged out on all devices"})
+	}
+}
+
+// ForgotPassword mails a password reset link to the account's address. It
+// answers the same way whether or not the email belongs to an account.
+func (uc *UserController) ForgotPassword() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		var requestBody struct {
+			Email string `json:"email" validate:"required,email"`
+		}
 		if err := c.BindJSON(&requestBody); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
 			return
 		}
+		if err := validate.Struct(requestBody); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 
-		userID := c.Param("user_id")
-		var patient models.User
-		var therapist models.User
+		response := gin.H{"message": "If an account exists for this email, a reset link has been sent"}
 
-		err := userCollection.FindOne(ctx, bson.M{"user_id": userID}).Decode(&patient)
+		user, err := uc.store.Users.FindByEmail(ctx, requestBody.Email)
+		if err == repositories.ErrNotFound {
+			c.JSON(http.StatusOK, response)
+			return
+		}
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "User not found"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
 			return
 		}
 
-		if patient.Role != "patient" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Only patients can link to therapists"})
+		token, err := helpers.IssueOneTimeToken(ctx, uc.store, user.UserID, models.TokenPurposePasswordReset, uc.account.PasswordResetTTL.Duration)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while creating reset token"})
 			return
 		}
 
-		err = userCollection.FindOne(ctx, bson.M{"reference_code": requestBody.ReferenceCode, "role": "therapist"}).Decode(&therapist)
+		link := fmt.Sprintf("%s/reset-password?token=%s", strings.TrimRight(uc.account.AppURL, "/"), token)
+		err = uc.mailer.Send(ctx, helpers.MailMessage{
+			To:      *user.Email,
+			Subject: "Reset your PeakSpeak password",
+			Body: fmt.Sprintf("Hi %s,\n\nUse the link below to choose a new password. It expires in %s and can only be used once.\n\n%s\n\nIf you didn't ask for this, you can ignore this email.\n",
+				*user.FirstName, uc.account.PasswordResetTTL.Duration, link),
+		})
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Therapist not found"})
+			log.Printf("Error sending password reset email to user %s: %v", user.UserID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while sending reset email"})
 			return
 		}
 
-		// add reference code to patient
-		update := bson.D{
-			{Key: "$set", Value: bson.D{
-				{Key: "reference_code", Value: therapist.ReferenceCode},
-			}},
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// ResetPassword sets a new password with a token from ForgotPassword. The
+// token can only be used once, and every session started before the reset
+// is logged out.
+func (uc *UserController) ResetPassword() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		var requestBody struct {
+			Token    string `json:"token" validate:"required"`
+			Password string `json:"password" validate:"required,min=6"`
+		}
+		if err := c.BindJSON(&requestBody); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			return
+		}
+		if err := validate.Struct(requestBody); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		token, err := helpers.RedeemOneTimeToken(ctx, uc.store, models.TokenPurposePasswordReset, requestBody.Token)
+		if err == helpers.ErrInvalidOneTimeToken {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
 		}
 
-		_, err = userCollection.UpdateOne(ctx, bson.M{"user_id": userID}, update)
+		password := HashPassword(requestBody.Password)
+		empty := ""
+		err = uc.store.Users.Update(ctx, token.UserID, repositories.UserUpdate{
+			Password:     &password,
+			Token:        &empty,
+			RefreshToken: &empty,
+		})
+		if err == repositories.ErrNotFound {
+			c.JSON(http.StatusBadRequest, gin.H{"error": helpers.ErrInvalidOneTimeToken.Error()})
+			return
+		}
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while linking to therapist"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while updating password"})
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"message": "Linked to therapist successfully"})
+		if err := helpers.RevokeAllSessions(ctx, uc.store, token.UserID, models.RevokedPasswordReset); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while revoking sessions"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Password has been reset"})
 	}
 }
 
-func GetPatients() gin.HandlerFunc {
+// LinkToTherapist redeems a therapist's reference code or an invite code for
+// the patient, like POST /invites/redeem.
+func (uc *UserController) LinkToTherapist() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		type RequestBody struct {
+			ReferenceCode string `json:"reference_code" bson:"reference_code" binding:"required"`
+		}
+		var requestBody RequestBody
+
+		if err := c.BindJSON(&requestBody); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			return
+		}
+
+		userID := c.Param("user_id")
+
+		patient, err := uc.store.Users.FindByID(ctx, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "User not found"})
+			return
+		}
+
+		if patient.Role != helpers.RolePatient {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Only patients can link to therapists"})
+			return
+		}
+
+		redeemInvite(ctx, c, uc.store, requestBody.ReferenceCode, patient)
+	}
+}
+
+// GetPatients lists the therapist's current caseload. Link requests the
+// therapist hasn't accepted yet and deleted patients are left out.
+func (uc *UserController) GetPatients() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
@@ -494,30 +1209,39 @@ func GetPatients() gin.HandlerFunc {
 			return
 		}
 
-		var therapist models.User
-		err := userCollection.FindOne(ctx, bson.M{"user_id": therapistID}).Decode(&therapist)
+		therapist, err := uc.store.Users.FindByID(ctx, therapistID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Therapist not found"})
 			return
 		}
 
-		if therapist.Role != "therapist" {
+		if therapist.Role != helpers.RoleTherapist {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Only therapists can view patients"})
 			return
 		}
 
-		var patients []models.User
-		cursor, err := userCollection.Find(ctx, bson.M{"reference_code": therapist.ReferenceCode, "role": "patient"})
+		relationships, err := helpers.ActiveCareRelationshipsOfTherapist(ctx, uc.store, therapistID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while fetching patients"})
 			return
 		}
+		patientIDs := []string{}
+		for _, relationship := range relationships {
+			patientIDs = append(patientIDs, relationship.PatientID)
+		}
 
-		defer cursor.Close(ctx)
-		for cursor.Next(ctx) {
-			var patient models.User
-			cursor.Decode(&patient)
-			patients = append(patients, patient)
+		patients := []gin.H{}
+		if len(patientIDs) > 0 {
+			users, err := uc.store.Users.FindByIDs(ctx, patientIDs)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while fetching patients"})
+				return
+			}
+			for i := range users {
+				if !helpers.IsAccountDeleted(&users[i]) {
+					patients = append(patients, userProfile(&users[i]))
+				}
+			}
 		}
 
 		c.JSON(http.StatusOK, patients)
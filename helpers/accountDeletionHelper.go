@@ -0,0 +1,251 @@
+package helpers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"golang-speakbackend/models"
+	"golang-speakbackend/repositories"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// IsAccountDeleted reports whether the account was deleted and is waiting to
+// be purged.
+func IsAccountDeleted(user *models.User) bool {
+	return user.DeletedAt != nil
+}
+
+// DeleteAccount marks the account deleted and logs it out everywhere. Its
+// data stays in place until the grace period is over, so the account can be
+// restored until then. It returns repositories.ErrConflict if the account is
+// already deleted.
+func DeleteAccount(ctx context.Context, store *repositories.Store, user *models.User, deletedBy string, grace time.Duration) (time.Time, error) {
+	now := time.Now().UTC()
+	purgeAfter := now.Add(grace)
+	if err := store.Users.MarkDeleted(ctx, user.UserID, deletedBy, now, purgeAfter); err != nil {
+		return time.Time{}, err
+	}
+	if err := RevokeAllSessions(ctx, store, user.UserID, models.RevokedAccountDeleted); err != nil {
+		return time.Time{}, err
+	}
+	return purgeAfter, nil
+}
+
+// PurgeAccount removes everything a deleted account owns: its profile image,
+// care relationships, sessions and finally the user itself. A patient's
+// recordings, patient exercises with their attempts, wrapped keys and
+// feedback, and schedules go too. A therapist's invites go, but what they
+// assigned and reviewed belongs to the patients and is left in place. The key
+// access log is kept as the audit trail. It returns the deletion certificate
+// recorded in place of the account.
+//
+// Storage objects are removed first and the documents in one transaction at
+// the end, so an interrupted purge is simply run again.
+func PurgeAccount(ctx context.Context, store *repositories.Store, storage *Storage, user *models.User, now time.Time) (*models.DeletionCertificate, error) {
+	certificate := &models.DeletionCertificate{
+		ID:        primitive.NewObjectID(),
+		UserID:    user.UserID,
+		Role:      user.Role,
+		DeletedBy: user.DeletedBy,
+		PurgedAt:  now,
+		Documents: map[string]int{},
+		Objects:   []string{},
+	}
+	certificate.CertificateID = certificate.ID.Hex()
+	if user.Email != nil {
+		certificate.EmailHash = hashEmail(*user.Email)
+	}
+	if user.DeletedAt != nil {
+		certificate.DeletedAt = *user.DeletedAt
+	}
+
+	var (
+		patientExercises []models.PatientExercise
+		schedules        []models.AssignmentSchedule
+		relationships    []models.CareRelationship
+		invites          []models.Invite
+		err              error
+	)
+	if user.Role == RoleTherapist {
+		relationships, err = store.CareTeams.ListByTherapist(ctx, user.UserID)
+		if err == nil {
+			invites, err = store.Invites.ListByTherapist(ctx, user.UserID)
+		}
+	} else {
+		patientExercises, err = store.PatientExercises.ListByPatient(ctx, user.UserID)
+		if err == nil {
+			schedules, err = store.Schedules.ListByPatient(ctx, user.UserID)
+		}
+		if err == nil {
+			relationships, err = store.CareTeams.ListByPatient(ctx, user.UserID)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	patientExerciseIDs := []string{}
+	for _, patientExercise := range patientExercises {
+		patientExerciseIDs = append(patientExerciseIDs, patientExercise.PatientExerciseID)
+		if patientExercise.WrappedKey != "" {
+			certificate.WrappedKeys++
+		}
+
+		attempts, err := store.Attempts.ListByPatientExercise(ctx, patientExercise.PatientExerciseID)
+		if err != nil {
+			return nil, err
+		}
+		for _, attempt := range attempts {
+			if attempt.WrappedKey != "" {
+				certificate.WrappedKeys++
+			}
+		}
+		certificate.Documents["recording_attempt"] += len(attempts)
+
+		feedback, err := store.Feedback.ListByPatientExercise(ctx, patientExercise.PatientExerciseID)
+		if err != nil {
+			return nil, err
+		}
+		certificate.Documents["feedback"] += len(feedback)
+
+		// Matches both the single recording of old and the attempts, since
+		// patient exercise IDs all have the same length
+		keys, err := storage.DeletePrefix(ctx, "recordings/"+patientExercise.PatientExerciseID)
+		certificate.Objects = append(certificate.Objects, keys...)
+		if err != nil {
+			return nil, err
+		}
+	}
+	keys, err := storage.DeletePrefix(ctx, "profile/"+user.UserID+".")
+	certificate.Objects = append(certificate.Objects, keys...)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.Role == RoleTherapist {
+		certificate.Documents["invite"] = len(invites)
+	} else {
+		certificate.Documents["patient_exercise"] = len(patientExercises)
+		certificate.Documents["assignment_schedule"] = len(schedules)
+	}
+	certificate.Documents["care_relationship"] = len(relationships)
+	certificate.Documents["user"] = 1
+
+	err = store.WithTransaction(ctx, func(ctx context.Context) error {
+		if len(patientExerciseIDs) > 0 {
+			if err := store.Attempts.DeleteByPatientExercises(ctx, patientExerciseIDs); err != nil {
+				return err
+			}
+			if err := store.Feedback.DeleteByPatientExercises(ctx, patientExerciseIDs); err != nil {
+				return err
+			}
+		}
+		if user.Role == RoleTherapist {
+			if err := store.Invites.DeleteByTherapist(ctx, user.UserID); err != nil {
+				return err
+			}
+			if user.ReferenceCode != "" {
+				if err := store.Users.UnlinkPatients(ctx, user.ReferenceCode); err != nil {
+					return err
+				}
+			}
+		} else {
+			if err := store.PatientExercises.DeleteByPatient(ctx, user.UserID); err != nil {
+				return err
+			}
+			if err := store.Schedules.DeleteByPatient(ctx, user.UserID); err != nil {
+				return err
+			}
+		}
+		if err := store.CareTeams.DeleteAllForUser(ctx, user.UserID); err != nil {
+			return err
+		}
+		if err := store.TokenFamilies.DeleteAllForUser(ctx, user.UserID); err != nil {
+			return err
+		}
+		if err := store.OneTimeTokens.DeleteAllForUser(ctx, user.UserID); err != nil {
+			return err
+		}
+		if err := store.Certificates.Insert(ctx, certificate); err != nil {
+			return err
+		}
+		return store.Users.Delete(ctx, user.UserID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return certificate, nil
+}
+
+// hashEmail lets a certificate be matched to an email address without
+// storing the address.
+func hashEmail(email string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(email))))
+	return hex.EncodeToString(sum[:])
+}
+
+// PurgeDeletedAccounts purges every account whose grace period ended before
+// now. An account that fails to purge is logged and tried again next time.
+// It returns how many were purged.
+func PurgeDeletedAccounts(ctx context.Context, store *repositories.Store, storage *Storage, now time.Time) (int, error) {
+	users, err := store.Users.ListPurgeable(ctx, now)
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, listed := range users {
+		// Re-read so an account restored since we listed it is left alone
+		user, err := store.Users.FindByID(ctx, listed.UserID)
+		if errors.Is(err, repositories.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return purged, err
+		}
+		if user.PurgeAfter == nil || user.PurgeAfter.After(now) {
+			continue
+		}
+
+		certificate, err := PurgeAccount(ctx, store, storage, user, now)
+		if err != nil {
+			log.Printf("Error purging account %s: %v", user.UserID, err)
+			continue
+		}
+		log.Printf("Purged account %s (certificate %s)", user.UserID, certificate.CertificateID)
+		purged++
+	}
+	return purged, nil
+}
+
+// StartAccountPurger runs PurgeDeletedAccounts every interval until ctx is
+// done.
+func StartAccountPurger(ctx context.Context, store *repositories.Store, storage *Storage, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				purgeCtx, cancel := context.WithTimeout(ctx, interval)
+				purged, err := PurgeDeletedAccounts(purgeCtx, store, storage, now.UTC())
+				cancel()
+				if err != nil {
+					log.Printf("Error purging deleted accounts: %v", err)
+					continue
+				}
+				if purged > 0 {
+					log.Printf("Purged %d deleted accounts", purged)
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,131 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+
+	"golang-speakbackend/models"
+	"golang-speakbackend/repositories"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	RolePatient   = "patient"
+	RoleTherapist = "therapist"
+	RoleAdmin     = "admin"
+)
+
+var (
+	ErrUnauthorized = errors.New("unauthorized to access this resource")
+	ErrNotFound     = errors.New("resource not found")
+)
+
+// CheckUserType returns an error unless the authenticated user has one of the given roles.
+func CheckUserType(c *gin.Context, roles ...string) error {
+	userType := c.GetString("role")
+	for _, role := range roles {
+		if userType == role {
+			return nil
+		}
+	}
+	return ErrUnauthorized
+}
+
+// MatchUserTypeToUid allows admins through and otherwise requires the
+// authenticated user to be the user identified by userID.
+func MatchUserTypeToUid(c *gin.Context, userID string) error {
+	if c.GetString("role") == RoleAdmin {
+		return nil
+	}
+	if c.GetString("user_id") != userID {
+		return ErrUnauthorized
+	}
+	return nil
+}
+
+// CanAccessPatient allows the patient themselves, admins and therapists on
+// the patient's care team.
+func CanAccessPatient(ctx context.Context, c *gin.Context, store *repositories.Store, patientID string) error {
+	return CanActForPatient(ctx, c, store, patientID, CareCapabilityView)
+}
+
+// CanActForPatient is CanAccessPatient for therapists whose care team role
+// grants the capability, such as CareCapabilityAssign for changing the
+// patient's exercises.
+func CanActForPatient(ctx context.Context, c *gin.Context, store *repositories.Store, patientID string, capability string) error {
+	if err := MatchUserTypeToUid(c, patientID); err == nil {
+		return nil
+	}
+	if c.GetString("role") != RoleTherapist {
+		return ErrUnauthorized
+	}
+
+	allowed, err := HasCareCapability(ctx, store, c.GetString("user_id"), patientID, capability)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return ErrUnauthorized
+	}
+	return nil
+}
+
+// CanAccessPatientExercise applies CanAccessPatient to the patient the
+// exercise is assigned to.
+func CanAccessPatientExercise(ctx context.Context, c *gin.Context, store *repositories.Store, patientExerciseID string) error {
+	return CanActForPatientExercise(ctx, c, store, patientExerciseID, CareCapabilityView)
+}
+
+// CanActForPatientExercise applies CanActForPatient to the patient the
+// exercise is assigned to.
+func CanActForPatientExercise(ctx context.Context, c *gin.Context, store *repositories.Store, patientExerciseID string, capability string) error {
+	patientExercise, err := store.PatientExercises.FindByID(ctx, patientExerciseID)
+	if err == repositories.ErrNotFound {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if patientExercise.PatientID == nil {
+		// No care team covers an exercise without a patient
+		return CheckUserType(c, RoleAdmin)
+	}
+	return CanActForPatient(ctx, c, store, *patientExercise.PatientID, capability)
+}
+
+// CanReleaseRecordingKey allows only the patient the exercise belongs to and
+// therapists on the patient's care team whose role grants recording access.
+// Unlike the other checks, admins are not let through: nobody outside the
+// care relationship gets plaintext recording keys.
+func CanReleaseRecordingKey(ctx context.Context, c *gin.Context, store *repositories.Store, patientExerciseID string) error {
+	patientExercise, err := store.PatientExercises.FindByID(ctx, patientExerciseID)
+	if err == repositories.ErrNotFound {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	userID := c.GetString("user_id")
+	switch {
+	case c.GetString("role") == RolePatient && patientExercise.PatientID != nil && *patientExercise.PatientID == userID:
+		return nil
+	case c.GetString("role") == RoleTherapist && patientExercise.PatientID != nil:
+		allowed, err := HasCareCapability(ctx, store, userID, *patientExercise.PatientID, CareCapabilityRecordings)
+		if err != nil {
+			return err
+		}
+		if allowed {
+			return nil
+		}
+	}
+	return ErrUnauthorized
+}
+
+// IsEmailVerified reports whether the user confirmed their email address.
+// Accounts from before verification existed have no status and count as
+// verified.
+func IsEmailVerified(user *models.User) bool {
+	return user.AccountStatus != models.AccountUnverified
+}
@@ -0,0 +1,168 @@
+package helpers
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"golang-speakbackend/models"
+	"golang-speakbackend/repositories"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// What a care team member may do with the patient's record.
+const (
+	CareCapabilityView = "view"
+	// CareCapabilityAssign allows assigning and scheduling exercises.
+	CareCapabilityAssign = "assign"
+	// CareCapabilityRecordings allows getting recording keys.
+	CareCapabilityRecordings = "recordings"
+	// CareCapabilityManageTeam allows adding and removing team members.
+	CareCapabilityManageTeam = "manage_team"
+)
+
+// careRoleCapabilities lists the capabilities of every care team role.
+// Students can follow the patient's progress but not act on it or listen to
+// recordings.
+var careRoleCapabilities = map[string][]string{
+	models.CareRolePrimary:    {CareCapabilityView, CareCapabilityAssign, CareCapabilityRecordings, CareCapabilityManageTeam},
+	models.CareRoleSupervisor: {CareCapabilityView, CareCapabilityAssign, CareCapabilityRecordings, CareCapabilityManageTeam},
+	models.CareRoleAssistant:  {CareCapabilityView, CareCapabilityAssign, CareCapabilityRecordings},
+	models.CareRoleStudent:    {CareCapabilityView},
+}
+
+// IsCareRole reports whether role is a known care team role.
+func IsCareRole(role string) bool {
+	_, ok := careRoleCapabilities[role]
+	return ok
+}
+
+// CareRoleAllows reports whether the care team role grants the capability.
+func CareRoleAllows(role string, capability string) bool {
+	for _, allowed := range careRoleCapabilities[role] {
+		if allowed == capability {
+			return true
+		}
+	}
+	return false
+}
+
+// IsActiveCareRelationship reports whether the relationship grants access at
+// the given time.
+func IsActiveCareRelationship(relationship models.CareRelationship, now time.Time) bool {
+	if relationship.Status != models.CareStatusActive || now.Before(relationship.StartDate) {
+		return false
+	}
+	return relationship.EndDate == nil || now.Before(*relationship.EndDate)
+}
+
+// ActiveCareRelationship returns the therapist's active relationship with the
+// patient, or nil if they aren't on the patient's care team.
+func ActiveCareRelationship(ctx context.Context, store *repositories.Store, therapistID string, patientID string) (*models.CareRelationship, error) {
+	relationships, err := store.CareTeams.ListByPatient(ctx, patientID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for _, relationship := range relationships {
+		if relationship.TherapistID == therapistID && IsActiveCareRelationship(relationship, now) {
+			return &relationship, nil
+		}
+	}
+	return nil, nil
+}
+
+// HasCareCapability reports whether the therapist is on the patient's care
+// team in a role that grants the capability.
+func HasCareCapability(ctx context.Context, store *repositories.Store, therapistID string, patientID string, capability string) (bool, error) {
+	relationship, err := ActiveCareRelationship(ctx, store, therapistID, patientID)
+	if err != nil || relationship == nil {
+		return false, err
+	}
+	return CareRoleAllows(relationship.Role, capability), nil
+}
+
+// ActiveCareRelationshipsOfTherapist returns the therapist's active
+// relationships, one per patient.
+func ActiveCareRelationshipsOfTherapist(ctx context.Context, store *repositories.Store, therapistID string) ([]models.CareRelationship, error) {
+	relationships, err := store.CareTeams.ListByTherapist(ctx, therapistID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	active := []models.CareRelationship{}
+	for _, relationship := range relationships {
+		if IsActiveCareRelationship(relationship, now) {
+			active = append(active, relationship)
+		}
+	}
+	return active, nil
+}
+
+// NewCareRelationship returns an active relationship starting at startDate.
+func NewCareRelationship(patientID string, therapistID string, role string, startDate time.Time, endDate *time.Time, createdBy string) *models.CareRelationship {
+	now := time.Now().UTC()
+	relationship := &models.CareRelationship{
+		ID:          primitive.NewObjectID(),
+		PatientID:   patientID,
+		TherapistID: therapistID,
+		Role:        role,
+		Status:      models.CareStatusActive,
+		StartDate:   startDate,
+		EndDate:     endDate,
+		CreatedBy:   createdBy,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	relationship.RelationshipID = relationship.ID.Hex()
+	return relationship
+}
+
+// MigrateReferenceCodeLinks gives every patient still linked through a
+// therapist's reference code a primary care relationship with that
+// therapist. Pairs that already have a relationship are skipped, so it is
+// safe to run on every start.
+func MigrateReferenceCodeLinks(ctx context.Context, store *repositories.Store) error {
+	patients, err := store.Users.ListLinkedPatients(ctx)
+	if err != nil {
+		return err
+	}
+
+	migrated := 0
+	for _, patient := range patients {
+		therapist, err := store.Users.FindTherapistByReferenceCode(ctx, patient.ReferenceCode)
+		if err == repositories.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		relationships, err := store.CareTeams.ListByPatient(ctx, patient.UserID)
+		if err != nil {
+			return err
+		}
+		exists := false
+		for _, relationship := range relationships {
+			if relationship.TherapistID == therapist.UserID {
+				exists = true
+				break
+			}
+		}
+		if exists {
+			continue
+		}
+
+		relationship := NewCareRelationship(patient.UserID, therapist.UserID, models.CareRolePrimary, time.Now().UTC(), nil, RoleSystem)
+		if err := store.CareTeams.Insert(ctx, relationship); err != nil {
+			return err
+		}
+		migrated++
+	}
+
+	if migrated > 0 {
+		log.Printf("Migrated %d reference code links to care relationships", migrated)
+	}
+	return nil
+}
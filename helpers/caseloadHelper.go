@@ -0,0 +1,122 @@
+package helpers
+
+import (
+	"context"
+	"time"
+
+	"golang-speakbackend/models"
+	"golang-speakbackend/repositories"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// TransferCaseload hands the patients of the given care relationships over
+// to another therapist in one transaction. The new therapist takes over each
+// relationship's role along with the patient's open and historical
+// assignments and schedules, which keep the previous therapist as author.
+// Feedback stays with the therapist who wrote it.
+func TransferCaseload(ctx context.Context, store *repositories.Store, relationships []models.CareRelationship, fromTherapistID string, toTherapistID string, requestedBy string) (*models.CaseloadTransfer, error) {
+	transfer := &models.CaseloadTransfer{
+		ID:              primitive.NewObjectID(),
+		FromTherapistID: fromTherapistID,
+		ToTherapistID:   toTherapistID,
+		RequestedBy:     requestedBy,
+	}
+	transfer.TransferID = transfer.ID.Hex()
+
+	err := store.WithTransaction(ctx, func(ctx context.Context) error {
+		now := time.Now().UTC()
+		transfer.CreatedAt = now
+		transfer.Patients = []models.CaseloadTransferPatient{}
+		for _, relationship := range relationships {
+			report, err := transferPatient(ctx, store, relationship, toTherapistID, requestedBy, now)
+			if err != nil {
+				return err
+			}
+			transfer.Patients = append(transfer.Patients, *report)
+		}
+		return store.Transfers.Insert(ctx, transfer)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return transfer, nil
+}
+
+func transferPatient(ctx context.Context, store *repositories.Store, relationship models.CareRelationship, toTherapistID string, requestedBy string, now time.Time) (*models.CaseloadTransferPatient, error) {
+	patientID := relationship.PatientID
+	fromTherapistID := relationship.TherapistID
+	report := &models.CaseloadTransferPatient{
+		PatientID:           patientID,
+		Role:                relationship.Role,
+		EndedRelationshipID: relationship.RelationshipID,
+	}
+
+	patientExercises, err := store.PatientExercises.ListByPatient(ctx, patientID)
+	if err != nil {
+		return nil, err
+	}
+	for _, patientExercise := range patientExercises {
+		if patientExercise.TherapistID == nil || *patientExercise.TherapistID != fromTherapistID {
+			continue
+		}
+		switch NormalizeStatus(patientExercise.Status) {
+		case models.StatusReviewed, models.StatusArchived:
+			report.HistoricalAssignments++
+		default:
+			report.OpenAssignments++
+		}
+	}
+	schedules, err := store.Schedules.ListByPatient(ctx, patientID)
+	if err != nil {
+		return nil, err
+	}
+	for _, schedule := range schedules {
+		if schedule.TherapistID != nil && *schedule.TherapistID == fromTherapistID {
+			report.Schedules++
+		}
+	}
+
+	existing, err := ActiveCareRelationship(ctx, store, toTherapistID, patientID)
+	if err != nil {
+		return nil, err
+	}
+	if err := store.CareTeams.End(ctx, relationship.RelationshipID, now); err != nil {
+		return nil, err
+	}
+
+	// A therapist already on the team keeps their membership unless the
+	// transferred role grants more
+	if existing != nil && !careRoleOutranks(relationship.Role, existing.Role) {
+		report.Role = existing.Role
+		report.RelationshipID = existing.RelationshipID
+	} else {
+		if existing != nil {
+			if err := store.CareTeams.End(ctx, existing.RelationshipID, now); err != nil {
+				return nil, err
+			}
+		}
+		successor := NewCareRelationship(patientID, toTherapistID, relationship.Role, now, relationship.EndDate, requestedBy)
+		if err := store.CareTeams.Insert(ctx, successor); err != nil {
+			return nil, err
+		}
+		report.RelationshipID = successor.RelationshipID
+	}
+
+	if err := store.PatientExercises.Reassign(ctx, patientID, fromTherapistID, toTherapistID); err != nil {
+		return nil, err
+	}
+	if err := store.Schedules.Reassign(ctx, patientID, fromTherapistID, toTherapistID); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// careRoleOutranks reports whether role grants more than other. Primary
+// outranks every other role.
+func careRoleOutranks(role string, other string) bool {
+	if role == models.CareRolePrimary || other == models.CareRolePrimary {
+		return role == models.CareRolePrimary && other != models.CareRolePrimary
+	}
+	return len(careRoleCapabilities[role]) > len(careRoleCapabilities[other])
+}
@@ -0,0 +1,318 @@
+package helpers
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"log"
+	"math/big"
+	"strings"
+	"time"
+
+	"golang-speakbackend/models"
+	"golang-speakbackend/repositories"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	// ErrInvalidInvite is returned for unknown, expired, revoked and used up
+	// invite codes alike.
+	ErrInvalidInvite = errors.New("invalid or expired invite code")
+	// ErrInviteEmailMismatch is returned when an invite made out to one email
+	// address is redeemed by another account.
+	ErrInviteEmailMismatch = errors.New("invite is for another email address")
+	// ErrInviteEmailUnverified is returned when an invite made out to an
+	// email address is redeemed by an account that hasn't proven it owns it.
+	ErrInviteEmailUnverified = errors.New("verify your email address to redeem this invite")
+	// ErrTherapistUnverified is returned when linking to a therapist who has
+	// not verified their email yet.
+	ErrTherapistUnverified = errors.New("therapist has not verified their email yet")
+)
+
+// Invite codes leave out letters and digits that are easily confused.
+const inviteCodeAlphabet = "ABCDEFGHJKMNPQRSTUVWXYZ23456789"
+
+// RandomString returns length characters drawn uniformly from alphabet with
+// crypto/rand.
+func RandomString(length int, alphabet string) (string, error) {
+	max := big.NewInt(int64(len(alphabet)))
+	b := make([]byte, length)
+	for i := range b {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b[i] = alphabet[n.Int64()]
+	}
+	return string(b), nil
+}
+
+// GenerateInviteCode returns a code like "K3M9Q-X7P2D" together with the hash
+// to store.
+func GenerateInviteCode() (string, string, error) {
+	raw, err := RandomString(10, inviteCodeAlphabet)
+	if err != nil {
+		return "", "", err
+	}
+	code := raw[:5] + "-" + raw[5:]
+	return code, HashInviteCode(code), nil
+}
+
+// HashInviteCode normalises an invite code as typed by the patient and
+// returns its hash.
+func HashInviteCode(code string) string {
+	code = strings.ToUpper(strings.Join(strings.Fields(code), ""))
+	return hashOneTimeToken(strings.ReplaceAll(code, "-", ""))
+}
+
+// IsInviteRedeemable reports whether the invite can still be redeemed at the
+// given time.
+func IsInviteRedeemable(invite *models.Invite, now time.Time) bool {
+	if invite.RevokedAt != nil {
+		return false
+	}
+	if invite.ExpiresAt != nil && !now.Before(*invite.ExpiresAt) {
+		return false
+	}
+	return invite.MaxUses == 0 || invite.Uses < invite.MaxUses
+}
+
+// LegacyInvite returns the invite standing for the therapist's reference
+// code, creating it the first time. Therapists without a reference code have
+// none.
+func LegacyInvite(ctx context.Context, store *repositories.Store, therapist *models.User) (*models.Invite, error) {
+	if therapist.ReferenceCode == "" {
+		return nil, nil
+	}
+	invite, err := store.Invites.FindLegacy(ctx, therapist.UserID)
+	if err != repositories.ErrNotFound {
+		return invite, err
+	}
+
+	now := time.Now().UTC()
+	invite = &models.Invite{
+		ID:          primitive.NewObjectID(),
+		TherapistID: therapist.UserID,
+		Legacy:      true,
+		ExerciseIDs: []string{},
+		Redemptions: []models.InviteRedemption{},
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	invite.InviteID = invite.ID.Hex()
+	if err := store.Invites.Insert(ctx, invite); err != nil {
+		return nil, err
+	}
+	return invite, nil
+}
+
+// findInvite looks the code up among invite codes first and therapists'
+// reference codes second.
+func findInvite(ctx context.Context, store *repositories.Store, code string) (*models.Invite, error) {
+	invite, err := store.Invites.FindByCodeHash(ctx, HashInviteCode(code))
+	if err != repositories.ErrNotFound {
+		return invite, err
+	}
+
+	// Reference codes are case sensitive
+	therapist, err := store.Users.FindTherapistByReferenceCode(ctx, strings.TrimSpace(code))
+	if err != nil {
+		return nil, err
+	}
+	invite, err = LegacyInvite(ctx, store, therapist)
+	if err == nil && invite == nil {
+		return nil, repositories.ErrNotFound
+	}
+	return invite, err
+}
+
+// RedeemInvite puts the patient on the inviting therapist's caseload as their
+// primary therapist and assigns the invite's exercise bundle. A patient who
+// already has a primary therapist keeps them, and the inviting therapist
+// joins their care team as an assistant; only a caseload transfer hands the
+// patient over. A legacy invite only creates a pending link request for the
+// therapist to accept. Redeeming an invite of a therapist the patient is
+// already linked to, or has asked to be, changes nothing and doesn't count as
+// a use.
+func RedeemInvite(ctx context.Context, store *repositories.Store, code string, patient *models.User) (*models.CareRelationship, *models.InviteRedemption, error) {
+	invite, err := findInvite(ctx, store, code)
+	if err == repositories.ErrNotFound {
+		return nil, nil, ErrInvalidInvite
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now().UTC()
+	if !IsInviteRedeemable(invite, now) {
+		return nil, nil, ErrInvalidInvite
+	}
+	if invite.Email != "" && (patient.Email == nil || !strings.EqualFold(invite.Email, *patient.Email)) {
+		return nil, nil, ErrInviteEmailMismatch
+	}
+	// Anyone can sign up with the invited address before the patient does
+	if invite.Email != "" && !IsEmailVerified(patient) {
+		return nil, nil, ErrInviteEmailUnverified
+	}
+
+	therapist, err := store.Users.FindByID(ctx, invite.TherapistID)
+	if err == repositories.ErrNotFound || (err == nil && (therapist.Role != RoleTherapist || IsAccountDeleted(therapist))) {
+		return nil, nil, ErrInvalidInvite
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if !IsEmailVerified(therapist) {
+		return nil, nil, ErrTherapistUnverified
+	}
+
+	relationships, err := store.CareTeams.ListByPatient(ctx, patient.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	role := models.CareRolePrimary
+	for _, existing := range relationships {
+		if existing.TherapistID != therapist.UserID {
+			if !invite.Legacy && existing.Role == models.CareRolePrimary && IsActiveCareRelationship(existing, now) {
+				role = models.CareRoleAssistant
+			}
+			continue
+		}
+		if existing.Status == models.CareStatusPending || IsActiveCareRelationship(existing, now) {
+			return &existing, &models.InviteRedemption{
+				PatientID:          patient.UserID,
+				RelationshipID:     existing.RelationshipID,
+				PatientExerciseIDs: []string{},
+				RedeemedAt:         now,
+			}, nil
+		}
+	}
+
+	patientExercises, err := newInvitePatientExercises(ctx, store, invite, patient.UserID, now)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	relationship := NewCareRelationship(patient.UserID, therapist.UserID, role, now, nil, patient.UserID)
+	if invite.Legacy {
+		relationship.Status = models.CareStatusPending
+	}
+	redemption := models.InviteRedemption{
+		PatientID:          patient.UserID,
+		RelationshipID:     relationship.RelationshipID,
+		PatientExerciseIDs: []string{},
+		RedeemedAt:         now,
+	}
+	for _, patientExercise := range patientExercises {
+		redemption.PatientExerciseIDs = append(redemption.PatientExerciseIDs, patientExercise.PatientExerciseID)
+	}
+
+	// Counting the use first keeps concurrent redemptions within max_uses
+	err = store.Invites.Redeem(ctx, invite.InviteID, redemption, now)
+	if errors.Is(err, repositories.ErrConflict) {
+		return nil, nil, ErrInvalidInvite
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if relationship.Status == models.CareStatusPending || relationship.Role != models.CareRolePrimary {
+		err = store.CareTeams.Insert(ctx, relationship)
+	} else {
+		err = LinkPrimaryTherapist(ctx, store, relationship)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(patientExercises) > 0 {
+		if err := store.PatientExercises.InsertMany(ctx, patientExercises); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// A reference code left on the patient would be migrated again
+	if patient.ReferenceCode != "" {
+		empty := ""
+		if err := store.Users.Update(ctx, patient.UserID, repositories.UserUpdate{ReferenceCode: &empty}); err != nil {
+			log.Printf("Could not clear reference code of patient %s: %v", patient.UserID, err)
+		}
+	}
+
+	return relationship, &redemption, nil
+}
+
+// newInvitePatientExercises builds the assignments of the invite's exercise
+// bundle. Exercises archived or deleted since the invite was made are left
+// out.
+func newInvitePatientExercises(ctx context.Context, store *repositories.Store, invite *models.Invite, patientID string, now time.Time) ([]models.PatientExercise, error) {
+	if len(invite.ExerciseIDs) == 0 {
+		return nil, nil
+	}
+	exercises, err := store.Exercises.FindByIDs(ctx, invite.ExerciseIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	patientExercises := []models.PatientExercise{}
+	for _, exercise := range exercises {
+		if exercise.ArchivedAt != nil {
+			continue
+		}
+		exerciseID := exercise.ExerciseID
+		therapistID := invite.TherapistID
+		patientExercise := models.PatientExercise{
+			ID:          primitive.NewObjectID(),
+			PatientID:   &patientID,
+			TherapistID: &therapistID,
+			ExerciseID:  &exerciseID,
+			Status:      models.StatusAssigned,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		}
+		patientExercise.PatientExerciseID = patientExercise.ID.Hex()
+		patientExercises = append(patientExercises, patientExercise)
+	}
+	return patientExercises, nil
+}
+
+// LinkPrimaryTherapist inserts the primary relationship, ending the
+// patient's previous primary therapist's membership.
+func LinkPrimaryTherapist(ctx context.Context, store *repositories.Store, relationship *models.CareRelationship) error {
+	if err := endOtherPrimaries(ctx, store, relationship); err != nil {
+		return err
+	}
+	return store.CareTeams.Insert(ctx, relationship)
+}
+
+// AcceptLinkRequest makes the pending request the patient's primary
+// relationship from now on, ending the previous primary therapist's
+// membership. It returns repositories.ErrConflict if the request is no
+// longer pending.
+func AcceptLinkRequest(ctx context.Context, store *repositories.Store, relationship *models.CareRelationship) error {
+	now := time.Now().UTC()
+	if err := store.CareTeams.Accept(ctx, relationship.RelationshipID, now); err != nil {
+		return err
+	}
+	relationship.Status = models.CareStatusActive
+	relationship.StartDate = now
+	return endOtherPrimaries(ctx, store, relationship)
+}
+
+func endOtherPrimaries(ctx context.Context, store *repositories.Store, relationship *models.CareRelationship) error {
+	relationships, err := store.CareTeams.ListByPatient(ctx, relationship.PatientID)
+	if err != nil {
+		return err
+	}
+	for _, existing := range relationships {
+		if existing.RelationshipID == relationship.RelationshipID || existing.Role != models.CareRolePrimary {
+			continue
+		}
+		if IsActiveCareRelationship(existing, relationship.StartDate) {
+			if err := store.CareTeams.End(ctx, existing.RelationshipID, relationship.StartDate); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,260 @@
+package helpers
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang-speakbackend/config"
+	"golang-speakbackend/models"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+)
+
+const (
+	KeyProviderAWS   = "aws"
+	KeyProviderLocal = "local"
+)
+
+var (
+	ErrMalformedWrappedKey = errors.New("malformed wrapped key")
+	ErrUnknownMasterKey    = errors.New("wrapped key was made with an unknown master key")
+)
+
+// KeyWrapper encrypts the AES keys of recordings under a master key, so that
+// only wrapped keys are ever stored.
+type KeyWrapper interface {
+	WrapKey(ctx context.Context, plaintext []byte) (string, error)
+	UnwrapKey(ctx context.Context, wrapped string) ([]byte, error)
+	// KeyID identifies the master key new keys are wrapped with.
+	KeyID() string
+}
+
+const (
+	// DataKeyAlgorithm is how clients encrypt recordings with the data key.
+	DataKeyAlgorithm = "AES-256-GCM"
+	// DataKeyNonceConvention says where the GCM nonce lives: a random 96-bit
+	// nonce is written in front of the ciphertext.
+	DataKeyNonceConvention = "nonce-prefix-96"
+)
+
+// DataKey is a freshly generated recording key. Plaintext is handed to the
+// client once and never stored; only Wrapped is persisted.
+type DataKey struct {
+	Plaintext  []byte
+	Wrapped    string
+	Encryption models.RecordingEncryption
+}
+
+// GenerateDataKey creates a random AES-256 key and wraps it.
+func GenerateDataKey(ctx context.Context, keys KeyWrapper) (*DataKey, error) {
+	plaintext := make([]byte, 32)
+	if _, err := rand.Read(plaintext); err != nil {
+		return nil, err
+	}
+
+	wrapped, err := keys.WrapKey(ctx, plaintext)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DataKey{
+		Plaintext: plaintext,
+		Wrapped:   wrapped,
+		Encryption: models.RecordingEncryption{
+			Algorithm:       DataKeyAlgorithm,
+			NonceConvention: DataKeyNonceConvention,
+			KeyVersion:      keys.KeyID(),
+		},
+	}, nil
+}
+
+// NewKeyWrapper returns the key wrapper selected by cfg.Provider.
+func NewKeyWrapper(cfg config.KMSConfig) (KeyWrapper, error) {
+	switch cfg.Provider {
+	case KeyProviderAWS, "":
+		client, err := NewKMSClient(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return NewKMSKeyWrapper(client, cfg.KeyID), nil
+	case KeyProviderLocal:
+		masterKey, err := loadMasterKey(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return NewLocalKeyWrapper(masterKey, cfg.MasterKeyID)
+	default:
+		return nil, fmt.Errorf("unknown key provider %q", cfg.Provider)
+	}
+}
+
+func NewKMSClient(cfg config.KMSConfig) (*kms.Client, error) {
+	kmsCfg, err := awsconfig.LoadDefaultConfig(context.Background(),
+		awsconfig.WithRegion(cfg.Region),
+		awsconfig.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(cfg.AccessKey, cfg.SecretKey, "")),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("creating KMS session: %w", err)
+	}
+	return kms.NewFromConfig(kmsCfg), nil
+}
+
+// KMSKeyWrapper wraps keys with an AWS KMS key. The wrapped form is the
+// base64 KMS ciphertext blob, which already names the KMS key.
+type KMSKeyWrapper struct {
+	client *kms.Client
+	keyID  string
+}
+
+func NewKMSKeyWrapper(client *kms.Client, keyID string) *KMSKeyWrapper {
+	return &KMSKeyWrapper{client: client, keyID: keyID}
+}
+
+func (w *KMSKeyWrapper) KeyID() string {
+	return w.keyID
+}
+
+func (w *KMSKeyWrapper) WrapKey(ctx context.Context, plaintext []byte) (string, error) {
+	result, err := w.client.Encrypt(ctx, &kms.EncryptInput{
+		KeyId:     aws.String(w.keyID),
+		Plaintext: plaintext,
+	})
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(result.CiphertextBlob), nil
+}
+
+func (w *KMSKeyWrapper) UnwrapKey(ctx context.Context, wrapped string) ([]byte, error) {
+	ciphertextBlob, err := base64.StdEncoding.DecodeString(wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedWrappedKey, err)
+	}
+
+	result, err := w.client.Decrypt(ctx, &kms.DecryptInput{
+		KeyId:             aws.String(w.keyID),
+		CiphertextBlob:    ciphertextBlob,
+		EncryptionContext: nil, // Add encryption context if used during wrapping
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.Plaintext, nil
+}
+
+// localWrapVersion is the first byte of every locally wrapped key.
+const localWrapVersion = 1
+
+// LocalKeyWrapper wraps keys with AES-256-GCM under a master key held by the
+// server, for deployments without AWS and for offline tests. The wrapped
+// form is base64 of
+//
+//	version (1 byte) | key ID length (1 byte) | key ID | nonce | ciphertext
+//
+// with the key ID authenticated as additional data, so keys wrapped under a
+// different master key are recognised rather than failing to decrypt.
+type LocalKeyWrapper struct {
+	keyID string
+	aead  cipher.AEAD
+}
+
+// NewLocalKeyWrapper returns a wrapper for the 32-byte master key. If keyID
+// is empty, it is derived from the key's fingerprint.
+func NewLocalKeyWrapper(masterKey []byte, keyID string) (*LocalKeyWrapper, error) {
+	if len(masterKey) != 32 {
+		return nil, fmt.Errorf("local master key must be 32 bytes, got %d", len(masterKey))
+	}
+	if keyID == "" {
+		fingerprint := sha256.Sum256(masterKey)
+		keyID = "local-" + hex.EncodeToString(fingerprint[:8])
+	}
+	if len(keyID) > 255 {
+		return nil, errors.New("local master key ID must be at most 255 bytes")
+	}
+
+	block, err := aes.NewCipher(masterKey)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &LocalKeyWrapper{keyID: keyID, aead: aead}, nil
+}
+
+func (w *LocalKeyWrapper) KeyID() string {
+	return w.keyID
+}
+
+func (w *LocalKeyWrapper) WrapKey(ctx context.Context, plaintext []byte) (string, error) {
+	nonce := make([]byte, w.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	wrapped := []byte{localWrapVersion, byte(len(w.keyID))}
+	wrapped = append(wrapped, w.keyID...)
+	wrapped = append(wrapped, nonce...)
+	wrapped = w.aead.Seal(wrapped, nonce, plaintext, []byte(w.keyID))
+	return base64.StdEncoding.EncodeToString(wrapped), nil
+}
+
+func (w *LocalKeyWrapper) UnwrapKey(ctx context.Context, wrapped string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedWrappedKey, err)
+	}
+	if len(data) < 2 || data[0] != localWrapVersion {
+		return nil, ErrMalformedWrappedKey
+	}
+
+	keyIDLength := int(data[1])
+	data = data[2:]
+	if len(data) < keyIDLength+w.aead.NonceSize() {
+		return nil, ErrMalformedWrappedKey
+	}
+	keyID := string(data[:keyIDLength])
+	if keyID != w.keyID {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownMasterKey, keyID)
+	}
+
+	data = data[keyIDLength:]
+	nonce, ciphertext := data[:w.aead.NonceSize()], data[w.aead.NonceSize():]
+	plaintext, err := w.aead.Open(nil, nonce, ciphertext, []byte(keyID))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedWrappedKey, err)
+	}
+	return plaintext, nil
+}
+
+// loadMasterKey reads the base64 master key from the config or, if it is
+// not set there, from the file it names.
+func loadMasterKey(cfg config.KMSConfig) ([]byte, error) {
+	encoded := cfg.MasterKey
+	if encoded == "" {
+		data, err := os.ReadFile(cfg.MasterKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading local master key: %w", err)
+		}
+		encoded = string(data)
+	}
+
+	masterKey, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("decoding local master key: %w", err)
+	}
+	return masterKey, nil
+}
@@ -0,0 +1,115 @@
+package helpers
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"golang-speakbackend/config"
+	"golang-speakbackend/repositories"
+)
+
+// loginFailureWindow is how long a failed login counts towards a lockout.
+const loginFailureWindow = 24 * time.Hour
+
+// LoginLockedError is returned while an account or IP address is locked out.
+// The message is the same either way, so it doesn't reveal which one it is
+// or whether the account exists.
+type LoginLockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginLockedError) Error() string {
+	return "too many failed login attempts, try again later"
+}
+
+// LoginGuard throttles password and MFA guessing. Failures are counted per
+// account (by email, whether or not it exists) and per IP address.
+type LoginGuard struct {
+	store         *repositories.Store
+	maxFailures   int
+	ipMaxFailures int
+	lockout       time.Duration
+	maxLockout    time.Duration
+}
+
+func NewLoginGuard(store *repositories.Store, cfg config.AccountConfig) *LoginGuard {
+	return &LoginGuard{
+		store:         store,
+		maxFailures:   cfg.LoginMaxFailures,
+		ipMaxFailures: cfg.LoginIPMaxFailures,
+		lockout:       cfg.LoginLockout.Duration,
+		maxLockout:    cfg.LoginMaxLockout.Duration,
+	}
+}
+
+func accountThrottleKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipThrottleKey(ip string) string {
+	return "ip:" + ip
+}
+
+// Check returns a *LoginLockedError if the account or the IP address is
+// locked out.
+func (g *LoginGuard) Check(ctx context.Context, email string, ip string) error {
+	now := time.Now().UTC()
+	var retryAfter time.Duration
+	for _, key := range []string{accountThrottleKey(email), ipThrottleKey(ip)} {
+		throttle, err := g.store.LoginThrottles.Find(ctx, key)
+		if err == repositories.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if throttle.LockedUntil != nil && throttle.LockedUntil.After(now) {
+			if wait := throttle.LockedUntil.Sub(now); wait > retryAfter {
+				retryAfter = wait
+			}
+		}
+	}
+	if retryAfter > 0 {
+		return &LoginLockedError{RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+// Fail counts a failed login and locks the account or IP address once it
+// has too many.
+func (g *LoginGuard) Fail(ctx context.Context, email string, ip string) error {
+	if err := g.fail(ctx, accountThrottleKey(email), g.maxFailures); err != nil {
+		return err
+	}
+	return g.fail(ctx, ipThrottleKey(ip), g.ipMaxFailures)
+}
+
+func (g *LoginGuard) fail(ctx context.Context, key string, maxFailures int) error {
+	now := time.Now().UTC()
+	throttle, err := g.store.LoginThrottles.RecordFailure(ctx, key, now, now.Add(-loginFailureWindow))
+	if err != nil {
+		return err
+	}
+	if throttle.Failures < maxFailures {
+		return nil
+	}
+
+	// Double the lockout with every failure past the limit
+	lockout := g.lockout
+	for i := maxFailures; i < throttle.Failures && lockout < g.maxLockout; i++ {
+		lockout *= 2
+	}
+	if lockout > g.maxLockout {
+		lockout = g.maxLockout
+	}
+	log.Printf("Login locked for %s until %s after %d failed attempts", key, now.Add(lockout).Format(time.RFC3339), throttle.Failures)
+	return g.store.LoginThrottles.Lock(ctx, key, now.Add(lockout))
+}
+
+// Succeed clears the account's failures after a successful login. The IP
+// address keeps its count, so one valid account can't be used to reset it.
+func (g *LoginGuard) Succeed(ctx context.Context, email string) error {
+	return g.store.LoginThrottles.Reset(ctx, accountThrottleKey(email))
+}
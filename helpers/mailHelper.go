@@ -0,0 +1,94 @@
+package helpers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang-speakbackend/config"
+)
+
+const (
+	MailProviderLog  = "log"
+	MailProviderFile = "file"
+	MailProviderSMTP = "smtp"
+)
+
+// MailMessage is a plain-text email.
+type MailMessage struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers emails to users.
+type Mailer interface {
+	Send(ctx context.Context, message MailMessage) error
+}
+
+// NewMailer returns the mailer selected by cfg.Provider.
+func NewMailer(cfg config.MailConfig) (Mailer, error) {
+	switch cfg.Provider {
+	case MailProviderLog, "":
+		return LogMailer{}, nil
+	case MailProviderFile:
+		if err := os.MkdirAll(cfg.Dir, 0o700); err != nil {
+			return nil, fmt.Errorf("creating mail directory: %w", err)
+		}
+		return FileMailer{dir: cfg.Dir, from: cfg.From}, nil
+	case MailProviderSMTP:
+		return SMTPMailer{
+			addr: fmt.Sprintf("%s:%d", cfg.SMTPHost, cfg.SMTPPort),
+			from: cfg.From,
+			auth: smtp.PlainAuth("", cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPHost),
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown mail provider %q", cfg.Provider)
+	}
+}
+
+// LogMailer writes emails to the server log, for local development.
+type LogMailer struct{}
+
+func (LogMailer) Send(ctx context.Context, message MailMessage) error {
+	log.Printf("Mail to %s: %s\n%s", message.To, message.Subject, message.Body)
+	return nil
+}
+
+// FileMailer writes every email to its own file in a directory, for local
+// development and tests that need to read the mail back.
+type FileMailer struct {
+	dir  string
+	from string
+}
+
+func (m FileMailer) Send(ctx context.Context, message MailMessage) error {
+	name := fmt.Sprintf("%d-%s.eml", time.Now().UnixNano(), strings.NewReplacer("@", "_at_", "/", "_").Replace(message.To))
+	return os.WriteFile(filepath.Join(m.dir, name), formatMail(m.from, message), 0o600)
+}
+
+// SMTPMailer sends emails through an SMTP server.
+type SMTPMailer struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+func (m SMTPMailer) Send(ctx context.Context, message MailMessage) error {
+	return smtp.SendMail(m.addr, m.auth, m.from, []string{message.To}, formatMail(m.from, message))
+}
+
+func formatMail(from string, message MailMessage) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", message.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", message.Subject)
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(message.Body)
+	return []byte(b.String())
+}
@@ -0,0 +1,46 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"golang-speakbackend/models"
+	"golang-speakbackend/repositories"
+)
+
+// ErrInvalidMFACode is returned for wrong, reused and missing codes alike.
+var ErrInvalidMFACode = errors.New("invalid authentication code")
+
+// VerifySecondFactor accepts either a current TOTP code or one of the user's
+// recovery codes. Each TOTP code and each recovery code works only once.
+func VerifySecondFactor(ctx context.Context, store *repositories.Store, keys KeyWrapper, user *models.User, code string, recoveryCode string) error {
+	if !user.MFA.Enabled {
+		return ErrInvalidMFACode
+	}
+
+	switch {
+	case code != "":
+		secret, err := keys.UnwrapKey(ctx, user.MFA.Secret)
+		if err != nil {
+			return err
+		}
+		step, ok := VerifyTOTP(string(secret), code, time.Now())
+		if !ok {
+			return ErrInvalidMFACode
+		}
+		err = store.Users.UseMFAStep(ctx, user.UserID, step)
+		if errors.Is(err, repositories.ErrConflict) {
+			return ErrInvalidMFACode
+		}
+		return err
+	case recoveryCode != "":
+		err := store.Users.ConsumeRecoveryCode(ctx, user.UserID, HashRecoveryCode(recoveryCode))
+		if errors.Is(err, repositories.ErrConflict) {
+			return ErrInvalidMFACode
+		}
+		return err
+	default:
+		return ErrInvalidMFACode
+	}
+}
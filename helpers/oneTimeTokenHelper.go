@@ -0,0 +1,82 @@
+package helpers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"golang-speakbackend/models"
+	"golang-speakbackend/repositories"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ErrInvalidOneTimeToken is returned for unknown, expired and already used
+// tokens alike, so callers can't tell them apart.
+var ErrInvalidOneTimeToken = errors.New("invalid or expired token")
+
+func hashOneTimeToken(plaintext string) string {
+	sum := sha256.Sum256([]byte(plaintext))
+	return hex.EncodeToString(sum[:])
+}
+
+// IssueOneTimeToken creates a token for the user and returns its plaintext,
+// which is only ever mailed to the user. Earlier unused tokens with the same
+// purpose stop working.
+func IssueOneTimeToken(ctx context.Context, store *repositories.Store, userID string, purpose string, ttl time.Duration) (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	plaintext := base64.RawURLEncoding.EncodeToString(secret)
+
+	now := time.Now().UTC()
+	if err := store.OneTimeTokens.ConsumeAllForUser(ctx, userID, purpose, now); err != nil {
+		return "", err
+	}
+
+	id := primitive.NewObjectID()
+	err := store.OneTimeTokens.Insert(ctx, &models.OneTimeToken{
+		ID:        id,
+		TokenID:   id.Hex(),
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: hashOneTimeToken(plaintext),
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	})
+	if err != nil {
+		return "", err
+	}
+	return plaintext, nil
+}
+
+// RedeemOneTimeToken checks the token and marks it used, so it can't be
+// redeemed a second time.
+func RedeemOneTimeToken(ctx context.Context, store *repositories.Store, purpose string, plaintext string) (*models.OneTimeToken, error) {
+	token, err := store.OneTimeTokens.FindByHash(ctx, purpose, hashOneTimeToken(plaintext))
+	if err == repositories.ErrNotFound {
+		return nil, ErrInvalidOneTimeToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	if token.UsedAt != nil || !now.Before(token.ExpiresAt) {
+		return nil, ErrInvalidOneTimeToken
+	}
+
+	err = store.OneTimeTokens.Consume(ctx, token.TokenID, now)
+	if errors.Is(err, repositories.ErrConflict) || errors.Is(err, repositories.ErrNotFound) {
+		return nil, ErrInvalidOneTimeToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	return token, nil
+}
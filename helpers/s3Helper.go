@@ -2,75 +2,159 @@ package helpers
 
 import (
 	"context"
-	"log"
+	"errors"
+	"fmt"
 	"mime/multipart"
-	"os"
+	"strings"
+	"time"
+
+	"golang-speakbackend/config"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
-	"github.com/aws/aws-sdk-go-v2/service/kms"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
-	// "github.com/joho/godotenv"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 )
 
-// Global session variables
-var s3Client *s3.Client
-var kmsClient *kms.Client
-
-func init() {
-	// err := godotenv.Load()
-	// if err != nil {
-	//     log.Fatalf("Error loading .env file")
-	// }
-
-	// Load environment variables
-	key := os.Getenv("SPACES_KEY")
-	secret := os.Getenv("SPACES_SECRET")
-	awsKey := os.Getenv("AWS_ACCESS")
-	awsSecret := os.Getenv("AWS_SECRET")
-
-	// Setup DigitalOcean Spaces session
-	s3Cfg, err := config.LoadDefaultConfig(context.Background(),
-		config.WithRegion("us-east-1"),
-		config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(key, secret, "")),
-	)
-	if err != nil {
-		log.Fatalf("Error creating S3 session: %s", err)
-	}
+var ErrObjectNotFound = errors.New("object not found")
 
-	// Customize S3 endpoint for DigitalOcean Spaces
-	s3Client = s3.NewFromConfig(s3Cfg, func(o *s3.Options) {
-		o.EndpointResolver = s3.EndpointResolverFromURL("https://nyc3.digitaloceanspaces.com")
-	})
+// Storage is the S3-compatible bucket that holds profile images and recordings.
+type Storage struct {
+	client       *s3.Client
+	bucket       string
+	cdnBaseURL   string
+	uploadURLTTL time.Duration
+}
+
+// ObjectInfo is what a HEAD request tells us about a stored object.
+type ObjectInfo struct {
+	Size        int64
+	ContentType string
+}
 
-	// Setup AWS KMS session
-	kmsCfg, err := config.LoadDefaultConfig(context.Background(),
-		config.WithRegion("us-east-2"),
-		config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(awsKey, awsSecret, "")),
+func NewStorage(cfg config.StorageConfig) (*Storage, error) {
+	s3Cfg, err := awsconfig.LoadDefaultConfig(context.Background(),
+		awsconfig.WithRegion(cfg.Region),
+		awsconfig.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(cfg.AccessKey, cfg.SecretKey, "")),
 	)
 	if err != nil {
-		log.Fatalf("Error creating KMS session: %s", err)
+		return nil, fmt.Errorf("creating S3 session: %w", err)
 	}
 
-	kmsClient = kms.NewFromConfig(kmsCfg)
-	log.Print("S3 and KMS sessions created")
-}
+	// Customize S3 endpoint for DigitalOcean Spaces
+	client := s3.NewFromConfig(s3Cfg, func(o *s3.Options) {
+		o.EndpointResolver = s3.EndpointResolverFromURL(cfg.Endpoint)
+	})
 
-func GetS3Client() *s3.Client {
-	return s3Client
+	return &Storage{
+		client:       client,
+		bucket:       cfg.Bucket,
+		cdnBaseURL:   strings.TrimRight(cfg.CDNBaseURL, "/"),
+		uploadURLTTL: cfg.UploadURLTTL.Duration,
+	}, nil
 }
 
-func GetKMSClient() *kms.Client {
-	return kmsClient
+// PublicURL returns the CDN URL of a public-read object.
+func (s *Storage) PublicURL(key string) string {
+	return fmt.Sprintf("%s/%s", s.cdnBaseURL, key)
 }
 
-func UploadFileToS3(ctx context.Context, s3Client *s3.Client, bucket string, key string, file multipart.File) error {
-	_, err := s3Client.PutObject(ctx, &s3.PutObjectInput{
-		Bucket: aws.String(bucket),
+func (s *Storage) UploadFile(ctx context.Context, key string, file multipart.File) error {
+	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(s.bucket),
 		Key:    aws.String(key),
 		Body:   file,
 		ACL:    "public-read", // Set the ACL to public-read
 	})
 	return err
 }
+
+// UploadURLTTL is how long presigned upload URLs stay valid.
+func (s *Storage) UploadURLTTL() time.Duration {
+	return s.uploadURLTTL
+}
+
+// PresignPut returns a URL the client can PUT the object to. The client has
+// to send the given Content-Type, since it is part of the signature.
+func (s *Storage) PresignPut(ctx context.Context, key string, contentType string, expires time.Duration) (string, error) {
+	presigned, err := s3.NewPresignClient(s.client).PresignPutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(key),
+		ContentType: aws.String(contentType),
+	}, s3.WithPresignExpires(expires))
+	if err != nil {
+		return "", err
+	}
+	return presigned.URL, nil
+}
+
+func (s *Storage) PresignGet(ctx context.Context, key string, expires time.Duration) (string, error) {
+	presigned, err := s3.NewPresignClient(s.client).PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	}, s3.WithPresignExpires(expires))
+	if err != nil {
+		return "", err
+	}
+	return presigned.URL, nil
+}
+
+// Stat looks an object up with a HEAD request. It returns ErrObjectNotFound
+// if there is no such object.
+func (s *Storage) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	output, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	var notFound *types.NotFound
+	if errors.As(err, &notFound) {
+		return nil, ErrObjectNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &ObjectInfo{
+		Size:        aws.ToInt64(output.ContentLength),
+		ContentType: aws.ToString(output.ContentType),
+	}, nil
+}
+
+// DeletePrefix deletes every object whose key starts with prefix and returns
+// the deleted keys.
+func (s *Storage) DeletePrefix(ctx context.Context, prefix string) ([]string, error) {
+	deleted := []string{}
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return deleted, err
+		}
+		if len(page.Contents) == 0 {
+			continue
+		}
+
+		objects := make([]types.ObjectIdentifier, 0, len(page.Contents))
+		for _, object := range page.Contents {
+			objects = append(objects, types.ObjectIdentifier{Key: object.Key})
+		}
+		output, err := s.client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(s.bucket),
+			Delete: &types.Delete{Objects: objects, Quiet: aws.Bool(true)},
+		})
+		if err != nil {
+			return deleted, err
+		}
+		if len(output.Errors) > 0 {
+			failed := output.Errors[0]
+			return deleted, fmt.Errorf("deleting %s: %s", aws.ToString(failed.Key), aws.ToString(failed.Message))
+		}
+		for _, object := range objects {
+			deleted = append(deleted, aws.ToString(object.Key))
+		}
+	}
+	return deleted, nil
+}
@@ -0,0 +1,119 @@
+package helpers
+
+import (
+	"context"
+	"time"
+
+	"golang-speakbackend/models"
+	"golang-speakbackend/repositories"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	FrequencyDaily  = "daily"
+	FrequencyWeekly = "weekly"
+
+	// ScheduleHorizon is how far ahead of today occurrences are generated,
+	// so the "upcoming" view has something to show.
+	ScheduleHorizon = 7 * 24 * time.Hour
+)
+
+// StartOfDay truncates t to midnight in its location.
+func StartOfDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
+
+// ScheduleOccurrences returns the days after `after` and up to and including
+// `through` on which the schedule has a session.
+func ScheduleOccurrences(schedule models.AssignmentSchedule, after time.Time, through time.Time) []time.Time {
+	days := map[time.Weekday]bool{}
+	for _, day := range schedule.DaysOfWeek {
+		days[time.Weekday(day)] = true
+	}
+
+	first := StartOfDay(schedule.StartDate)
+	if next := StartOfDay(after).AddDate(0, 0, 1); next.After(first) {
+		first = next
+	}
+	last := StartOfDay(schedule.EndDate)
+	if through.Before(last) {
+		last = StartOfDay(through)
+	}
+
+	var occurrences []time.Time
+	for day := first; !day.After(last); day = day.AddDate(0, 0, 1) {
+		if schedule.Frequency == FrequencyDaily || days[day.Weekday()] {
+			occurrences = append(occurrences, day)
+		}
+	}
+	return occurrences
+}
+
+// GenerateScheduledExercises creates the dated PatientExercise occurrences of
+// every schedule of the patient up to `through`. Each schedule remembers how
+// far it has been generated, so calling this repeatedly is cheap and never
+// creates duplicates.
+func GenerateScheduledExercises(ctx context.Context, store *repositories.Store, patientID string, through time.Time, initialStatus string) error {
+	schedules, err := store.Schedules.ListByPatient(ctx, patientID)
+	if err != nil {
+		return err
+	}
+
+	for _, schedule := range schedules {
+		if err := generateOccurrences(ctx, store, schedule, through, initialStatus); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func generateOccurrences(ctx context.Context, store *repositories.Store, schedule models.AssignmentSchedule, through time.Time, initialStatus string) error {
+	through = StartOfDay(through)
+	if end := StartOfDay(schedule.EndDate); end.Before(through) {
+		through = end
+	}
+	if !through.After(schedule.GeneratedThrough) {
+		return nil
+	}
+
+	// Claim the range first so concurrent readers don't generate it twice
+	claimed, err := store.Schedules.AdvanceGeneratedThrough(ctx, schedule.ScheduleID, schedule.GeneratedThrough, through)
+	if err != nil || !claimed {
+		return err
+	}
+
+	occurrences := ScheduleOccurrences(schedule, schedule.GeneratedThrough, through)
+	if len(occurrences) == 0 {
+		return nil
+	}
+
+	now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	var patientExercises []models.PatientExercise
+	for _, dueDate := range occurrences {
+		dueDate := dueDate
+		patientExercise := models.PatientExercise{
+			ID:          primitive.NewObjectID(),
+			PatientID:   schedule.PatientID,
+			TherapistID: schedule.TherapistID,
+			AuthoredBy:  schedule.AuthoredBy,
+			ExerciseID:  schedule.ExerciseID,
+			Status:      initialStatus,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+			ScheduleID:  schedule.ScheduleID,
+			DueDate:     &dueDate,
+			Repetitions: schedule.RepetitionsPerSession,
+		}
+		patientExercise.PatientExerciseID = patientExercise.ID.Hex()
+		patientExercises = append(patientExercises, patientExercise)
+	}
+
+	if err := store.PatientExercises.InsertMany(ctx, patientExercises); err != nil {
+		// Give the range back so the next read retries it
+		store.Schedules.AdvanceGeneratedThrough(ctx, schedule.ScheduleID, through, schedule.GeneratedThrough)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"golang-speakbackend/models"
+	"golang-speakbackend/repositories"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	// ErrTokenRevoked is returned for tokens of a session that was logged out
+	// or revoked, and for tokens issued before sessions existed.
+	ErrTokenRevoked = errors.New("the token has been revoked")
+	// ErrTokenReused is returned when a refresh token that was already
+	// rotated is presented again. The whole session is revoked.
+	ErrTokenReused = errors.New("the refresh token has already been used; the session has been revoked")
+)
+
+// StartSession opens a new token family for the user and signs its first
+// access and refresh tokens. amr lists how the user logged in.
+func StartSession(ctx context.Context, store *repositories.Store, tokens *TokenMaker, user *models.User, amr ...string) (string, string, error) {
+	id := primitive.NewObjectID()
+	refreshTokenID := primitive.NewObjectID().Hex()
+	family := &models.TokenFamily{
+		ID:             id,
+		FamilyID:       id.Hex(),
+		UserID:         user.UserID,
+		CurrentTokenID: refreshTokenID,
+		AMR:            amr,
+		CreatedAt:      time.Now().UTC(),
+	}
+	if err := store.TokenFamilies.Insert(ctx, family); err != nil {
+		return "", "", err
+	}
+	return tokens.GenerateAllTokens(*user.Email, *user.FirstName, *user.LastName, user.Role, user.UserID, family.FamilyID, refreshTokenID, family.AMR)
+}
+
+// RotateSession exchanges a validated refresh token for a new token pair.
+// Presenting a refresh token that was already exchanged revokes the session,
+// since either it or its successor must have been stolen.
+func RotateSession(ctx context.Context, store *repositories.Store, tokens *TokenMaker, claims *SignedDetails, user *models.User) (string, string, error) {
+	family, err := findSession(ctx, store, claims)
+	if err != nil {
+		return "", "", err
+	}
+
+	now := time.Now().UTC()
+	refreshTokenID := primitive.NewObjectID().Hex()
+	if claims.Id == family.CurrentTokenID {
+		err = store.TokenFamilies.Rotate(ctx, family.FamilyID, claims.Id, refreshTokenID, now)
+		if err == nil {
+			return tokens.GenerateAllTokens(*user.Email, *user.FirstName, *user.LastName, user.Role, user.UserID, family.FamilyID, refreshTokenID, family.AMR)
+		}
+		if !errors.Is(err, repositories.ErrConflict) {
+			return "", "", err
+		}
+	}
+
+	log.Printf("Refresh token reuse detected for user %s, revoking session %s", user.UserID, family.FamilyID)
+	if err := store.TokenFamilies.Revoke(ctx, family.FamilyID, models.RevokedReuse, now); err != nil {
+		return "", "", err
+	}
+	return "", "", ErrTokenReused
+}
+
+// CheckSession returns ErrTokenRevoked unless the session the token belongs
+// to is still active.
+func CheckSession(ctx context.Context, store *repositories.Store, claims *SignedDetails) error {
+	_, err := findSession(ctx, store, claims)
+	return err
+}
+
+func findSession(ctx context.Context, store *repositories.Store, claims *SignedDetails) (*models.TokenFamily, error) {
+	if claims.Family == "" {
+		return nil, ErrTokenRevoked
+	}
+	family, err := store.TokenFamilies.FindByID(ctx, claims.Family)
+	if err == repositories.ErrNotFound {
+		return nil, ErrTokenRevoked
+	}
+	if err != nil {
+		return nil, err
+	}
+	if family.RevokedAt != nil || family.UserID != claims.UserID {
+		return nil, ErrTokenRevoked
+	}
+	return family, nil
+}
+
+// RevokeSession logs a single session out.
+func RevokeSession(ctx context.Context, store *repositories.Store, familyID string, reason string) error {
+	return store.TokenFamilies.Revoke(ctx, familyID, reason, time.Now().UTC())
+}
+
+// RevokeAllSessions logs the user out on every device.
+func RevokeAllSessions(ctx context.Context, store *repositories.Store, userID string, reason string) error {
+	return store.TokenFamilies.RevokeAllForUser(ctx, userID, reason, time.Now().UTC())
+}
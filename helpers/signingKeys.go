@@ -0,0 +1,238 @@
+package helpers
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+var errUnknownSigningKey = errors.New("the token was signed with an unknown key")
+
+// SigningMethodEdDSA signs JWTs with Ed25519 as described in RFC 8037. jwt-go
+// only ships HMAC, RSA and ECDSA, so it is registered here.
+var SigningMethodEdDSA = &signingMethodEd25519{}
+
+type signingMethodEd25519 struct{}
+
+func init() {
+	jwt.RegisterSigningMethod(SigningMethodEdDSA.Alg(), func() jwt.SigningMethod {
+		return SigningMethodEdDSA
+	})
+}
+
+func (m *signingMethodEd25519) Alg() string {
+	return "EdDSA"
+}
+
+func (m *signingMethodEd25519) Sign(signingString string, key interface{}) (string, error) {
+	privateKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return "", jwt.ErrInvalidKeyType
+	}
+	return jwt.EncodeSegment(ed25519.Sign(privateKey, []byte(signingString))), nil
+}
+
+func (m *signingMethodEd25519) Verify(signingString string, signature string, key interface{}) error {
+	publicKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return jwt.ErrInvalidKeyType
+	}
+	sig, err := jwt.DecodeSegment(signature)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(publicKey, []byte(signingString), sig) {
+		return jwt.ErrSignatureInvalid
+	}
+	return nil
+}
+
+// signingKey is a key tokens are signed or verified with. For HMAC the
+// private and public halves are the same secret. Keys only kept to verify
+// older tokens have no private half.
+type signingKey struct {
+	id      string
+	method  jwt.SigningMethod
+	private interface{}
+	public  interface{}
+}
+
+// newHMACSigningKey returns an HS256 key. Its ID is a fingerprint of the
+// secret, so rotated secrets get distinct IDs.
+func newHMACSigningKey(secret string) *signingKey {
+	sum := sha256.Sum256([]byte(secret))
+	return &signingKey{
+		id:      hex.EncodeToString(sum[:8]),
+		method:  jwt.SigningMethodHS256,
+		private: []byte(secret),
+		public:  []byte(secret),
+	}
+}
+
+// loadSigningKey reads a PEM key for the algorithm: a private key (PKCS#8,
+// or PKCS#1 for RSA), or a public key (PKIX) that can only verify. Its ID is
+// a fingerprint of the public key.
+func loadSigningKey(algorithm string, path string) (*signingKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading signing key: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("signing key %s is not PEM encoded", path)
+	}
+
+	var parsed interface{}
+	if block.Type == "PUBLIC KEY" {
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	} else {
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing signing key %s: %w", path, err)
+	}
+
+	key := &signingKey{}
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.method, key.private, key.public = jwt.SigningMethodRS256, k, &k.PublicKey
+	case *rsa.PublicKey:
+		key.method, key.public = jwt.SigningMethodRS256, k
+	case ed25519.PrivateKey:
+		key.method, key.private, key.public = SigningMethodEdDSA, k, k.Public()
+	case ed25519.PublicKey:
+		key.method, key.public = SigningMethodEdDSA, k
+	default:
+		return nil, fmt.Errorf("signing key %s has unsupported type %T", path, parsed)
+	}
+	if key.method.Alg() != algorithm {
+		return nil, fmt.Errorf("signing key %s is a %s key, not %s", path, key.method.Alg(), algorithm)
+	}
+
+	key.id, err = publicKeyFingerprint(key.public)
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// loadSigningKeyDir loads every *.pem file in dir.
+func loadSigningKeyDir(algorithm string, dir string) ([]*signingKey, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	keys := make([]*signingKey, 0, len(paths))
+	for _, path := range paths {
+		key, err := loadSigningKey(algorithm, path)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func publicKeyFingerprint(public crypto.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:8]), nil
+}
+
+// KeyRing holds the one key new tokens are signed with and every key tokens
+// are still accepted with, looked up by the kid header.
+type KeyRing struct {
+	signing *signingKey
+	keys    map[string]*signingKey
+}
+
+func newKeyRing(signing *signingKey, previous ...*signingKey) (*KeyRing, error) {
+	if signing.private == nil {
+		return nil, fmt.Errorf("signing key %s has no private key", signing.id)
+	}
+	ring := &KeyRing{signing: signing, keys: map[string]*signingKey{signing.id: signing}}
+	for _, key := range previous {
+		if key.method.Alg() != signing.method.Alg() {
+			return nil, fmt.Errorf("key %s is a %s key, not %s", key.id, key.method.Alg(), signing.method.Alg())
+		}
+		if _, ok := ring.keys[key.id]; !ok {
+			ring.keys[key.id] = key
+		}
+	}
+	return ring, nil
+}
+
+func (r *KeyRing) find(kid string) *signingKey {
+	return r.keys[kid]
+}
+
+// JWK is one public key in the JSON Web Key format of RFC 7517.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKSet is the document served at /.well-known/jwks.json.
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public keys of the ring, the signing key first. HMAC
+// secrets are never published.
+func (r *KeyRing) JWKS() JWKSet {
+	ids := make([]string, 0, len(r.keys))
+	for id := range r.keys {
+		if id != r.signing.id {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	ids = append([]string{r.signing.id}, ids...)
+
+	set := JWKSet{Keys: []JWK{}}
+	for _, id := range ids {
+		key := r.keys[id]
+		jwk := JWK{Kid: key.id, Use: "sig", Alg: key.method.Alg()}
+		switch public := key.public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		default:
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
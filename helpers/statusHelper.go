@@ -0,0 +1,137 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang-speakbackend/models"
+	"golang-speakbackend/repositories"
+)
+
+// RoleSystem is the actor role used for transitions made by background jobs.
+const RoleSystem = "system"
+
+var ErrIllegalTransition = errors.New("illegal status transition")
+
+// statusTransitions lists, for every status, the statuses it may move to and
+// the roles allowed to make that move. Admins and the system may make any
+// allowed move; an empty role list means only they may.
+var statusTransitions = map[string]map[string][]string{
+	models.StatusAssigned: {
+		models.StatusInProgress:    {RolePatient},
+		models.StatusUploadPending: {RolePatient},
+		models.StatusSubmitted:     {RolePatient},
+		models.StatusArchived:      {RoleTherapist},
+	},
+	models.StatusInProgress: {
+		models.StatusUploadPending: {RolePatient},
+		models.StatusSubmitted:     {RolePatient},
+		models.StatusArchived:      {RoleTherapist},
+	},
+	// A new upload URL may be requested while one is pending. Stale uploads
+	// are sent back to where they came from by the upload sweeper.
+	models.StatusUploadPending: {
+		models.StatusUploadPending: {RolePatient},
+		models.StatusSubmitted:     {RolePatient},
+		models.StatusAssigned:      {},
+		models.StatusInProgress:    {},
+		models.StatusNeedsRedo:     {},
+	},
+	models.StatusSubmitted: {
+		models.StatusReviewed:  {RoleTherapist},
+		models.StatusNeedsRedo: {RoleTherapist},
+	},
+	models.StatusReviewed: {
+		models.StatusNeedsRedo: {RoleTherapist},
+		models.StatusArchived:  {RoleTherapist},
+	},
+	models.StatusNeedsRedo: {
+		models.StatusInProgress:    {RolePatient},
+		models.StatusUploadPending: {RolePatient},
+		models.StatusSubmitted:     {RolePatient},
+		models.StatusReviewed:      {RoleTherapist},
+		models.StatusArchived:      {RoleTherapist},
+	},
+	models.StatusArchived: {},
+}
+
+// uploadStatuses are only reached through the upload handlers, which check
+// that a recording attempt exists and was actually uploaded.
+var uploadStatuses = map[string]bool{
+	models.StatusUploadPending: true,
+	models.StatusSubmitted:     true,
+}
+
+// legacyStatuses maps the statuses written before the lifecycle existed.
+var legacyStatuses = map[string]string{
+	"pending":   models.StatusAssigned,
+	"completed": models.StatusSubmitted,
+}
+
+// NormalizeStatus returns the lifecycle status for a stored status,
+// translating legacy values.
+func NormalizeStatus(status string) string {
+	if mapped, ok := legacyStatuses[strings.ToLower(status)]; ok {
+		return mapped
+	}
+	return status
+}
+
+// IsOpenStatus reports whether the patient still has work to do.
+func IsOpenStatus(status string) bool {
+	switch NormalizeStatus(status) {
+	case models.StatusAssigned, models.StatusInProgress, models.StatusUploadPending, models.StatusNeedsRedo:
+		return true
+	}
+	return false
+}
+
+// CheckTransition returns ErrIllegalTransition unless a user with the given
+// role may move a patient exercise from one status to the other.
+func CheckTransition(from string, to string, role string) error {
+	from = NormalizeStatus(from)
+	roles, ok := statusTransitions[from][to]
+	if !ok {
+		return fmt.Errorf("%w: %s to %s", ErrIllegalTransition, from, to)
+	}
+	if role == RoleAdmin || role == RoleSystem {
+		return nil
+	}
+	for _, allowed := range roles {
+		if allowed == role {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s cannot move %s to %s", ErrIllegalTransition, role, from, to)
+}
+
+// CheckManualTransition is CheckTransition for status changes requested
+// directly rather than by uploading a recording. Those can't submit work.
+func CheckManualTransition(from string, to string, role string) error {
+	if uploadStatuses[to] {
+		return fmt.Errorf("%w: %s is only reached by uploading a recording", ErrIllegalTransition, to)
+	}
+	return CheckTransition(from, to, role)
+}
+
+// TransitionPatientExercise moves the patient exercise to a new status,
+// applying any other field changes in the same write and recording the
+// transition. It fails with ErrIllegalTransition if the move is not allowed
+// and with repositories.ErrConflict if the status changed underneath us.
+func TransitionPatientExercise(ctx context.Context, store *repositories.Store, patientExercise *models.PatientExercise, to string, actorID string, actorRole string, update repositories.PatientExerciseUpdate) error {
+	if err := CheckTransition(patientExercise.Status, to, actorRole); err != nil {
+		return err
+	}
+
+	transition := models.StatusTransition{
+		From:      NormalizeStatus(patientExercise.Status),
+		To:        to,
+		ActorID:   actorID,
+		ActorRole: actorRole,
+		At:        time.Now().UTC(),
+	}
+	return store.PatientExercises.Transition(ctx, patientExercise.PatientExerciseID, patientExercise.Status, transition, update)
+}
@@ -0,0 +1,58 @@
+package helpers
+
+import (
+	"errors"
+	"testing"
+
+	"golang-speakbackend/models"
+)
+
+func TestCheckTransition(t *testing.T) {
+	tests := []struct {
+		from, to, role string
+		allowed        bool
+	}{
+		{models.StatusAssigned, models.StatusInProgress, RolePatient, true},
+		{models.StatusAssigned, models.StatusInProgress, RoleTherapist, false},
+		{models.StatusInProgress, models.StatusSubmitted, RolePatient, true},
+		{models.StatusSubmitted, models.StatusReviewed, RoleTherapist, true},
+		{models.StatusSubmitted, models.StatusReviewed, RolePatient, false},
+		{models.StatusSubmitted, models.StatusInProgress, RolePatient, false},
+		{models.StatusReviewed, models.StatusArchived, RoleTherapist, true},
+		{models.StatusNeedsRedo, models.StatusInProgress, RolePatient, true},
+		{models.StatusArchived, models.StatusAssigned, RoleAdmin, false},
+		{models.StatusArchived, models.StatusReviewed, RoleTherapist, false},
+		// Only admins and background jobs send stale uploads back
+		{models.StatusUploadPending, models.StatusInProgress, RolePatient, false},
+		{models.StatusUploadPending, models.StatusInProgress, RoleSystem, true},
+		{models.StatusUploadPending, models.StatusInProgress, RoleAdmin, true},
+		// Legacy statuses are read as their lifecycle equivalents
+		{"pending", models.StatusInProgress, RolePatient, true},
+		{"completed", models.StatusReviewed, RoleTherapist, true},
+	}
+	for _, test := range tests {
+		err := CheckTransition(test.from, test.to, test.role)
+		if test.allowed && err != nil {
+			t.Errorf("%s moving %s to %s: %v", test.role, test.from, test.to, err)
+		}
+		if !test.allowed && !errors.Is(err, ErrIllegalTransition) {
+			t.Errorf("%s moving %s to %s: got %v, want ErrIllegalTransition", test.role, test.from, test.to, err)
+		}
+	}
+}
+
+func TestCheckManualTransition(t *testing.T) {
+	for _, to := range []string{models.StatusUploadPending, models.StatusSubmitted} {
+		for _, role := range []string{RolePatient, RoleTherapist, RoleAdmin} {
+			if err := CheckManualTransition(models.StatusInProgress, to, role); !errors.Is(err, ErrIllegalTransition) {
+				t.Errorf("%s moving to %s by hand: got %v, want ErrIllegalTransition", role, to, err)
+			}
+		}
+	}
+	if err := CheckManualTransition(models.StatusAssigned, models.StatusInProgress, RolePatient); err != nil {
+		t.Errorf("patient starting work: %v", err)
+	}
+	if err := CheckManualTransition(models.StatusAssigned, models.StatusInProgress, RoleTherapist); !errors.Is(err, ErrIllegalTransition) {
+		t.Errorf("therapist starting work: got %v, want ErrIllegalTransition", err)
+	}
+}
@@ -2,54 +2,179 @@ package helpers
 
 import (
 	"context"
-	"golang-speakbackend/database"
+	"fmt"
+	"golang-speakbackend/config"
+	"golang-speakbackend/repositories"
 	"log"
-	"os"
+	"strings"
 	"time"
 
 	jwt "github.com/dgrijalva/jwt-go"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// Token types, carried in the typ claim. An access token is never accepted
+// where a refresh token is expected and the other way round.
+const (
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+	// TokenTypeMFAChallenge proves the password step of a login and is only
+	// good for completing it with a second factor.
+	TokenTypeMFAChallenge = "mfa_challenge"
+)
+
+// Authentication methods (RFC 8176) recorded in the amr claim.
+const (
+	AMRPassword = "pwd"
+	AMRMFA      = "mfa"
+)
+
+// HasMFA reports whether a second factor was used for the login.
+func HasMFA(amr []string) bool {
+	for _, method := range amr {
+		if method == AMRMFA {
+			return true
+		}
+	}
+	return false
+}
+
 type SignedDetails struct {
 	Email     string
 	FirstName string
 	LastName  string
+	Role      string
 	UserID    string
+	// Family is the login session the token belongs to.
+	Family string
+	Type   string   `json:"typ"`
+	AMR    []string `json:"amr,omitempty"`
 	jwt.StandardClaims
 }
 
-var userCollection *mongo.Collection = database.OpenCollection(database.Client, "user")
+// TokenMaker signs and validates the JWTs handed out to clients. Access and
+// refresh tokens have their own key rings.
+type TokenMaker struct {
+	accessKeys      *KeyRing
+	refreshKeys     *KeyRing
+	issuer          string
+	audience        string
+	accessTokenTTL  time.Duration
+	refreshTokenTTL time.Duration
+	mfaChallengeTTL time.Duration
+}
+
+func NewTokenMaker(cfg config.AuthConfig) (*TokenMaker, error) {
+	t := &TokenMaker{
+		issuer:          cfg.Issuer,
+		audience:        cfg.Audience,
+		accessTokenTTL:  cfg.AccessTokenTTL.Duration,
+		refreshTokenTTL: cfg.RefreshTokenTTL.Duration,
+		mfaChallengeTTL: cfg.MFAChallengeTTL.Duration,
+	}
+
+	var accessKey, refreshKey *signingKey
+	var previousKeys []*signingKey
+	var err error
+	switch cfg.Algorithm {
+	case "HS256":
+		accessKey = newHMACSigningKey(cfg.SecretKey)
+		refreshKey = newHMACSigningKey(cfg.RefreshSecretKey)
+		for _, secret := range cfg.PreviousSecretKeys {
+			if secret = strings.TrimSpace(secret); secret != "" {
+				previousKeys = append(previousKeys, newHMACSigningKey(secret))
+			}
+		}
+	case "RS256", "EdDSA":
+		if accessKey, err = loadSigningKey(cfg.Algorithm, cfg.AccessPrivateKeyFile); err != nil {
+			return nil, err
+		}
+		if refreshKey, err = loadSigningKey(cfg.Algorithm, cfg.RefreshPrivateKeyFile); err != nil {
+			return nil, err
+		}
+		if cfg.AccessVerifyKeyDir != "" {
+			if previousKeys, err = loadSigningKeyDir(cfg.Algorithm, cfg.AccessVerifyKeyDir); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported token algorithm %q", cfg.Algorithm)
+	}
+
+	if t.accessKeys, err = newKeyRing(accessKey, previousKeys...); err != nil {
+		return nil, err
+	}
+	if t.refreshKeys, err = newKeyRing(refreshKey); err != nil {
+		return nil, err
+	}
+	if t.accessKeys.find(refreshKey.id) != nil {
+		return nil, fmt.Errorf("access and refresh tokens must be signed with different keys")
+	}
+	return t, nil
+}
+
+func (t *TokenMaker) keysFor(tokenType string) *KeyRing {
+	if tokenType == TokenTypeRefresh {
+		return t.refreshKeys
+	}
+	return t.accessKeys
+}
+
+// JWKS returns the public keys access tokens can be verified with, for
+// other services and the mobile app.
+func (t *TokenMaker) JWKS() JWKSet {
+	return t.accessKeys.JWKS()
+}
+
+func (t *TokenMaker) sign(claims *SignedDetails, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims.Issuer = t.issuer
+	claims.Audience = t.audience
+	claims.IssuedAt = now.Unix()
+	claims.NotBefore = now.Unix()
+	claims.ExpiresAt = now.Add(ttl).Unix()
+	if claims.Id == "" {
+		claims.Id = primitive.NewObjectID().Hex()
+	}
 
-var SECRET_KEY string = os.Getenv("SECRET_KEY")
+	key := t.keysFor(claims.Type).signing
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = key.id
+	return token.SignedString(key.private)
+}
 
-func GenerateAllTokens(email string, firstName string, lastName string, userID string) (signedToken string, signedRefreshToken string, err error) {
+// GenerateAllTokens signs an access and a refresh token for the session
+// familyID. refreshTokenID identifies the refresh token within the family and
+// amr lists how the user logged in.
+func (t *TokenMaker) GenerateAllTokens(email string, firstName string, lastName string, role string, userID string, familyID string, refreshTokenID string, amr []string) (signedToken string, signedRefreshToken string, err error) {
 	claims := &SignedDetails{
 		Email:     email,
 		FirstName: firstName,
 		LastName:  lastName,
+		Role:      role,
 		UserID:    userID,
-		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Local().Add(time.Hour * time.Duration(24)).Unix(), // 1 day expiry
-		},
+		Family:    familyID,
+		Type:      TokenTypeAccess,
+		AMR:       amr,
 	}
 
 	refreshClaims := &SignedDetails{
 		UserID: userID,
 		Email:  email, // Include minimal necessary information
+		Family: familyID,
+		Type:   TokenTypeRefresh,
 		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Local().Add(time.Hour * time.Duration(24*7)).Unix(), // 1 week expiry
+			Id: refreshTokenID,
 		},
 	}
 
-	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(SECRET_KEY))
+	token, err := t.sign(claims, t.accessTokenTTL)
 	if err != nil {
 		log.Panic(err)
 		return
 	}
 
-	refreshToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims).SignedString([]byte(SECRET_KEY))
+	refreshToken, err := t.sign(refreshClaims, t.refreshTokenTTL)
 	if err != nil {
 		log.Panic(err)
 		return
@@ -58,55 +183,83 @@ func GenerateAllTokens(email string, firstName string, lastName string, userID s
 	return token, refreshToken, err
 }
 
-func UpdateAllTokens(signedToken string, signedRefreshToken string, userID string) error {
+// GenerateMFAChallenge signs the short-lived token a login returns after the
+// password step when the user has MFA enabled.
+func (t *TokenMaker) GenerateMFAChallenge(userID string, email string) (string, error) {
+	return t.sign(&SignedDetails{
+		UserID: userID,
+		Email:  email,
+		Type:   TokenTypeMFAChallenge,
+		AMR:    []string{AMRPassword},
+	}, t.mfaChallengeTTL)
+}
+
+func UpdateAllTokens(users repositories.UserRepository, signedToken string, signedRefreshToken string, userID string) error {
 	var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 	defer cancel()
 
-	filter := bson.M{"user_id": userID}
-
-	update := bson.D{
-		{Key: "$set", Value: bson.D{
-			{Key: "token", Value: signedToken},
-			{Key: "refresh_token", Value: signedRefreshToken},
-			{Key: "updated_at", Value: time.Now()},
-		}},
+	err := users.Update(ctx, userID, repositories.UserUpdate{
+		Token:        &signedToken,
+		RefreshToken: &signedRefreshToken,
+	})
+	if err == repositories.ErrNotFound {
+		log.Printf("No document matched for user ID %s. No update occurred.", userID)
+		return nil
 	}
-
-	result, err := userCollection.UpdateOne(ctx, filter, update)
 	if err != nil {
 		log.Printf("Could not update tokens for user %s: %v", userID, err)
 		return err
 	}
 
-	if result.MatchedCount == 0 {
-		log.Printf("No document matched for user ID %s. No update occurred.", userID)
-	} else {
-		log.Printf("Updated tokens for user ID %s", userID)
-	}
-
+	log.Printf("Updated tokens for user ID %s", userID)
 	return nil
 }
 
-func ValidateToken(signedToken string) (claims *SignedDetails, msg string) {
-
+// ValidateToken checks the signature, lifetime, issuer and audience of a
+// token and that it is of the expected type.
+func (t *TokenMaker) ValidateToken(signedToken string, tokenType string) (claims *SignedDetails, msg string) {
 	token, err := jwt.ParseWithClaims(
 		signedToken,
 		&SignedDetails{},
 		func(token *jwt.Token) (interface{}, error) {
-			return []byte(SECRET_KEY), nil
+			kid, _ := token.Header["kid"].(string)
+			key := t.keysFor(tokenType).find(kid)
+			if key == nil {
+				if t.accessKeys.find(kid) != nil || t.refreshKeys.find(kid) != nil {
+					return nil, fmt.Errorf("wrong token type, expected %s", tokenType)
+				}
+				return nil, errUnknownSigningKey
+			}
+			if token.Method.Alg() != key.method.Alg() {
+				return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+			}
+			return key.public, nil
 		},
 	)
 
-	claims, ok := token.Claims.(*SignedDetails)
-	if !ok {
-		// msg = fmt.Sprint("the token is invalid")
+	if err != nil {
 		msg = err.Error()
 		return
 	}
 
+	claims, ok := token.Claims.(*SignedDetails)
+	if !ok || !token.Valid {
+		msg = fmt.Sprint("the token is invalid")
+		return
+	}
+
+	if claims.Type != tokenType {
+		msg = fmt.Sprintf("wrong token type, expected %s", tokenType)
+		return nil, msg
+	}
+
+	if !claims.VerifyIssuer(t.issuer, true) || !claims.VerifyAudience(t.audience, true) {
+		msg = fmt.Sprint("the token is invalid")
+		return nil, msg
+	}
+
 	if claims.ExpiresAt < time.Now().Local().Unix() {
-		// msg = fmt.Sprint("the token has expired")
-		msg = err.Error()
+		msg = fmt.Sprint("the token has expired")
 		return
 	}
 
@@ -0,0 +1,75 @@
+package helpers
+
+import (
+	"testing"
+
+	"golang-speakbackend/config"
+)
+
+func newTestTokenMaker(t *testing.T) *TokenMaker {
+	t.Helper()
+	cfg := config.Default().Auth
+	cfg.SecretKey = "test-access-secret"
+	cfg.RefreshSecretKey = "test-refresh-secret"
+	tokens, err := NewTokenMaker(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tokens
+}
+
+func TestValidateTokenType(t *testing.T) {
+	tokens := newTestTokenMaker(t)
+	access, refresh, err := tokens.GenerateAllTokens("p@example.com", "Pat", "Ient", RolePatient, "user", "family", "refresh", []string{AMRPassword})
+	if err != nil {
+		t.Fatal(err)
+	}
+	challenge, err := tokens.GenerateMFAChallenge("user", "p@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name, token, tokenType string
+		valid                  bool
+	}{
+		{"access as access", access, TokenTypeAccess, true},
+		{"refresh as refresh", refresh, TokenTypeRefresh, true},
+		{"challenge as challenge", challenge, TokenTypeMFAChallenge, true},
+		{"refresh as access", refresh, TokenTypeAccess, false},
+		{"access as refresh", access, TokenTypeRefresh, false},
+		{"challenge as access", challenge, TokenTypeAccess, false},
+		{"challenge as refresh", challenge, TokenTypeRefresh, false},
+		{"access as challenge", access, TokenTypeMFAChallenge, false},
+		{"refresh as challenge", refresh, TokenTypeMFAChallenge, false},
+	}
+	for _, test := range tests {
+		claims, msg := tokens.ValidateToken(test.token, test.tokenType)
+		if test.valid && (claims == nil || msg != "") {
+			t.Errorf("%s: rejected with %q", test.name, msg)
+		}
+		if !test.valid && (claims != nil || msg == "") {
+			t.Errorf("%s: accepted", test.name)
+		}
+	}
+}
+
+func TestValidateTokenOtherService(t *testing.T) {
+	tokens := newTestTokenMaker(t)
+	cfg := config.Default().Auth
+	cfg.SecretKey = "test-access-secret"
+	cfg.RefreshSecretKey = "test-refresh-secret"
+	cfg.Audience = "another-api"
+	other, err := NewTokenMaker(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	access, _, err := other.GenerateAllTokens("p@example.com", "Pat", "Ient", RolePatient, "user", "family", "refresh", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims, _ := tokens.ValidateToken(access, TokenTypeAccess); claims != nil {
+		t.Error("accepted a token for another audience")
+	}
+}
@@ -0,0 +1,209 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang-speakbackend/models"
+	"golang-speakbackend/repositories"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// RecordingContentType is the Content-Type recordings are uploaded with.
+	RecordingContentType = "video/mp4"
+	// MaxRecordingSize matches the limit on direct multipart uploads.
+	MaxRecordingSize = 100 << 20
+)
+
+var ErrInvalidUpload = errors.New("invalid upload")
+
+// The file types accepted for direct recording and profile image uploads.
+var (
+	recordingExtensions    = map[string]bool{"mp4": true, "mov": true, "m4a": true, "webm": true}
+	profileImageExtensions = map[string]bool{"jpg": true, "jpeg": true, "png": true, "webp": true}
+)
+
+// RecordingExtension returns the lower case extension of an uploaded
+// recording's file name, and false if it is missing or not a recording type.
+func RecordingExtension(filename string) (string, bool) {
+	extension := fileExtension(filename)
+	return extension, recordingExtensions[extension]
+}
+
+// ProfileImageExtension is RecordingExtension for profile images.
+func ProfileImageExtension(filename string) (string, bool) {
+	extension := fileExtension(filename)
+	return extension, profileImageExtensions[extension]
+}
+
+func fileExtension(filename string) string {
+	return strings.ToLower(strings.TrimPrefix(filepath.Ext(filename), "."))
+}
+
+// RecordingKey is the object key recordings had before every upload became
+// its own attempt. It is only used to read those older recordings.
+func RecordingKey(patientExerciseID string) string {
+	return fmt.Sprintf("recordings/%s.mp4", patientExerciseID)
+}
+
+// AttemptKey is the object key of one recording attempt.
+func AttemptKey(patientExerciseID string, attemptID string, extension string) string {
+	return fmt.Sprintf("recordings/%s/%s.%s", patientExerciseID, attemptID, extension)
+}
+
+// NewRecordingAttempt prepares the next pending attempt for a patient
+// exercise. The caller fills in the rest and inserts it.
+func NewRecordingAttempt(ctx context.Context, store *repositories.Store, patientExerciseID string, extension string) (*models.RecordingAttempt, error) {
+	attempts, err := store.Attempts.ListByPatientExercise(ctx, patientExerciseID)
+	if err != nil {
+		return nil, err
+	}
+
+	id := primitive.NewObjectID()
+	attemptID := id.Hex()
+	return &models.RecordingAttempt{
+		ID:                id,
+		AttemptID:         attemptID,
+		PatientExerciseID: patientExerciseID,
+		Number:            len(attempts) + 1,
+		Status:            models.AttemptPending,
+		ObjectKey:         AttemptKey(patientExerciseID, attemptID, extension),
+		CreatedAt:         time.Now().UTC(),
+	}, nil
+}
+
+// LatestUploadedAttempt returns the most recent attempt that finished
+// uploading, or nil if there is none.
+func LatestUploadedAttempt(ctx context.Context, store *repositories.Store, patientExerciseID string) (*models.RecordingAttempt, error) {
+	attempts, err := store.Attempts.ListByPatientExercise(ctx, patientExerciseID)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(attempts) - 1; i >= 0; i-- {
+		if attempts[i].Status == models.AttemptUploaded {
+			return &attempts[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// ExpireAttempt marks a pending attempt as abandoned. Attempts that were
+// uploaded in the meantime are left alone.
+func ExpireAttempt(ctx context.Context, store *repositories.Store, attemptID string) error {
+	attempt, err := store.Attempts.FindByID(ctx, attemptID)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if attempt.Status != models.AttemptPending {
+		return nil
+	}
+	status := models.AttemptExpired
+	return store.Attempts.Update(ctx, attemptID, repositories.RecordingAttemptUpdate{Status: &status})
+}
+
+// VerifyRecordingUpload checks that the recording exists in the bucket and
+// has a plausible size and the expected content type. It returns
+// ErrObjectNotFound if nothing was uploaded and ErrInvalidUpload if the
+// object is not acceptable.
+func VerifyRecordingUpload(ctx context.Context, storage *Storage, key string) (*ObjectInfo, error) {
+	info, err := storage.Stat(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if info.Size <= 0 || info.Size > MaxRecordingSize {
+		return nil, fmt.Errorf("%w: size %d bytes is outside 1..%d", ErrInvalidUpload, info.Size, MaxRecordingSize)
+	}
+	if info.ContentType != RecordingContentType {
+		return nil, fmt.Errorf("%w: content type %q, expected %q", ErrInvalidUpload, info.ContentType, RecordingContentType)
+	}
+	return info, nil
+}
+
+// statusBeforeUpload returns the status a patient exercise had before it
+// started waiting for an upload.
+func statusBeforeUpload(patientExercise models.PatientExercise) string {
+	for i := len(patientExercise.StatusHistory) - 1; i >= 0; i-- {
+		transition := patientExercise.StatusHistory[i]
+		if transition.To == models.StatusUploadPending && transition.From != models.StatusUploadPending {
+			return transition.From
+		}
+	}
+	return models.StatusAssigned
+}
+
+// SweepExpiredUploads sends every patient exercise whose upload URL expired
+// before now without the upload being confirmed back to its previous status.
+// It returns how many were reset.
+func SweepExpiredUploads(ctx context.Context, store *repositories.Store, now time.Time) (int, error) {
+	patientExercises, err := store.PatientExercises.ListExpiredUploads(ctx, now)
+	if err != nil {
+		return 0, err
+	}
+
+	reset := 0
+	for _, listed := range patientExercises {
+		// Re-read so an upload URL issued since we listed it isn't reset
+		patientExercise, err := store.PatientExercises.FindByID(ctx, listed.PatientExerciseID)
+		if errors.Is(err, repositories.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return reset, err
+		}
+		if patientExercise.Status != models.StatusUploadPending ||
+			patientExercise.UploadExpiresAt == nil || !patientExercise.UploadExpiresAt.Before(now) {
+			continue
+		}
+
+		err = TransitionPatientExercise(ctx, store, patientExercise, statusBeforeUpload(*patientExercise), "", RoleSystem, repositories.PatientExerciseUpdate{})
+		if errors.Is(err, repositories.ErrConflict) || errors.Is(err, repositories.ErrNotFound) {
+			// Confirmed, re-presigned or deleted since we listed it
+			continue
+		}
+		if err != nil {
+			return reset, err
+		}
+		if patientExercise.CurrentAttemptID != "" {
+			if err := ExpireAttempt(ctx, store, patientExercise.CurrentAttemptID); err != nil {
+				return reset, err
+			}
+		}
+		reset++
+	}
+	return reset, nil
+}
+
+// StartUploadSweeper runs SweepExpiredUploads every interval until ctx is done.
+func StartUploadSweeper(ctx context.Context, store *repositories.Store, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				sweepCtx, cancel := context.WithTimeout(ctx, interval)
+				reset, err := SweepExpiredUploads(sweepCtx, store, now.UTC())
+				cancel()
+				if err != nil {
+					log.Printf("Error sweeping expired uploads: %v", err)
+					continue
+				}
+				if reset > 0 {
+					log.Printf("Reset %d expired recording uploads", reset)
+				}
+			}
+		}
+	}()
+}
@@ -1,58 +1,68 @@
 package main
 
 import (
-	controller "golang-speakbackend/controllers"
+	"context"
+	"golang-speakbackend/config"
+	"golang-speakbackend/database"
+	"golang-speakbackend/helpers"
+	"golang-speakbackend/repositories"
 	routes "golang-speakbackend/routes"
-	// "log"
-	"os"
-
-	"github.com/gin-contrib/cors"
-	"github.com/gin-gonic/gin"
-	// "github.com/joho/godotenv"
+	"log"
 )
 
+func main() {
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Error loading configuration: %v", err)
+	}
 
-// func init() {
-// 	err := godotenv.Load()
-// 	if err != nil {
-// 		log.Fatalf("Error loading .env file")
-// 	}
-// }
+	storage, err := helpers.NewStorage(cfg.Storage)
+	if err != nil {
+		log.Fatalf("Error creating storage client: %v", err)
+	}
 
-func main() {
-	port := os.Getenv("PORT")
+	keys, err := helpers.NewKeyWrapper(cfg.KMS)
+	if err != nil {
+		log.Fatalf("Error creating key wrapper: %v", err)
+	}
+	log.Printf("Storage session created, wrapping keys with %s (%s)", cfg.KMS.Provider, keys.KeyID())
 
-	if port == "" {
-		port = "8080"
+	mailer, err := helpers.NewMailer(cfg.Mail)
+	if err != nil {
+		log.Fatalf("Error creating mailer: %v", err)
 	}
 
-	router := gin.New()
-	router.Use(gin.Logger())
-
-	// Enable CORS
-	router.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{"*"},
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE"},
-		AllowHeaders:     []string{"Origin", "Content-Type"},
-		ExposeHeaders:    []string{"Content-Length"},
-		AllowCredentials: true,
-	}))
-
-	// Public routes
-	publicRoutes := router.Group("/")
-	{
-		publicRoutes.POST("/signup", controller.SignUp())
-		publicRoutes.POST("/login", controller.Login())
-		publicRoutes.POST("/refresh", controller.RefreshToken()) // Refresh token doesn't need auth middleware
+	tokens, err := helpers.NewTokenMaker(cfg.Auth)
+	if err != nil {
+		log.Fatalf("Error loading token signing keys: %v", err)
 	}
 
-	// Private routes
-	privateRoutes := router.Group("/")
-	{
-		routes.UserRoutes(privateRoutes)
-		routes.ExerciseRoutes(privateRoutes)
-		routes.PatientExerciseRoutes(privateRoutes)
+	client := database.DBInstance(cfg.Mongo)
+
+	store := repositories.NewMongoStore(database.OpenDatabase(client, cfg.Mongo))
+
+	// Care teams replaced reference code links between patients and therapists
+	if err := helpers.MigrateReferenceCodeLinks(context.Background(), store); err != nil {
+		log.Fatalf("Error migrating reference code links: %v", err)
+	}
+
+	// Reset recording uploads that were never confirmed
+	helpers.StartUploadSweeper(context.Background(), store, cfg.Storage.UploadSweepInterval.Duration)
+
+	// Purge deleted accounts once they can no longer be restored
+	helpers.StartAccountPurger(context.Background(), store, storage, cfg.Account.PurgeInterval.Duration)
+
+	router, err := routes.NewRouter(routes.Dependencies{
+		Config:  cfg,
+		Store:   store,
+		Storage: storage,
+		Keys:    keys,
+		Tokens:  tokens,
+		Mailer:  mailer,
+	})
+	if err != nil {
+		log.Fatalf("Error creating router: %v", err)
 	}
 
-	router.Run(":" + port)
+	router.Run(":" + cfg.Port)
 }
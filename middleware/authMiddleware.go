@@ -1,25 +1,44 @@
 package middleware
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"golang-speakbackend/helpers"
+	"golang-speakbackend/repositories"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
-func Authentication() gin.HandlerFunc {
+// Authentication accepts access tokens whose session has not been logged out
+// or revoked.
+func Authentication(tokens *helpers.TokenMaker, store *repositories.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		clientToken := c.Request.Header.Get("token")
 		if clientToken == "" {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprint("No Authorization header provided")})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": fmt.Sprint("No Authorization header provided")})
 			c.Abort()
 			return
 		}
 
-		claims, err := helpers.ValidateToken(clientToken)
+		claims, err := tokens.ValidateToken(clientToken, helpers.TokenTypeAccess)
 		if err != "" {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err})
+			c.Abort()
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		if err := helpers.CheckSession(ctx, store, claims); err != nil {
+			if err == helpers.ErrTokenRevoked {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while checking the session"})
+			}
 			c.Abort()
 			return
 		}
@@ -27,7 +46,118 @@ func Authentication() gin.HandlerFunc {
 		c.Set("email", claims.Email)
 		c.Set("first_name", claims.FirstName)
 		c.Set("last_name", claims.LastName)
+		c.Set("role", claims.Role)
 		c.Set("user_id", claims.UserID)
+		c.Set("family_id", claims.Family)
+		c.Set("amr", claims.AMR)
+		c.Next()
+	}
+}
+
+// RequireTherapistMFA turns therapists away until they log in with a second
+// factor, for clinics that enforce MFA.
+func RequireTherapistMFA() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("role") == helpers.RoleTherapist && !helpers.HasMFA(c.GetStringSlice("amr")) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "multi-factor authentication is required for therapist accounts"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireRoles only lets users with one of the given roles through.
+func RequireRoles(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := helpers.CheckUserType(c, roles...); err != nil {
+			abortWithPolicyError(c, err)
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireSelf only lets the user named by the route parameter (or an admin) through.
+func RequireSelf(param string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := helpers.MatchUserTypeToUid(c, c.Param(param)); err != nil {
+			abortWithPolicyError(c, err)
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequirePatientAccess only lets the patient named by the route parameter,
+// their linked therapist or an admin through.
+func RequirePatientAccess(store *repositories.Store, param string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		if err := helpers.CanAccessPatient(ctx, c, store, c.Param(param)); err != nil {
+			abortWithPolicyError(c, err)
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequirePatientExerciseAccess applies RequirePatientAccess to the patient
+// the patient exercise named by the route parameter belongs to.
+func RequirePatientExerciseAccess(store *repositories.Store, param string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		if err := helpers.CanAccessPatientExercise(ctx, c, store, c.Param(param)); err != nil {
+			abortWithPolicyError(c, err)
+			return
+		}
 		c.Next()
 	}
 }
+
+// RequirePatientExerciseCapability is RequirePatientExerciseAccess for
+// routes that change the patient exercise: therapists also need a care team
+// role that grants the capability.
+func RequirePatientExerciseCapability(store *repositories.Store, param string, capability string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		if err := helpers.CanActForPatientExercise(ctx, c, store, c.Param(param), capability); err != nil {
+			abortWithPolicyError(c, err)
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireRecordingKeyAccess only lets the owning patient of the patient
+// exercise named by the route parameter and their care team through.
+func RequireRecordingKeyAccess(store *repositories.Store, param string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		if err := helpers.CanReleaseRecordingKey(ctx, c, store, c.Param(param)); err != nil {
+			abortWithPolicyError(c, err)
+			return
+		}
+		c.Next()
+	}
+}
+
+func abortWithPolicyError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, helpers.ErrUnauthorized):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, helpers.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while checking permissions"})
+	}
+	c.Abort()
+}
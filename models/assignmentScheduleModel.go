@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type AssignmentSchedule struct {
+	ID                    primitive.ObjectID `json:"id" bson:"_id"`
+	PatientID             *string            `json:"patient_id" bson:"patient_id" validate:"required"`
+	TherapistID           *string            `json:"therapist_id" bson:"therapist_id" validate:"required"`
+	// AuthoredBy keeps the therapist who created the schedule once it has
+	// been transferred to another therapist. Empty means TherapistID.
+	AuthoredBy            string             `json:"authored_by,omitempty" bson:"authored_by,omitempty"`
+	ExerciseID            *string            `json:"exercise_id" bson:"exercise_id" validate:"required"`
+	StartDate             time.Time          `json:"start_date" bson:"start_date" validate:"required"`
+	EndDate               time.Time          `json:"end_date" bson:"end_date" validate:"required,gtefield=StartDate"`
+	Frequency             string             `json:"frequency" bson:"frequency" validate:"required,eq=daily|eq=weekly"`
+	DaysOfWeek            []int              `json:"days_of_week" bson:"days_of_week" validate:"dive,min=0,max=6"`
+	RepetitionsPerSession int                `json:"repetitions_per_session" bson:"repetitions_per_session" validate:"required,min=1"`
+	GeneratedThrough      time.Time          `json:"generated_through" bson:"generated_through"`
+	CreatedAt             time.Time          `json:"created_at" bson:"created_at"`
+	UpdatedAt             time.Time          `json:"updated_at" bson:"updated_at"`
+	ScheduleID            string             `json:"schedule_id" bson:"schedule_id"`
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Roles a therapist can have in a patient's care team.
+const (
+	CareRolePrimary    = "primary"
+	CareRoleAssistant  = "assistant"
+	CareRoleStudent    = "student"
+	CareRoleSupervisor = "supervisor"
+)
+
+// Care relationship statuses. Patients asking to join a therapist's caseload
+// start out pending until the therapist accepts or declines.
+const (
+	CareStatusPending  = "pending"
+	CareStatusActive   = "active"
+	CareStatusDeclined = "declined"
+	CareStatusEnded    = "ended"
+)
+
+// CareRelationship puts a therapist on a patient's care team in some role.
+// It only grants access while active and between StartDate and EndDate.
+// Pending relationships start when they are accepted.
+type CareRelationship struct {
+	ID             primitive.ObjectID `json:"id" bson:"_id"`
+	RelationshipID string             `json:"relationship_id" bson:"relationship_id"`
+	PatientID      string             `json:"patient_id" bson:"patient_id"`
+	TherapistID    string             `json:"therapist_id" bson:"therapist_id"`
+	Role           string             `json:"role" bson:"role"`
+	Status         string             `json:"status" bson:"status"`
+	StartDate      time.Time          `json:"start_date" bson:"start_date"`
+	EndDate        *time.Time         `json:"end_date,omitempty" bson:"end_date,omitempty"`
+	CreatedBy      string             `json:"created_by" bson:"created_by"`
+	CreatedAt      time.Time          `json:"created_at" bson:"created_at"`
+	UpdatedAt      time.Time          `json:"updated_at" bson:"updated_at"`
+}
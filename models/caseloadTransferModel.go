@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// CaseloadTransfer is the report of patients handed from one therapist to
+// another.
+type CaseloadTransfer struct {
+	ID              primitive.ObjectID        `json:"id" bson:"_id"`
+	TransferID      string                    `json:"transfer_id" bson:"transfer_id"`
+	FromTherapistID string                    `json:"from_therapist_id" bson:"from_therapist_id"`
+	ToTherapistID   string                    `json:"to_therapist_id" bson:"to_therapist_id"`
+	RequestedBy     string                    `json:"requested_by" bson:"requested_by"`
+	Patients        []CaseloadTransferPatient `json:"patients" bson:"patients"`
+	CreatedAt       time.Time                 `json:"created_at" bson:"created_at"`
+}
+
+// CaseloadTransferPatient reports what was transferred for one patient.
+// Open assignments are those not reviewed or archived yet.
+type CaseloadTransferPatient struct {
+	PatientID             string `json:"patient_id" bson:"patient_id"`
+	Role                  string `json:"role" bson:"role"`
+	EndedRelationshipID   string `json:"ended_relationship_id" bson:"ended_relationship_id"`
+	RelationshipID        string `json:"relationship_id" bson:"relationship_id"`
+	OpenAssignments       int    `json:"open_assignments" bson:"open_assignments"`
+	HistoricalAssignments int    `json:"historical_assignments" bson:"historical_assignments"`
+	Schedules             int    `json:"schedules" bson:"schedules"`
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// DeletionCertificate records that a deleted account's data was purged. It
+// is kept for compliance and holds no personal data beyond the user ID and a
+// hash of the email address.
+type DeletionCertificate struct {
+	ID            primitive.ObjectID `json:"id" bson:"_id"`
+	CertificateID string             `json:"certificate_id" bson:"certificate_id"`
+	UserID        string             `json:"user_id" bson:"user_id"`
+	Role          string             `json:"role" bson:"role"`
+	EmailHash     string             `json:"email_hash" bson:"email_hash"`
+	DeletedBy     string             `json:"deleted_by" bson:"deleted_by"`
+	DeletedAt     time.Time          `json:"deleted_at" bson:"deleted_at"`
+	PurgedAt      time.Time          `json:"purged_at" bson:"purged_at"`
+	// Documents counts the purged documents by collection.
+	Documents map[string]int `json:"documents" bson:"documents"`
+	// Objects lists the storage keys that were removed.
+	Objects     []string `json:"objects" bson:"objects"`
+	WrappedKeys int      `json:"wrapped_keys" bson:"wrapped_keys"`
+}
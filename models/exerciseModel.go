@@ -14,4 +14,10 @@ type Exercise struct {
 	CreatedAt		time.Time			`json:"created_at" bson:"created_at"`
 	UpdatedAt		time.Time			`json:"updated_at" bson:"updated_at"`
 	ExerciseID		string				`json:"exercise_id" bson:"exercise_id"`
+	// CreatedBy is the therapist or admin who authored the exercise. Only
+	// they and admins can change, archive or delete it.
+	CreatedBy		string				`json:"created_by,omitempty" bson:"created_by,omitempty"`
+	// ArchivedAt hides the exercise from the catalogue. Past assignments can
+	// still read it.
+	ArchivedAt		*time.Time			`json:"archived_at,omitempty" bson:"archived_at,omitempty"`
 }
\ No newline at end of file
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	DecisionReviewed  = "reviewed"
+	DecisionNeedsRedo = "needs_redo"
+)
+
+// Feedback is a therapist's review of a submitted recording.
+type Feedback struct {
+	ID                primitive.ObjectID `json:"id" bson:"_id"`
+	PatientExerciseID string             `json:"patient_exercise_id" bson:"patient_exercise_id"`
+	TherapistID       string             `json:"therapist_id" bson:"therapist_id"`
+	Scores            []RubricScore      `json:"scores" bson:"scores" validate:"dive"`
+	Comments          string             `json:"comments" bson:"comments" validate:"max=5000"`
+	Annotations       []Annotation       `json:"annotations" bson:"annotations" validate:"dive"`
+	Decision          string             `json:"decision" bson:"decision" validate:"required,eq=reviewed|eq=needs_redo"`
+	CreatedAt         time.Time          `json:"created_at" bson:"created_at"`
+	FeedbackID        string             `json:"feedback_id" bson:"feedback_id"`
+}
+
+// RubricScore is the score given for one rubric item.
+type RubricScore struct {
+	Item     string  `json:"item" bson:"item" validate:"required,max=100"`
+	Score    float64 `json:"score" bson:"score" validate:"min=0,ltefield=MaxScore"`
+	MaxScore float64 `json:"max_score" bson:"max_score" validate:"required,gt=0"`
+}
+
+// Annotation is a note pinned to a point in the recording.
+type Annotation struct {
+	TimestampMs int64  `json:"timestamp_ms" bson:"timestamp_ms" validate:"min=0"`
+	Note        string `json:"note" bson:"note" validate:"required,max=1000"`
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Invite lets patients join a therapist's caseload with a code. Only the
+// SHA-256 hash of the code is stored. A MaxUses of 0 means unlimited.
+//
+// Legacy invites stand for the therapist's permanent reference code. They
+// have no code hash, expiry or use limit, and are looked up by therapist.
+type Invite struct {
+	ID          primitive.ObjectID `json:"id" bson:"_id"`
+	InviteID    string             `json:"invite_id" bson:"invite_id"`
+	TherapistID string             `json:"therapist_id" bson:"therapist_id"`
+	CodeHash    string             `json:"-" bson:"code_hash,omitempty"`
+	Legacy      bool               `json:"legacy" bson:"legacy"`
+	Email       string             `json:"email,omitempty" bson:"email,omitempty"`
+	ExerciseIDs []string           `json:"exercise_ids" bson:"exercise_ids"`
+	MaxUses     int                `json:"max_uses" bson:"max_uses"`
+	Uses        int                `json:"uses" bson:"uses"`
+	Redemptions []InviteRedemption `json:"redemptions" bson:"redemptions"`
+	ExpiresAt   *time.Time         `json:"expires_at,omitempty" bson:"expires_at,omitempty"`
+	RevokedAt   *time.Time         `json:"revoked_at,omitempty" bson:"revoked_at,omitempty"`
+	CreatedAt   time.Time          `json:"created_at" bson:"created_at"`
+	UpdatedAt   time.Time          `json:"updated_at" bson:"updated_at"`
+}
+
+// InviteRedemption records a patient joining through an invite.
+type InviteRedemption struct {
+	PatientID          string    `json:"patient_id" bson:"patient_id"`
+	RelationshipID     string    `json:"relationship_id" bson:"relationship_id"`
+	PatientExerciseIDs []string  `json:"patient_exercise_ids" bson:"patient_exercise_ids"`
+	RedeemedAt         time.Time `json:"redeemed_at" bson:"redeemed_at"`
+}
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// KeyAccessLog records one release of a recording's plaintext key. Entries
+// are only ever appended.
+type KeyAccessLog struct {
+	ID                primitive.ObjectID `json:"id" bson:"_id"`
+	PatientID         string             `json:"patient_id" bson:"patient_id"`
+	PatientExerciseID string             `json:"patient_exercise_id" bson:"patient_exercise_id"`
+	AttemptID         string             `json:"attempt_id,omitempty" bson:"attempt_id,omitempty"`
+	ActorID           string             `json:"actor_id" bson:"actor_id"`
+	ActorRole         string             `json:"actor_role" bson:"actor_role"`
+	IP                string             `json:"ip" bson:"ip"`
+	Purpose           string             `json:"purpose" bson:"purpose"`
+	At                time.Time          `json:"at" bson:"at"`
+}
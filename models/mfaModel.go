@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+)
+
+// MFASettings is a user's TOTP enrolment. The secrets are stored wrapped by
+// the key wrapper and the recovery codes only as SHA-256 hashes.
+type MFASettings struct {
+	Enabled       bool       `json:"enabled" bson:"enabled"`
+	EnabledAt     *time.Time `json:"enabled_at,omitempty" bson:"enabled_at,omitempty"`
+	Secret        string     `json:"-" bson:"secret,omitempty"`
+	PendingSecret string     `json:"-" bson:"pending_secret,omitempty"`
+	RecoveryCodes []string   `json:"-" bson:"recovery_codes,omitempty"`
+	// LastStep is the TOTP time step of the last accepted code, so a code
+	// can't be replayed.
+	LastStep int64 `json:"-" bson:"last_step,omitempty"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	TokenPurposePasswordReset     = "password_reset"
+	TokenPurposeEmailVerification = "email_verification"
+	TokenPurposeEmailChange       = "email_change"
+	TokenPurposeAccountRestore    = "account_restore"
+)
+
+// OneTimeToken is a single-use secret mailed to a user. Only the SHA-256 hash
+// of the secret is stored.
+type OneTimeToken struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id"`
+	TokenID   string             `json:"token_id" bson:"token_id"`
+	UserID    string             `json:"user_id" bson:"user_id"`
+	Purpose   string             `json:"purpose" bson:"purpose"`
+	TokenHash string             `json:"-" bson:"token_hash"`
+	ExpiresAt time.Time          `json:"expires_at" bson:"expires_at"`
+	UsedAt    *time.Time         `json:"used_at,omitempty" bson:"used_at,omitempty"`
+	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
+}
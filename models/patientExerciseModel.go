@@ -5,15 +5,47 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// Patient exercise lifecycle. Allowed transitions between these are enforced
+// in helpers/statusHelper.go.
+const (
+	StatusAssigned   = "assigned"
+	StatusInProgress = "in_progress"
+	// StatusUploadPending means an upload URL was handed out and the upload
+	// has not been confirmed yet.
+	StatusUploadPending = "upload_pending"
+	StatusSubmitted  = "submitted"
+	StatusReviewed   = "reviewed"
+	StatusNeedsRedo  = "needs_redo"
+	StatusArchived   = "archived"
+)
+
 type PatientExercise struct {
 	ID                primitive.ObjectID `json:"id" bson:"_id"`
 	PatientID         *string            `json:"patient_id" bson:"patient_id" validate:"required"`
 	TherapistID       *string            `json:"therapist_id" bson:"therapist_id" validate:"required"`
+	// AuthoredBy keeps the therapist who assigned the exercise once it has
+	// been transferred to another therapist. Empty means TherapistID.
+	AuthoredBy        string             `json:"authored_by,omitempty" bson:"authored_by,omitempty"`
 	ExerciseID        *string            `json:"exercise_id" bson:"exercise_id" validate:"required"`
-	Status            string             `json:"status" validate:"required,eq=PENDING|eq=COMPLETED"`
+	Status            string             `json:"status" validate:"required,oneof=assigned in_progress upload_pending submitted reviewed needs_redo archived"`
 	Recording         string             `json:"recording"`
 	CreatedAt         time.Time          `json:"created_at" bson:"created_at"`
 	UpdatedAt         time.Time          `json:"updated_at" bson:"updated_at"`
 	PatientExerciseID string             `json:"patient_exercise_id" bson:"patient_exercise_id"`
 	WrappedKey 	  	  string             `json:"wrapped_key" bson:"wrapped_key"`
-}
\ No newline at end of file
+	ScheduleID        string             `json:"schedule_id,omitempty" bson:"schedule_id,omitempty"`
+	DueDate           *time.Time         `json:"due_date,omitempty" bson:"due_date,omitempty"`
+	Repetitions       int                `json:"repetitions,omitempty" bson:"repetitions,omitempty"`
+	StatusHistory     []StatusTransition `json:"status_history" bson:"status_history,omitempty"`
+	UploadExpiresAt   *time.Time         `json:"upload_expires_at,omitempty" bson:"upload_expires_at,omitempty"`
+	CurrentAttemptID  string             `json:"current_attempt_id,omitempty" bson:"current_attempt_id,omitempty"`
+}
+
+// StatusTransition records who moved a patient exercise between statuses and when.
+type StatusTransition struct {
+	From      string    `json:"from" bson:"from"`
+	To        string    `json:"to" bson:"to"`
+	ActorID   string    `json:"actor_id" bson:"actor_id"`
+	ActorRole string    `json:"actor_role" bson:"actor_role"`
+	At        time.Time `json:"at" bson:"at"`
+}
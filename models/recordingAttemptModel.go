@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	AttemptPending  = "pending"
+	AttemptUploaded = "uploaded"
+	AttemptExpired  = "expired"
+)
+
+// RecordingAttempt is one recording a patient uploaded for a patient
+// exercise. Every attempt has its own object and its own wrapped key, so
+// earlier attempts stay available when the exercise is redone.
+type RecordingAttempt struct {
+	ID                primitive.ObjectID   `json:"id" bson:"_id"`
+	AttemptID         string               `json:"attempt_id" bson:"attempt_id"`
+	PatientExerciseID string               `json:"patient_exercise_id" bson:"patient_exercise_id"`
+	Number            int                  `json:"number" bson:"number"`
+	Status            string               `json:"status" bson:"status"`
+	ObjectKey         string               `json:"object_key" bson:"object_key"`
+	WrappedKey        string               `json:"-" bson:"wrapped_key,omitempty"`
+	Encryption        *RecordingEncryption `json:"encryption,omitempty" bson:"encryption,omitempty"`
+	Size              int64                `json:"size" bson:"size"`
+	DurationMs        int64                `json:"duration_ms" bson:"duration_ms"`
+	CreatedAt         time.Time            `json:"created_at" bson:"created_at"`
+	UploadedAt        *time.Time           `json:"uploaded_at,omitempty" bson:"uploaded_at,omitempty"`
+}
+
+// RecordingEncryption records how a recording was encrypted, so it can be
+// decrypted without guessing. Recordings uploaded with client-supplied keys
+// have none.
+type RecordingEncryption struct {
+	Algorithm       string `json:"algorithm" bson:"algorithm"`
+	NonceConvention string `json:"nonce_convention" bson:"nonce_convention"`
+	// KeyVersion is the ID of the master key the data key was wrapped with.
+	KeyVersion string `json:"key_version" bson:"key_version"`
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Reasons a token family was revoked.
+const (
+	RevokedLogout         = "logout"
+	RevokedLogoutAll      = "logout_all"
+	RevokedReuse          = "reuse_detected"
+	RevokedPasswordReset  = "password_reset"
+	RevokedPasswordChange = "password_change"
+	RevokedMFAEnrolled    = "mfa_enrolled"
+	RevokedAccountDeleted = "account_deleted"
+)
+
+// TokenFamily is one login session. Every refresh rotates the refresh token
+// and only the newest one, CurrentTokenID, is accepted. Revoking the family
+// invalidates its access and refresh tokens alike.
+type TokenFamily struct {
+	ID             primitive.ObjectID `json:"id" bson:"_id"`
+	FamilyID       string             `json:"family_id" bson:"family_id"`
+	UserID         string             `json:"user_id" bson:"user_id"`
+	CurrentTokenID string             `json:"-" bson:"current_token_id"`
+	// AMR lists how the user logged in; refreshed tokens keep it.
+	AMR           []string   `json:"amr,omitempty" bson:"amr,omitempty"`
+	CreatedAt     time.Time  `json:"created_at" bson:"created_at"`
+	RotatedAt     *time.Time `json:"rotated_at,omitempty" bson:"rotated_at,omitempty"`
+	RevokedAt     *time.Time `json:"revoked_at,omitempty" bson:"revoked_at,omitempty"`
+	RevokedReason string     `json:"revoked_reason,omitempty" bson:"revoked_reason,omitempty"`
+}
@@ -6,6 +6,13 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// Account statuses. Accounts created before email verification existed have
+// no status and count as active.
+const (
+	AccountUnverified = "unverified"
+	AccountActive     = "active"
+)
+
 type User struct {
 	ID            primitive.ObjectID `json:"id" bson:"_id"`
 	FirstName     *string            `json:"first_name" bson:"first_name" validate:"required,min=2,max=100"`
@@ -20,4 +27,15 @@ type User struct {
 	CreatedAt     time.Time          `json:"created_at" bson:"created_at"`
 	UpdatedAt     time.Time          `json:"updated_at" bson:"updated_at"`
 	UserID        string             `json:"user_id" bson:"user_id"`
+	PasswordChangedAt *time.Time     `json:"-" bson:"password_changed_at,omitempty"`
+	AccountStatus string             `json:"account_status" bson:"account_status,omitempty"`
+	MFA           MFASettings        `json:"mfa" bson:"mfa,omitempty"`
+	// PendingEmail is a new address waiting for confirmation. Email stays
+	// in use until then.
+	PendingEmail  string             `json:"pending_email,omitempty" bson:"pending_email,omitempty"`
+	// A deleted account can be restored until PurgeAfter, when its data is
+	// purged for good.
+	DeletedAt     *time.Time         `json:"deleted_at,omitempty" bson:"deleted_at,omitempty"`
+	DeletedBy     string             `json:"deleted_by,omitempty" bson:"deleted_by,omitempty"`
+	PurgeAfter    *time.Time         `json:"purge_after,omitempty" bson:"purge_after,omitempty"`
 }
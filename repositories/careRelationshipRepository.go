@@ -0,0 +1,132 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"golang-speakbackend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CareRelationshipRepository stores which therapists are on which patient's
+// care team.
+type CareRelationshipRepository interface {
+	Insert(ctx context.Context, relationship *models.CareRelationship) error
+	FindByID(ctx context.Context, relationshipID string) (*models.CareRelationship, error)
+	// ListByPatient and ListByTherapist return relationships in every
+	// status, oldest first.
+	ListByPatient(ctx context.Context, patientID string) ([]models.CareRelationship, error)
+	ListByTherapist(ctx context.Context, therapistID string) ([]models.CareRelationship, error)
+	// End marks the relationship ended as of the given time.
+	End(ctx context.Context, relationshipID string, at time.Time) error
+	// EndAllForUser ends every active or pending relationship the user is
+	// part of, as patient or as therapist.
+	EndAllForUser(ctx context.Context, userID string, at time.Time) error
+	// Accept makes a pending relationship active from the given time and
+	// Decline turns it down. Both return ErrConflict if it isn't pending.
+	Accept(ctx context.Context, relationshipID string, at time.Time) error
+	Decline(ctx context.Context, relationshipID string, at time.Time) error
+	// DeleteAllForUser removes every relationship the user was part of, in
+	// any status.
+	DeleteAllForUser(ctx context.Context, userID string) error
+}
+
+type mongoCareRelationshipRepository struct {
+	collection *mongo.Collection
+}
+
+func (r *mongoCareRelationshipRepository) Insert(ctx context.Context, relationship *models.CareRelationship) error {
+	_, err := r.collection.InsertOne(ctx, relationship)
+	return err
+}
+
+func (r *mongoCareRelationshipRepository) FindByID(ctx context.Context, relationshipID string) (*models.CareRelationship, error) {
+	var relationship models.CareRelationship
+	err := r.collection.FindOne(ctx, bson.M{"relationship_id": relationshipID}).Decode(&relationship)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &relationship, nil
+}
+
+func (r *mongoCareRelationshipRepository) find(ctx context.Context, filter bson.M) ([]models.CareRelationship, error) {
+	cursor, err := r.collection.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	relationships := []models.CareRelationship{}
+	if err = cursor.All(ctx, &relationships); err != nil {
+		return nil, err
+	}
+	return relationships, nil
+}
+
+func (r *mongoCareRelationshipRepository) ListByPatient(ctx context.Context, patientID string) ([]models.CareRelationship, error) {
+	return r.find(ctx, bson.M{"patient_id": patientID})
+}
+
+func (r *mongoCareRelationshipRepository) ListByTherapist(ctx context.Context, therapistID string) ([]models.CareRelationship, error) {
+	return r.find(ctx, bson.M{"therapist_id": therapistID})
+}
+
+func (r *mongoCareRelationshipRepository) End(ctx context.Context, relationshipID string, at time.Time) error {
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"relationship_id": relationshipID},
+		bson.M{"$set": bson.M{"status": models.CareStatusEnded, "end_date": at, "updated_at": time.Now()}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoCareRelationshipRepository) EndAllForUser(ctx context.Context, userID string, at time.Time) error {
+	_, err := r.collection.UpdateMany(ctx,
+		bson.M{
+			"status": bson.M{"$in": bson.A{models.CareStatusActive, models.CareStatusPending}},
+			"$or":    bson.A{bson.M{"patient_id": userID}, bson.M{"therapist_id": userID}},
+		},
+		bson.M{"$set": bson.M{"status": models.CareStatusEnded, "end_date": at, "updated_at": time.Now()}},
+	)
+	return err
+}
+
+func (r *mongoCareRelationshipRepository) DeleteAllForUser(ctx context.Context, userID string) error {
+	_, err := r.collection.DeleteMany(ctx, bson.M{"$or": bson.A{bson.M{"patient_id": userID}, bson.M{"therapist_id": userID}}})
+	return err
+}
+
+func (r *mongoCareRelationshipRepository) resolve(ctx context.Context, relationshipID string, set bson.M) error {
+	set["updated_at"] = time.Now()
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"relationship_id": relationshipID, "status": models.CareStatusPending},
+		bson.M{"$set": set},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		if _, err := r.FindByID(ctx, relationshipID); err != nil {
+			return err
+		}
+		return ErrConflict
+	}
+	return nil
+}
+
+func (r *mongoCareRelationshipRepository) Accept(ctx context.Context, relationshipID string, at time.Time) error {
+	return r.resolve(ctx, relationshipID, bson.M{"status": models.CareStatusActive, "start_date": at})
+}
+
+func (r *mongoCareRelationshipRepository) Decline(ctx context.Context, relationshipID string, at time.Time) error {
+	return r.resolve(ctx, relationshipID, bson.M{"status": models.CareStatusDeclined, "end_date": at})
+}
@@ -0,0 +1,56 @@
+package repositories
+
+import (
+	"context"
+
+	"golang-speakbackend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CaseloadTransferRepository stores the reports of caseload transfers.
+type CaseloadTransferRepository interface {
+	Insert(ctx context.Context, transfer *models.CaseloadTransfer) error
+	FindByID(ctx context.Context, transferID string) (*models.CaseloadTransfer, error)
+	// ListByTherapist returns the transfers from or to the therapist, newest
+	// first.
+	ListByTherapist(ctx context.Context, therapistID string) ([]models.CaseloadTransfer, error)
+}
+
+type mongoCaseloadTransferRepository struct {
+	collection *mongo.Collection
+}
+
+func (r *mongoCaseloadTransferRepository) Insert(ctx context.Context, transfer *models.CaseloadTransfer) error {
+	_, err := r.collection.InsertOne(ctx, transfer)
+	return err
+}
+
+func (r *mongoCaseloadTransferRepository) FindByID(ctx context.Context, transferID string) (*models.CaseloadTransfer, error) {
+	var transfer models.CaseloadTransfer
+	err := r.collection.FindOne(ctx, bson.M{"transfer_id": transferID}).Decode(&transfer)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &transfer, nil
+}
+
+func (r *mongoCaseloadTransferRepository) ListByTherapist(ctx context.Context, therapistID string) ([]models.CaseloadTransfer, error) {
+	cursor, err := r.collection.Find(ctx,
+		bson.M{"$or": bson.A{bson.M{"from_therapist_id": therapistID}, bson.M{"to_therapist_id": therapistID}}},
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	transfers := []models.CaseloadTransfer{}
+	if err = cursor.All(ctx, &transfers); err != nil {
+		return nil, err
+	}
+	return transfers, nil
+}
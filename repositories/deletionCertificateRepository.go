@@ -0,0 +1,59 @@
+package repositories
+
+import (
+	"context"
+
+	"golang-speakbackend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DeletionCertificateRepository stores the certificates of purged accounts.
+type DeletionCertificateRepository interface {
+	Insert(ctx context.Context, certificate *models.DeletionCertificate) error
+	FindByUserID(ctx context.Context, userID string) (*models.DeletionCertificate, error)
+	// List returns a page of certificates, newest first, and the total
+	// number of certificates.
+	List(ctx context.Context, skip int64, limit int64) ([]models.DeletionCertificate, int64, error)
+}
+
+type mongoDeletionCertificateRepository struct {
+	collection *mongo.Collection
+}
+
+func (r *mongoDeletionCertificateRepository) Insert(ctx context.Context, certificate *models.DeletionCertificate) error {
+	_, err := r.collection.InsertOne(ctx, certificate)
+	return err
+}
+
+func (r *mongoDeletionCertificateRepository) FindByUserID(ctx context.Context, userID string) (*models.DeletionCertificate, error) {
+	var certificate models.DeletionCertificate
+	err := r.collection.FindOne(ctx, bson.M{"user_id": userID}).Decode(&certificate)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &certificate, nil
+}
+
+func (r *mongoDeletionCertificateRepository) List(ctx context.Context, skip int64, limit int64) ([]models.DeletionCertificate, int64, error) {
+	total, err := r.collection.CountDocuments(ctx, bson.M{})
+	if err != nil {
+		return nil, 0, err
+	}
+	cursor, err := r.collection.Find(ctx, bson.M{},
+		options.Find().SetSort(bson.D{{Key: "purged_at", Value: -1}}).SetSkip(skip).SetLimit(limit),
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	certificates := []models.DeletionCertificate{}
+	if err = cursor.All(ctx, &certificates); err != nil {
+		return nil, 0, err
+	}
+	return certificates, total, nil
+}
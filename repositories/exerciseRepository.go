@@ -0,0 +1,137 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"golang-speakbackend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ExerciseRepository stores the exercise catalogue therapists assign from.
+type ExerciseRepository interface {
+	Insert(ctx context.Context, exercise *models.Exercise) error
+	FindByID(ctx context.Context, exerciseID string) (*models.Exercise, error)
+	FindByIDs(ctx context.Context, exerciseIDs []string) ([]models.Exercise, error)
+	// List pages through the catalogue, leaving out archived exercises.
+	List(ctx context.Context, skip int64, limit int64) ([]models.Exercise, int64, error)
+	Update(ctx context.Context, exerciseID string, update ExerciseUpdate) error
+	Archive(ctx context.Context, exerciseID string, archivedAt time.Time) error
+	Delete(ctx context.Context, exerciseID string) error
+}
+
+// ExerciseUpdate holds the fields to change on an exercise. Nil fields are
+// left as is and updated_at is always refreshed.
+type ExerciseUpdate struct {
+	Name        *string
+	Description *string
+	VideoURL    *string
+	Tags        []string
+}
+
+type mongoExerciseRepository struct {
+	collection *mongo.Collection
+}
+
+func (r *mongoExerciseRepository) Insert(ctx context.Context, exercise *models.Exercise) error {
+	_, err := r.collection.InsertOne(ctx, exercise)
+	return err
+}
+
+func (r *mongoExerciseRepository) FindByID(ctx context.Context, exerciseID string) (*models.Exercise, error) {
+	var exercise models.Exercise
+	err := r.collection.FindOne(ctx, bson.M{"exercise_id": exerciseID}).Decode(&exercise)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &exercise, nil
+}
+
+func (r *mongoExerciseRepository) find(ctx context.Context, filter bson.M, opts ...*options.FindOptions) ([]models.Exercise, error) {
+	cursor, err := r.collection.Find(ctx, filter, opts...)
+	if err != nil {
+		return nil, err
+	}
+	exercises := []models.Exercise{}
+	if err = cursor.All(ctx, &exercises); err != nil {
+		return nil, err
+	}
+	return exercises, nil
+}
+
+func (r *mongoExerciseRepository) FindByIDs(ctx context.Context, exerciseIDs []string) ([]models.Exercise, error) {
+	return r.find(ctx, bson.M{"exercise_id": bson.M{"$in": exerciseIDs}})
+}
+
+func (r *mongoExerciseRepository) List(ctx context.Context, skip int64, limit int64) ([]models.Exercise, int64, error) {
+	filter := bson.M{"archived_at": bson.M{"$exists": false}}
+	total, err := r.collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	exercises, err := r.find(ctx, filter, options.Find().SetSkip(skip).SetLimit(limit))
+	if err != nil {
+		return nil, 0, err
+	}
+	return exercises, total, nil
+}
+
+func (r *mongoExerciseRepository) Update(ctx context.Context, exerciseID string, update ExerciseUpdate) error {
+	set := bson.D{}
+	if update.Name != nil {
+		set = append(set, bson.E{Key: "name", Value: *update.Name})
+	}
+	if update.Description != nil {
+		set = append(set, bson.E{Key: "description", Value: *update.Description})
+	}
+	if update.VideoURL != nil {
+		set = append(set, bson.E{Key: "video_url", Value: *update.VideoURL})
+	}
+	if update.Tags != nil {
+		set = append(set, bson.E{Key: "tags", Value: update.Tags})
+	}
+	set = append(set, bson.E{Key: "updated_at", Value: time.Now()})
+
+	result, err := r.collection.UpdateOne(ctx, bson.M{"exercise_id": exerciseID}, bson.D{{Key: "$set", Value: set}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoExerciseRepository) Archive(ctx context.Context, exerciseID string, archivedAt time.Time) error {
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"exercise_id": exerciseID},
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "archived_at", Value: archivedAt},
+			{Key: "updated_at", Value: time.Now()},
+		}}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoExerciseRepository) Delete(ctx context.Context, exerciseID string) error {
+	result, err := r.collection.DeleteOne(ctx, bson.M{"exercise_id": exerciseID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
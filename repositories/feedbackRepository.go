@@ -0,0 +1,47 @@
+package repositories
+
+import (
+	"context"
+
+	"golang-speakbackend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// FeedbackRepository stores therapist feedback on recordings.
+type FeedbackRepository interface {
+	Insert(ctx context.Context, feedback *models.Feedback) error
+	ListByPatientExercise(ctx context.Context, patientExerciseID string) ([]models.Feedback, error)
+	DeleteByPatientExercises(ctx context.Context, patientExerciseIDs []string) error
+}
+
+type mongoFeedbackRepository struct {
+	collection *mongo.Collection
+}
+
+func (r *mongoFeedbackRepository) Insert(ctx context.Context, feedback *models.Feedback) error {
+	_, err := r.collection.InsertOne(ctx, feedback)
+	return err
+}
+
+func (r *mongoFeedbackRepository) ListByPatientExercise(ctx context.Context, patientExerciseID string) ([]models.Feedback, error) {
+	cursor, err := r.collection.Find(ctx,
+		bson.M{"patient_exercise_id": patientExerciseID},
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	feedback := []models.Feedback{}
+	if err = cursor.All(ctx, &feedback); err != nil {
+		return nil, err
+	}
+	return feedback, nil
+}
+
+func (r *mongoFeedbackRepository) DeleteByPatientExercises(ctx context.Context, patientExerciseIDs []string) error {
+	_, err := r.collection.DeleteMany(ctx, bson.M{"patient_exercise_id": bson.M{"$in": patientExerciseIDs}})
+	return err
+}
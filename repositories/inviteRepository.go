@@ -0,0 +1,118 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"golang-speakbackend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// InviteRepository stores the invites therapists hand out to patients.
+type InviteRepository interface {
+	Insert(ctx context.Context, invite *models.Invite) error
+	FindByID(ctx context.Context, inviteID string) (*models.Invite, error)
+	FindByCodeHash(ctx context.Context, codeHash string) (*models.Invite, error)
+	// FindLegacy returns the invite standing for the therapist's reference
+	// code.
+	FindLegacy(ctx context.Context, therapistID string) (*models.Invite, error)
+	// ListByTherapist returns the therapist's invites, newest first.
+	ListByTherapist(ctx context.Context, therapistID string) ([]models.Invite, error)
+	Revoke(ctx context.Context, inviteID string, at time.Time) error
+	// Redeem records the redemption and counts a use, provided the invite is
+	// still redeemable at the given time. It returns ErrConflict otherwise.
+	Redeem(ctx context.Context, inviteID string, redemption models.InviteRedemption, now time.Time) error
+	DeleteByTherapist(ctx context.Context, therapistID string) error
+}
+
+type mongoInviteRepository struct {
+	collection *mongo.Collection
+}
+
+func (r *mongoInviteRepository) Insert(ctx context.Context, invite *models.Invite) error {
+	_, err := r.collection.InsertOne(ctx, invite)
+	return err
+}
+
+func (r *mongoInviteRepository) findOne(ctx context.Context, filter bson.M) (*models.Invite, error) {
+	var invite models.Invite
+	err := r.collection.FindOne(ctx, filter).Decode(&invite)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &invite, nil
+}
+
+func (r *mongoInviteRepository) FindByID(ctx context.Context, inviteID string) (*models.Invite, error) {
+	return r.findOne(ctx, bson.M{"invite_id": inviteID})
+}
+
+func (r *mongoInviteRepository) FindByCodeHash(ctx context.Context, codeHash string) (*models.Invite, error) {
+	return r.findOne(ctx, bson.M{"code_hash": codeHash})
+}
+
+func (r *mongoInviteRepository) FindLegacy(ctx context.Context, therapistID string) (*models.Invite, error) {
+	return r.findOne(ctx, bson.M{"therapist_id": therapistID, "legacy": true})
+}
+
+func (r *mongoInviteRepository) ListByTherapist(ctx context.Context, therapistID string) ([]models.Invite, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{"therapist_id": therapistID}, options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	invites := []models.Invite{}
+	if err = cursor.All(ctx, &invites); err != nil {
+		return nil, err
+	}
+	return invites, nil
+}
+
+func (r *mongoInviteRepository) Revoke(ctx context.Context, inviteID string, at time.Time) error {
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"invite_id": inviteID},
+		bson.M{"$set": bson.M{"revoked_at": at, "updated_at": time.Now()}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoInviteRepository) Redeem(ctx context.Context, inviteID string, redemption models.InviteRedemption, now time.Time) error {
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{
+			"invite_id":  inviteID,
+			"revoked_at": bson.M{"$exists": false},
+			"$and": bson.A{
+				bson.M{"$or": bson.A{bson.M{"expires_at": bson.M{"$exists": false}}, bson.M{"expires_at": bson.M{"$gt": now}}}},
+				bson.M{"$or": bson.A{bson.M{"max_uses": 0}, bson.M{"$expr": bson.M{"$lt": bson.A{"$uses", "$max_uses"}}}}},
+			},
+		},
+		bson.M{
+			"$inc":  bson.M{"uses": 1},
+			"$push": bson.M{"redemptions": redemption},
+			"$set":  bson.M{"updated_at": time.Now()},
+		},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrConflict
+	}
+	return nil
+}
+
+func (r *mongoInviteRepository) DeleteByTherapist(ctx context.Context, therapistID string) error {
+	_, err := r.collection.DeleteMany(ctx, bson.M{"therapist_id": therapistID})
+	return err
+}
@@ -0,0 +1,51 @@
+package repositories
+
+import (
+	"context"
+
+	"golang-speakbackend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// KeyAccessLogRepository is the append-only log of recording key releases.
+// It deliberately has no way to change or remove entries.
+type KeyAccessLogRepository interface {
+	Insert(ctx context.Context, entry *models.KeyAccessLog) error
+	// ListByPatient returns a page of the patient's entries, newest first,
+	// and the total number of entries.
+	ListByPatient(ctx context.Context, patientID string, skip int64, limit int64) ([]models.KeyAccessLog, int64, error)
+}
+
+type mongoKeyAccessLogRepository struct {
+	collection *mongo.Collection
+}
+
+func (r *mongoKeyAccessLogRepository) Insert(ctx context.Context, entry *models.KeyAccessLog) error {
+	_, err := r.collection.InsertOne(ctx, entry)
+	return err
+}
+
+func (r *mongoKeyAccessLogRepository) ListByPatient(ctx context.Context, patientID string, skip int64, limit int64) ([]models.KeyAccessLog, int64, error) {
+	filter := bson.M{"patient_id": patientID}
+	total, err := r.collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	cursor, err := r.collection.Find(ctx, filter, options.Find().
+		SetSort(bson.D{{Key: "at", Value: -1}}).
+		SetSkip(skip).
+		SetLimit(limit),
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	entries := []models.KeyAccessLog{}
+	if err = cursor.All(ctx, &entries); err != nil {
+		return nil, 0, err
+	}
+	return entries, total, nil
+}
@@ -0,0 +1,70 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"golang-speakbackend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// LoginThrottleRepository stores failed login counters, so lockouts hold
+// across every instance of the service.
+type LoginThrottleRepository interface {
+	Find(ctx context.Context, key string) (*models.LoginThrottle, error)
+	// RecordFailure counts a failed login and returns the new state. Counts
+	// from failures before resetBefore are dropped first.
+	RecordFailure(ctx context.Context, key string, at time.Time, resetBefore time.Time) (*models.LoginThrottle, error)
+	Lock(ctx context.Context, key string, until time.Time) error
+	Reset(ctx context.Context, key string) error
+}
+
+type mongoLoginThrottleRepository struct {
+	collection *mongo.Collection
+}
+
+func (r *mongoLoginThrottleRepository) Find(ctx context.Context, key string) (*models.LoginThrottle, error) {
+	var throttle models.LoginThrottle
+	err := r.collection.FindOne(ctx, bson.M{"key": key}).Decode(&throttle)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &throttle, nil
+}
+
+func (r *mongoLoginThrottleRepository) RecordFailure(ctx context.Context, key string, at time.Time, resetBefore time.Time) (*models.LoginThrottle, error) {
+	_, err := r.collection.UpdateOne(ctx,
+		bson.M{"key": key, "last_failure_at": bson.M{"$lt": resetBefore}},
+		bson.M{"$set": bson.M{"failures": 0}},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var throttle models.LoginThrottle
+	err = r.collection.FindOneAndUpdate(ctx,
+		bson.M{"key": key},
+		bson.M{"$inc": bson.M{"failures": 1}, "$set": bson.M{"last_failure_at": at}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&throttle)
+	if err != nil {
+		return nil, err
+	}
+	return &throttle, nil
+}
+
+func (r *mongoLoginThrottleRepository) Lock(ctx context.Context, key string, until time.Time) error {
+	_, err := r.collection.UpdateOne(ctx, bson.M{"key": key}, bson.M{"$set": bson.M{"locked_until": until}})
+	return err
+}
+
+func (r *mongoLoginThrottleRepository) Reset(ctx context.Context, key string) error {
+	_, err := r.collection.DeleteOne(ctx, bson.M{"key": key})
+	return err
+}
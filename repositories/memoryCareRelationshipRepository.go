@@ -0,0 +1,140 @@
+package repositories
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"golang-speakbackend/models"
+)
+
+type memoryCareRelationshipRepository struct {
+	mu            sync.RWMutex
+	relationships map[string]models.CareRelationship
+}
+
+func newMemoryCareRelationshipRepository() *memoryCareRelationshipRepository {
+	return &memoryCareRelationshipRepository{relationships: map[string]models.CareRelationship{}}
+}
+
+func (r *memoryCareRelationshipRepository) Insert(ctx context.Context, relationship *models.CareRelationship) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.relationships[relationship.RelationshipID] = *relationship
+	return nil
+}
+
+func (r *memoryCareRelationshipRepository) FindByID(ctx context.Context, relationshipID string) (*models.CareRelationship, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	relationship, ok := r.relationships[relationshipID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &relationship, nil
+}
+
+// filter returns every relationship matching the predicate, oldest first.
+func (r *memoryCareRelationshipRepository) filter(match func(models.CareRelationship) bool) []models.CareRelationship {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	relationships := []models.CareRelationship{}
+	for _, relationship := range r.relationships {
+		if match(relationship) {
+			relationships = append(relationships, relationship)
+		}
+	}
+	sort.Slice(relationships, func(i, j int) bool {
+		if relationships[i].CreatedAt.Equal(relationships[j].CreatedAt) {
+			return relationships[i].RelationshipID < relationships[j].RelationshipID
+		}
+		return relationships[i].CreatedAt.Before(relationships[j].CreatedAt)
+	})
+	return relationships
+}
+
+func (r *memoryCareRelationshipRepository) ListByPatient(ctx context.Context, patientID string) ([]models.CareRelationship, error) {
+	return r.filter(func(relationship models.CareRelationship) bool {
+		return relationship.PatientID == patientID
+	}), nil
+}
+
+func (r *memoryCareRelationshipRepository) ListByTherapist(ctx context.Context, therapistID string) ([]models.CareRelationship, error) {
+	return r.filter(func(relationship models.CareRelationship) bool {
+		return relationship.TherapistID == therapistID
+	}), nil
+}
+
+func (r *memoryCareRelationshipRepository) End(ctx context.Context, relationshipID string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	relationship, ok := r.relationships[relationshipID]
+	if !ok {
+		return ErrNotFound
+	}
+	relationship.Status = models.CareStatusEnded
+	relationship.EndDate = &at
+	relationship.UpdatedAt = time.Now()
+	r.relationships[relationshipID] = relationship
+	return nil
+}
+
+func (r *memoryCareRelationshipRepository) EndAllForUser(ctx context.Context, userID string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for relationshipID, relationship := range r.relationships {
+		if relationship.Status != models.CareStatusActive && relationship.Status != models.CareStatusPending {
+			continue
+		}
+		if relationship.PatientID == userID || relationship.TherapistID == userID {
+			endDate := at
+			relationship.Status = models.CareStatusEnded
+			relationship.EndDate = &endDate
+			relationship.UpdatedAt = time.Now()
+			r.relationships[relationshipID] = relationship
+		}
+	}
+	return nil
+}
+
+func (r *memoryCareRelationshipRepository) resolve(relationshipID string, apply func(*models.CareRelationship)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	relationship, ok := r.relationships[relationshipID]
+	if !ok {
+		return ErrNotFound
+	}
+	if relationship.Status != models.CareStatusPending {
+		return ErrConflict
+	}
+	apply(&relationship)
+	relationship.UpdatedAt = time.Now()
+	r.relationships[relationshipID] = relationship
+	return nil
+}
+
+func (r *memoryCareRelationshipRepository) Accept(ctx context.Context, relationshipID string, at time.Time) error {
+	return r.resolve(relationshipID, func(relationship *models.CareRelationship) {
+		relationship.Status = models.CareStatusActive
+		relationship.StartDate = at
+	})
+}
+
+func (r *memoryCareRelationshipRepository) Decline(ctx context.Context, relationshipID string, at time.Time) error {
+	return r.resolve(relationshipID, func(relationship *models.CareRelationship) {
+		relationship.Status = models.CareStatusDeclined
+		relationship.EndDate = &at
+	})
+}
+
+func (r *memoryCareRelationshipRepository) DeleteAllForUser(ctx context.Context, userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for relationshipID, relationship := range r.relationships {
+		if relationship.PatientID == userID || relationship.TherapistID == userID {
+			delete(r.relationships, relationshipID)
+		}
+	}
+	return nil
+}
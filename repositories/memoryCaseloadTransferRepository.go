@@ -0,0 +1,53 @@
+package repositories
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"golang-speakbackend/models"
+)
+
+type memoryCaseloadTransferRepository struct {
+	mu        sync.RWMutex
+	transfers map[string]models.CaseloadTransfer
+}
+
+func newMemoryCaseloadTransferRepository() *memoryCaseloadTransferRepository {
+	return &memoryCaseloadTransferRepository{transfers: map[string]models.CaseloadTransfer{}}
+}
+
+func (r *memoryCaseloadTransferRepository) Insert(ctx context.Context, transfer *models.CaseloadTransfer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.transfers[transfer.TransferID] = *transfer
+	return nil
+}
+
+func (r *memoryCaseloadTransferRepository) FindByID(ctx context.Context, transferID string) (*models.CaseloadTransfer, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	transfer, ok := r.transfers[transferID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &transfer, nil
+}
+
+func (r *memoryCaseloadTransferRepository) ListByTherapist(ctx context.Context, therapistID string) ([]models.CaseloadTransfer, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	transfers := []models.CaseloadTransfer{}
+	for _, transfer := range r.transfers {
+		if transfer.FromTherapistID == therapistID || transfer.ToTherapistID == therapistID {
+			transfers = append(transfers, transfer)
+		}
+	}
+	sort.Slice(transfers, func(i, j int) bool {
+		if transfers[i].CreatedAt.Equal(transfers[j].CreatedAt) {
+			return transfers[i].TransferID > transfers[j].TransferID
+		}
+		return transfers[i].CreatedAt.After(transfers[j].CreatedAt)
+	})
+	return transfers, nil
+}
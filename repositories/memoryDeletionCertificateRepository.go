@@ -0,0 +1,52 @@
+package repositories
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"golang-speakbackend/models"
+)
+
+type memoryDeletionCertificateRepository struct {
+	mu           sync.RWMutex
+	certificates map[string]models.DeletionCertificate
+}
+
+func newMemoryDeletionCertificateRepository() *memoryDeletionCertificateRepository {
+	return &memoryDeletionCertificateRepository{certificates: map[string]models.DeletionCertificate{}}
+}
+
+func (r *memoryDeletionCertificateRepository) Insert(ctx context.Context, certificate *models.DeletionCertificate) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.certificates[certificate.CertificateID] = *certificate
+	return nil
+}
+
+func (r *memoryDeletionCertificateRepository) FindByUserID(ctx context.Context, userID string) (*models.DeletionCertificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, certificate := range r.certificates {
+		if certificate.UserID == userID {
+			return &certificate, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memoryDeletionCertificateRepository) List(ctx context.Context, skip int64, limit int64) ([]models.DeletionCertificate, int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	certificates := []models.DeletionCertificate{}
+	for _, certificate := range r.certificates {
+		certificates = append(certificates, certificate)
+	}
+	sort.Slice(certificates, func(i, j int) bool {
+		if certificates[i].PurgedAt.Equal(certificates[j].PurgedAt) {
+			return certificates[i].CertificateID > certificates[j].CertificateID
+		}
+		return certificates[i].PurgedAt.After(certificates[j].PurgedAt)
+	})
+	return paginate(certificates, skip, limit), int64(len(certificates)), nil
+}
@@ -0,0 +1,119 @@
+package repositories
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"golang-speakbackend/models"
+)
+
+type memoryExerciseRepository struct {
+	mu        sync.RWMutex
+	exercises map[string]models.Exercise
+}
+
+func newMemoryExerciseRepository() *memoryExerciseRepository {
+	return &memoryExerciseRepository{exercises: map[string]models.Exercise{}}
+}
+
+func (r *memoryExerciseRepository) Insert(ctx context.Context, exercise *models.Exercise) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.exercises[exercise.ExerciseID] = *exercise
+	return nil
+}
+
+func (r *memoryExerciseRepository) FindByID(ctx context.Context, exerciseID string) (*models.Exercise, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	exercise, ok := r.exercises[exerciseID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &exercise, nil
+}
+
+// filter returns every exercise matching the predicate, oldest first.
+func (r *memoryExerciseRepository) filter(match func(models.Exercise) bool) []models.Exercise {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	exercises := []models.Exercise{}
+	for _, exercise := range r.exercises {
+		if match(exercise) {
+			exercises = append(exercises, exercise)
+		}
+	}
+	sort.Slice(exercises, func(i, j int) bool {
+		if exercises[i].CreatedAt.Equal(exercises[j].CreatedAt) {
+			return exercises[i].ExerciseID < exercises[j].ExerciseID
+		}
+		return exercises[i].CreatedAt.Before(exercises[j].CreatedAt)
+	})
+	return exercises
+}
+
+func (r *memoryExerciseRepository) FindByIDs(ctx context.Context, exerciseIDs []string) ([]models.Exercise, error) {
+	wanted := map[string]bool{}
+	for _, exerciseID := range exerciseIDs {
+		wanted[exerciseID] = true
+	}
+	return r.filter(func(exercise models.Exercise) bool {
+		return wanted[exercise.ExerciseID]
+	}), nil
+}
+
+func (r *memoryExerciseRepository) List(ctx context.Context, skip int64, limit int64) ([]models.Exercise, int64, error) {
+	exercises := r.filter(func(exercise models.Exercise) bool { return exercise.ArchivedAt == nil })
+	return paginate(exercises, skip, limit), int64(len(exercises)), nil
+}
+
+func (r *memoryExerciseRepository) Update(ctx context.Context, exerciseID string, update ExerciseUpdate) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	exercise, ok := r.exercises[exerciseID]
+	if !ok {
+		return ErrNotFound
+	}
+	if update.Name != nil {
+		name := *update.Name
+		exercise.Name = &name
+	}
+	if update.Description != nil {
+		description := *update.Description
+		exercise.Description = &description
+	}
+	if update.VideoURL != nil {
+		exercise.VideoURL = *update.VideoURL
+	}
+	if update.Tags != nil {
+		exercise.Tags = update.Tags
+	}
+	exercise.UpdatedAt = time.Now()
+	r.exercises[exerciseID] = exercise
+	return nil
+}
+
+func (r *memoryExerciseRepository) Archive(ctx context.Context, exerciseID string, archivedAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	exercise, ok := r.exercises[exerciseID]
+	if !ok {
+		return ErrNotFound
+	}
+	exercise.ArchivedAt = &archivedAt
+	exercise.UpdatedAt = time.Now()
+	r.exercises[exerciseID] = exercise
+	return nil
+}
+
+func (r *memoryExerciseRepository) Delete(ctx context.Context, exerciseID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.exercises[exerciseID]; !ok {
+		return ErrNotFound
+	}
+	delete(r.exercises, exerciseID)
+	return nil
+}
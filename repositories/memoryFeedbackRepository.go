@@ -0,0 +1,54 @@
+package repositories
+
+import (
+	"context"
+	"sync"
+
+	"golang-speakbackend/models"
+)
+
+type memoryFeedbackRepository struct {
+	mu       sync.RWMutex
+	feedback []models.Feedback
+}
+
+func newMemoryFeedbackRepository() *memoryFeedbackRepository {
+	return &memoryFeedbackRepository{}
+}
+
+func (r *memoryFeedbackRepository) Insert(ctx context.Context, feedback *models.Feedback) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.feedback = append(r.feedback, *feedback)
+	return nil
+}
+
+func (r *memoryFeedbackRepository) ListByPatientExercise(ctx context.Context, patientExerciseID string) ([]models.Feedback, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	feedback := []models.Feedback{}
+	for _, item := range r.feedback {
+		if item.PatientExerciseID == patientExerciseID {
+			feedback = append(feedback, item)
+		}
+	}
+	return feedback, nil
+}
+
+func (r *memoryFeedbackRepository) DeleteByPatientExercises(ctx context.Context, patientExerciseIDs []string) error {
+	deleted := map[string]bool{}
+	for _, patientExerciseID := range patientExerciseIDs {
+		deleted[patientExerciseID] = true
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	kept := r.feedback[:0]
+	for _, item := range r.feedback {
+		if !deleted[item.PatientExerciseID] {
+			kept = append(kept, item)
+		}
+	}
+	r.feedback = kept
+	return nil
+}
@@ -0,0 +1,121 @@
+package repositories
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"golang-speakbackend/models"
+)
+
+type memoryInviteRepository struct {
+	mu      sync.RWMutex
+	invites map[string]models.Invite
+}
+
+func newMemoryInviteRepository() *memoryInviteRepository {
+	return &memoryInviteRepository{invites: map[string]models.Invite{}}
+}
+
+func (r *memoryInviteRepository) Insert(ctx context.Context, invite *models.Invite) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.invites[invite.InviteID] = *invite
+	return nil
+}
+
+func (r *memoryInviteRepository) FindByID(ctx context.Context, inviteID string) (*models.Invite, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	invite, ok := r.invites[inviteID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &invite, nil
+}
+
+func (r *memoryInviteRepository) findOne(match func(models.Invite) bool) (*models.Invite, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, invite := range r.invites {
+		if match(invite) {
+			return &invite, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memoryInviteRepository) FindByCodeHash(ctx context.Context, codeHash string) (*models.Invite, error) {
+	return r.findOne(func(invite models.Invite) bool {
+		return invite.CodeHash != "" && invite.CodeHash == codeHash
+	})
+}
+
+func (r *memoryInviteRepository) FindLegacy(ctx context.Context, therapistID string) (*models.Invite, error) {
+	return r.findOne(func(invite models.Invite) bool {
+		return invite.TherapistID == therapistID && invite.Legacy
+	})
+}
+
+func (r *memoryInviteRepository) ListByTherapist(ctx context.Context, therapistID string) ([]models.Invite, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	invites := []models.Invite{}
+	for _, invite := range r.invites {
+		if invite.TherapistID == therapistID {
+			invites = append(invites, invite)
+		}
+	}
+	sort.Slice(invites, func(i, j int) bool {
+		if invites[i].CreatedAt.Equal(invites[j].CreatedAt) {
+			return invites[i].InviteID > invites[j].InviteID
+		}
+		return invites[i].CreatedAt.After(invites[j].CreatedAt)
+	})
+	return invites, nil
+}
+
+func (r *memoryInviteRepository) Revoke(ctx context.Context, inviteID string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	invite, ok := r.invites[inviteID]
+	if !ok {
+		return ErrNotFound
+	}
+	invite.RevokedAt = &at
+	invite.UpdatedAt = time.Now()
+	r.invites[inviteID] = invite
+	return nil
+}
+
+func (r *memoryInviteRepository) Redeem(ctx context.Context, inviteID string, redemption models.InviteRedemption, now time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	invite, ok := r.invites[inviteID]
+	if !ok || invite.RevokedAt != nil {
+		return ErrConflict
+	}
+	if invite.ExpiresAt != nil && !now.Before(*invite.ExpiresAt) {
+		return ErrConflict
+	}
+	if invite.MaxUses > 0 && invite.Uses >= invite.MaxUses {
+		return ErrConflict
+	}
+	invite.Uses++
+	invite.Redemptions = append(append([]models.InviteRedemption{}, invite.Redemptions...), redemption)
+	invite.UpdatedAt = time.Now()
+	r.invites[inviteID] = invite
+	return nil
+}
+
+func (r *memoryInviteRepository) DeleteByTherapist(ctx context.Context, therapistID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for inviteID, invite := range r.invites {
+		if invite.TherapistID == therapistID {
+			delete(r.invites, inviteID)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package repositories
+
+import (
+	"context"
+	"sync"
+
+	"golang-speakbackend/models"
+)
+
+type memoryKeyAccessLogRepository struct {
+	mu      sync.RWMutex
+	entries []models.KeyAccessLog
+}
+
+func newMemoryKeyAccessLogRepository() *memoryKeyAccessLogRepository {
+	return &memoryKeyAccessLogRepository{}
+}
+
+func (r *memoryKeyAccessLogRepository) Insert(ctx context.Context, entry *models.KeyAccessLog) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = append(r.entries, *entry)
+	return nil
+}
+
+func (r *memoryKeyAccessLogRepository) ListByPatient(ctx context.Context, patientID string, skip int64, limit int64) ([]models.KeyAccessLog, int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	entries := []models.KeyAccessLog{}
+	for i := len(r.entries) - 1; i >= 0; i-- {
+		if r.entries[i].PatientID == patientID {
+			entries = append(entries, r.entries[i])
+		}
+	}
+	return paginate(entries, skip, limit), int64(len(entries)), nil
+}
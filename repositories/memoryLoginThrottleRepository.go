@@ -0,0 +1,63 @@
+package repositories
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang-speakbackend/models"
+)
+
+type memoryLoginThrottleRepository struct {
+	mu        sync.Mutex
+	throttles map[string]models.LoginThrottle
+}
+
+func newMemoryLoginThrottleRepository() *memoryLoginThrottleRepository {
+	return &memoryLoginThrottleRepository{throttles: map[string]models.LoginThrottle{}}
+}
+
+func (r *memoryLoginThrottleRepository) Find(ctx context.Context, key string) (*models.LoginThrottle, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	throttle, ok := r.throttles[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &throttle, nil
+}
+
+func (r *memoryLoginThrottleRepository) RecordFailure(ctx context.Context, key string, at time.Time, resetBefore time.Time) (*models.LoginThrottle, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	throttle, ok := r.throttles[key]
+	if !ok {
+		throttle = models.LoginThrottle{Key: key}
+	}
+	if throttle.LastFailureAt.Before(resetBefore) {
+		throttle.Failures = 0
+	}
+	throttle.Failures++
+	throttle.LastFailureAt = at
+	r.throttles[key] = throttle
+	return &throttle, nil
+}
+
+func (r *memoryLoginThrottleRepository) Lock(ctx context.Context, key string, until time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	throttle, ok := r.throttles[key]
+	if !ok {
+		return nil
+	}
+	throttle.LockedUntil = &until
+	r.throttles[key] = throttle
+	return nil
+}
+
+func (r *memoryLoginThrottleRepository) Reset(ctx context.Context, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.throttles, key)
+	return nil
+}
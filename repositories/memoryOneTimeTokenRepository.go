@@ -0,0 +1,74 @@
+package repositories
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang-speakbackend/models"
+)
+
+type memoryOneTimeTokenRepository struct {
+	mu     sync.Mutex
+	tokens map[string]models.OneTimeToken
+}
+
+func newMemoryOneTimeTokenRepository() *memoryOneTimeTokenRepository {
+	return &memoryOneTimeTokenRepository{tokens: map[string]models.OneTimeToken{}}
+}
+
+func (r *memoryOneTimeTokenRepository) Insert(ctx context.Context, token *models.OneTimeToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tokens[token.TokenID] = *token
+	return nil
+}
+
+func (r *memoryOneTimeTokenRepository) FindByHash(ctx context.Context, purpose string, tokenHash string) (*models.OneTimeToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, token := range r.tokens {
+		if token.Purpose == purpose && token.TokenHash == tokenHash {
+			return &token, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memoryOneTimeTokenRepository) Consume(ctx context.Context, tokenID string, usedAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	token, ok := r.tokens[tokenID]
+	if !ok {
+		return ErrNotFound
+	}
+	if token.UsedAt != nil {
+		return ErrConflict
+	}
+	token.UsedAt = &usedAt
+	r.tokens[tokenID] = token
+	return nil
+}
+
+func (r *memoryOneTimeTokenRepository) ConsumeAllForUser(ctx context.Context, userID string, purpose string, usedAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for tokenID, token := range r.tokens {
+		if token.UserID == userID && token.Purpose == purpose && token.UsedAt == nil {
+			token.UsedAt = &usedAt
+			r.tokens[tokenID] = token
+		}
+	}
+	return nil
+}
+
+func (r *memoryOneTimeTokenRepository) DeleteAllForUser(ctx context.Context, userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for tokenID, token := range r.tokens {
+		if token.UserID == userID {
+			delete(r.tokens, tokenID)
+		}
+	}
+	return nil
+}
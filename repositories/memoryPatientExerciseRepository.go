@@ -0,0 +1,197 @@
+package repositories
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"golang-speakbackend/models"
+)
+
+type memoryPatientExerciseRepository struct {
+	mu               sync.RWMutex
+	patientExercises map[string]models.PatientExercise
+}
+
+func newMemoryPatientExerciseRepository() *memoryPatientExerciseRepository {
+	return &memoryPatientExerciseRepository{patientExercises: map[string]models.PatientExercise{}}
+}
+
+func (r *memoryPatientExerciseRepository) InsertMany(ctx context.Context, patientExercises []models.PatientExercise) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, patientExercise := range patientExercises {
+		r.patientExercises[patientExercise.PatientExerciseID] = patientExercise
+	}
+	return nil
+}
+
+func (r *memoryPatientExerciseRepository) FindByID(ctx context.Context, patientExerciseID string) (*models.PatientExercise, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	patientExercise, ok := r.patientExercises[patientExerciseID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &patientExercise, nil
+}
+
+// filter returns every patient exercise matching the predicate, oldest first.
+func (r *memoryPatientExerciseRepository) filter(match func(models.PatientExercise) bool) []models.PatientExercise {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	patientExercises := []models.PatientExercise{}
+	for _, patientExercise := range r.patientExercises {
+		if match(patientExercise) {
+			patientExercises = append(patientExercises, patientExercise)
+		}
+	}
+	sort.Slice(patientExercises, func(i, j int) bool {
+		if patientExercises[i].CreatedAt.Equal(patientExercises[j].CreatedAt) {
+			return patientExercises[i].PatientExerciseID < patientExercises[j].PatientExerciseID
+		}
+		return patientExercises[i].CreatedAt.Before(patientExercises[j].CreatedAt)
+	})
+	return patientExercises
+}
+
+func (r *memoryPatientExerciseRepository) ListByPatient(ctx context.Context, patientID string) ([]models.PatientExercise, error) {
+	return r.filter(func(patientExercise models.PatientExercise) bool {
+		return patientExercise.PatientID != nil && *patientExercise.PatientID == patientID
+	}), nil
+}
+
+func (r *memoryPatientExerciseRepository) ListByTherapist(ctx context.Context, therapistID string) ([]models.PatientExercise, error) {
+	return r.filter(func(patientExercise models.PatientExercise) bool {
+		return patientExercise.TherapistID != nil && *patientExercise.TherapistID == therapistID
+	}), nil
+}
+
+func (r *memoryPatientExerciseRepository) ListByExercise(ctx context.Context, exerciseID string) ([]models.PatientExercise, error) {
+	return r.filter(func(patientExercise models.PatientExercise) bool {
+		return patientExercise.ExerciseID != nil && *patientExercise.ExerciseID == exerciseID
+	}), nil
+}
+
+func (r *memoryPatientExerciseRepository) ListExpiredUploads(ctx context.Context, before time.Time) ([]models.PatientExercise, error) {
+	return r.filter(func(patientExercise models.PatientExercise) bool {
+		return patientExercise.Status == models.StatusUploadPending &&
+			patientExercise.UploadExpiresAt != nil && patientExercise.UploadExpiresAt.Before(before)
+	}), nil
+}
+
+func (r *memoryPatientExerciseRepository) Update(ctx context.Context, patientExerciseID string, update PatientExerciseUpdate) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	patientExercise, ok := r.patientExercises[patientExerciseID]
+	if !ok {
+		return ErrNotFound
+	}
+	applyPatientExerciseUpdate(&patientExercise, update)
+	r.patientExercises[patientExerciseID] = patientExercise
+	return nil
+}
+
+func applyPatientExerciseUpdate(patientExercise *models.PatientExercise, update PatientExerciseUpdate) {
+	if update.Recording != nil {
+		patientExercise.Recording = *update.Recording
+	}
+	if update.WrappedKey != nil {
+		patientExercise.WrappedKey = *update.WrappedKey
+	}
+	if update.UploadExpiresAt != nil {
+		expiresAt := *update.UploadExpiresAt
+		patientExercise.UploadExpiresAt = &expiresAt
+	}
+	if update.CurrentAttemptID != nil {
+		patientExercise.CurrentAttemptID = *update.CurrentAttemptID
+	}
+	patientExercise.UpdatedAt = time.Now()
+}
+
+func (r *memoryPatientExerciseRepository) Transition(ctx context.Context, patientExerciseID string, fromStatus string, transition models.StatusTransition, update PatientExerciseUpdate) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	patientExercise, ok := r.patientExercises[patientExerciseID]
+	if !ok {
+		return ErrNotFound
+	}
+	if patientExercise.Status != fromStatus {
+		return ErrConflict
+	}
+	applyPatientExerciseUpdate(&patientExercise, update)
+	patientExercise.Status = transition.To
+	patientExercise.StatusHistory = append(append([]models.StatusTransition{}, patientExercise.StatusHistory...), transition)
+	r.patientExercises[patientExerciseID] = patientExercise
+	return nil
+}
+
+func (r *memoryPatientExerciseRepository) Delete(ctx context.Context, patientExerciseID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.patientExercises[patientExerciseID]; !ok {
+		return ErrNotFound
+	}
+	delete(r.patientExercises, patientExerciseID)
+	return nil
+}
+
+func (r *memoryPatientExerciseRepository) deleteWhere(match func(models.PatientExercise) bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for patientExerciseID, patientExercise := range r.patientExercises {
+		if match(patientExercise) {
+			delete(r.patientExercises, patientExerciseID)
+		}
+	}
+}
+
+func (r *memoryPatientExerciseRepository) DeleteByPatient(ctx context.Context, patientID string) error {
+	r.deleteWhere(func(patientExercise models.PatientExercise) bool {
+		return patientExercise.PatientID != nil && *patientExercise.PatientID == patientID
+	})
+	return nil
+}
+
+func (r *memoryPatientExerciseRepository) DeleteByTherapist(ctx context.Context, therapistID string) error {
+	r.deleteWhere(func(patientExercise models.PatientExercise) bool {
+		return patientExercise.TherapistID != nil && *patientExercise.TherapistID == therapistID
+	})
+	return nil
+}
+
+func (r *memoryPatientExerciseRepository) Reassign(ctx context.Context, patientID string, fromTherapistID string, toTherapistID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for patientExerciseID, patientExercise := range r.patientExercises {
+		if patientExercise.PatientID == nil || *patientExercise.PatientID != patientID ||
+			patientExercise.TherapistID == nil || *patientExercise.TherapistID != fromTherapistID {
+			continue
+		}
+		if patientExercise.AuthoredBy == "" {
+			patientExercise.AuthoredBy = fromTherapistID
+		}
+		therapistID := toTherapistID
+		patientExercise.TherapistID = &therapistID
+		patientExercise.UpdatedAt = time.Now()
+		r.patientExercises[patientExerciseID] = patientExercise
+	}
+	return nil
+}
+
+func (r *memoryPatientExerciseRepository) DeleteByExercise(ctx context.Context, exerciseID string) error {
+	r.deleteWhere(func(patientExercise models.PatientExercise) bool {
+		return patientExercise.ExerciseID != nil && *patientExercise.ExerciseID == exerciseID
+	})
+	return nil
+}
+
+func (r *memoryPatientExerciseRepository) DeleteBySchedule(ctx context.Context, scheduleID string, dueAfter time.Time, status string) error {
+	r.deleteWhere(func(patientExercise models.PatientExercise) bool {
+		return patientExercise.ScheduleID == scheduleID &&
+			patientExercise.DueDate != nil && patientExercise.DueDate.After(dueAfter) &&
+			patientExercise.Status == status
+	})
+	return nil
+}
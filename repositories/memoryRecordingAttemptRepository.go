@@ -0,0 +1,90 @@
+package repositories
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"golang-speakbackend/models"
+)
+
+type memoryRecordingAttemptRepository struct {
+	mu       sync.RWMutex
+	attempts map[string]models.RecordingAttempt
+}
+
+func newMemoryRecordingAttemptRepository() *memoryRecordingAttemptRepository {
+	return &memoryRecordingAttemptRepository{attempts: map[string]models.RecordingAttempt{}}
+}
+
+func (r *memoryRecordingAttemptRepository) Insert(ctx context.Context, attempt *models.RecordingAttempt) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.attempts[attempt.AttemptID] = *attempt
+	return nil
+}
+
+func (r *memoryRecordingAttemptRepository) FindByID(ctx context.Context, attemptID string) (*models.RecordingAttempt, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	attempt, ok := r.attempts[attemptID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &attempt, nil
+}
+
+func (r *memoryRecordingAttemptRepository) ListByPatientExercise(ctx context.Context, patientExerciseID string) ([]models.RecordingAttempt, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	attempts := []models.RecordingAttempt{}
+	for _, attempt := range r.attempts {
+		if attempt.PatientExerciseID == patientExerciseID {
+			attempts = append(attempts, attempt)
+		}
+	}
+	sort.Slice(attempts, func(i, j int) bool {
+		return attempts[i].Number < attempts[j].Number
+	})
+	return attempts, nil
+}
+
+func (r *memoryRecordingAttemptRepository) Update(ctx context.Context, attemptID string, update RecordingAttemptUpdate) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	attempt, ok := r.attempts[attemptID]
+	if !ok {
+		return ErrNotFound
+	}
+	if update.Status != nil {
+		attempt.Status = *update.Status
+	}
+	if update.Size != nil {
+		attempt.Size = *update.Size
+	}
+	if update.DurationMs != nil {
+		attempt.DurationMs = *update.DurationMs
+	}
+	if update.UploadedAt != nil {
+		uploadedAt := *update.UploadedAt
+		attempt.UploadedAt = &uploadedAt
+	}
+	r.attempts[attemptID] = attempt
+	return nil
+}
+
+func (r *memoryRecordingAttemptRepository) DeleteByPatientExercises(ctx context.Context, patientExerciseIDs []string) error {
+	deleted := map[string]bool{}
+	for _, patientExerciseID := range patientExerciseIDs {
+		deleted[patientExerciseID] = true
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for attemptID, attempt := range r.attempts {
+		if deleted[attempt.PatientExerciseID] {
+			delete(r.attempts, attemptID)
+		}
+	}
+	return nil
+}
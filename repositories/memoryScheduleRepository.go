@@ -0,0 +1,148 @@
+package repositories
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"golang-speakbackend/models"
+)
+
+type memoryScheduleRepository struct {
+	mu        sync.RWMutex
+	schedules map[string]models.AssignmentSchedule
+}
+
+func newMemoryScheduleRepository() *memoryScheduleRepository {
+	return &memoryScheduleRepository{schedules: map[string]models.AssignmentSchedule{}}
+}
+
+func (r *memoryScheduleRepository) Insert(ctx context.Context, schedule *models.AssignmentSchedule) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.schedules[schedule.ScheduleID] = *schedule
+	return nil
+}
+
+func (r *memoryScheduleRepository) FindByID(ctx context.Context, scheduleID string) (*models.AssignmentSchedule, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	schedule, ok := r.schedules[scheduleID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &schedule, nil
+}
+
+// filter returns every schedule matching the predicate, oldest first.
+func (r *memoryScheduleRepository) filter(match func(models.AssignmentSchedule) bool) []models.AssignmentSchedule {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	schedules := []models.AssignmentSchedule{}
+	for _, schedule := range r.schedules {
+		if match(schedule) {
+			schedules = append(schedules, schedule)
+		}
+	}
+	sort.Slice(schedules, func(i, j int) bool {
+		return schedules[i].CreatedAt.Before(schedules[j].CreatedAt)
+	})
+	return schedules
+}
+
+func (r *memoryScheduleRepository) ListByPatient(ctx context.Context, patientID string) ([]models.AssignmentSchedule, error) {
+	return r.filter(func(schedule models.AssignmentSchedule) bool {
+		return schedule.PatientID != nil && *schedule.PatientID == patientID
+	}), nil
+}
+
+func (r *memoryScheduleRepository) ListByTherapist(ctx context.Context, therapistID string) ([]models.AssignmentSchedule, error) {
+	return r.filter(func(schedule models.AssignmentSchedule) bool {
+		return schedule.TherapistID != nil && *schedule.TherapistID == therapistID
+	}), nil
+}
+
+func (r *memoryScheduleRepository) ListByExercise(ctx context.Context, exerciseID string) ([]models.AssignmentSchedule, error) {
+	return r.filter(func(schedule models.AssignmentSchedule) bool {
+		return schedule.ExerciseID != nil && *schedule.ExerciseID == exerciseID
+	}), nil
+}
+
+func (r *memoryScheduleRepository) AdvanceGeneratedThrough(ctx context.Context, scheduleID string, from time.Time, to time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	schedule, ok := r.schedules[scheduleID]
+	if !ok || !schedule.GeneratedThrough.Equal(from) {
+		return false, nil
+	}
+	schedule.GeneratedThrough = to
+	schedule.UpdatedAt = time.Now()
+	r.schedules[scheduleID] = schedule
+	return true, nil
+}
+
+func (r *memoryScheduleRepository) End(ctx context.Context, scheduleID string, endDate time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	schedule, ok := r.schedules[scheduleID]
+	if !ok {
+		return ErrNotFound
+	}
+	schedule.EndDate = endDate
+	schedule.UpdatedAt = time.Now()
+	r.schedules[scheduleID] = schedule
+	return nil
+}
+
+func (r *memoryScheduleRepository) DeleteByExercise(ctx context.Context, exerciseID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for scheduleID, schedule := range r.schedules {
+		if schedule.ExerciseID != nil && *schedule.ExerciseID == exerciseID {
+			delete(r.schedules, scheduleID)
+		}
+	}
+	return nil
+}
+
+func (r *memoryScheduleRepository) DeleteByPatient(ctx context.Context, patientID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for scheduleID, schedule := range r.schedules {
+		if schedule.PatientID != nil && *schedule.PatientID == patientID {
+			delete(r.schedules, scheduleID)
+		}
+	}
+	return nil
+}
+
+func (r *memoryScheduleRepository) DeleteByTherapist(ctx context.Context, therapistID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for scheduleID, schedule := range r.schedules {
+		if schedule.TherapistID != nil && *schedule.TherapistID == therapistID {
+			delete(r.schedules, scheduleID)
+		}
+	}
+	return nil
+}
+
+func (r *memoryScheduleRepository) Reassign(ctx context.Context, patientID string, fromTherapistID string, toTherapistID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for scheduleID, schedule := range r.schedules {
+		if schedule.PatientID == nil || *schedule.PatientID != patientID ||
+			schedule.TherapistID == nil || *schedule.TherapistID != fromTherapistID {
+			continue
+		}
+		if schedule.AuthoredBy == "" {
+			schedule.AuthoredBy = fromTherapistID
+		}
+		therapistID := toTherapistID
+		schedule.TherapistID = &therapistID
+		schedule.UpdatedAt = time.Now()
+		r.schedules[scheduleID] = schedule
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package repositories
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang-speakbackend/models"
+)
+
+type memoryTokenFamilyRepository struct {
+	mu       sync.Mutex
+	families map[string]models.TokenFamily
+}
+
+func newMemoryTokenFamilyRepository() *memoryTokenFamilyRepository {
+	return &memoryTokenFamilyRepository{families: map[string]models.TokenFamily{}}
+}
+
+func (r *memoryTokenFamilyRepository) Insert(ctx context.Context, family *models.TokenFamily) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.families[family.FamilyID] = *family
+	return nil
+}
+
+func (r *memoryTokenFamilyRepository) FindByID(ctx context.Context, familyID string) (*models.TokenFamily, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	family, ok := r.families[familyID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &family, nil
+}
+
+func (r *memoryTokenFamilyRepository) Rotate(ctx context.Context, familyID string, fromTokenID string, toTokenID string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	family, ok := r.families[familyID]
+	if !ok {
+		return ErrNotFound
+	}
+	if family.CurrentTokenID != fromTokenID || family.RevokedAt != nil {
+		return ErrConflict
+	}
+	family.CurrentTokenID = toTokenID
+	family.RotatedAt = &at
+	r.families[familyID] = family
+	return nil
+}
+
+func (r *memoryTokenFamilyRepository) Revoke(ctx context.Context, familyID string, reason string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	family, ok := r.families[familyID]
+	if !ok {
+		return ErrNotFound
+	}
+	if family.RevokedAt == nil {
+		family.RevokedAt = &at
+		family.RevokedReason = reason
+		r.families[familyID] = family
+	}
+	return nil
+}
+
+func (r *memoryTokenFamilyRepository) RevokeAllForUser(ctx context.Context, userID string, reason string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for familyID, family := range r.families {
+		if family.UserID == userID && family.RevokedAt == nil {
+			family.RevokedAt = &at
+			family.RevokedReason = reason
+			r.families[familyID] = family
+		}
+	}
+	return nil
+}
+
+func (r *memoryTokenFamilyRepository) DeleteAllForUser(ctx context.Context, userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for familyID, family := range r.families {
+		if family.UserID == userID {
+			delete(r.families, familyID)
+		}
+	}
+	return nil
+}
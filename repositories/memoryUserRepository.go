@@ -0,0 +1,273 @@
+package repositories
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"golang-speakbackend/models"
+)
+
+type memoryUserRepository struct {
+	mu    sync.RWMutex
+	users map[string]models.User
+}
+
+func newMemoryUserRepository() *memoryUserRepository {
+	return &memoryUserRepository{users: map[string]models.User{}}
+}
+
+func (r *memoryUserRepository) Insert(ctx context.Context, user *models.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.users[user.UserID] = *user
+	return nil
+}
+
+func (r *memoryUserRepository) FindByID(ctx context.Context, userID string) (*models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	user, ok := r.users[userID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &user, nil
+}
+
+// first returns the first user, in insertion order, matching the predicate.
+func (r *memoryUserRepository) first(match func(models.User) bool) (*models.User, error) {
+	users := r.filter(match)
+	if len(users) == 0 {
+		return nil, ErrNotFound
+	}
+	return &users[0], nil
+}
+
+// filter returns every user matching the predicate, oldest first.
+func (r *memoryUserRepository) filter(match func(models.User) bool) []models.User {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	users := []models.User{}
+	for _, user := range r.users {
+		if match(user) {
+			users = append(users, user)
+		}
+	}
+	sort.Slice(users, func(i, j int) bool {
+		if users[i].CreatedAt.Equal(users[j].CreatedAt) {
+			return users[i].UserID < users[j].UserID
+		}
+		return users[i].CreatedAt.Before(users[j].CreatedAt)
+	})
+	return users
+}
+
+func (r *memoryUserRepository) FindByEmail(ctx context.Context, email string) (*models.User, error) {
+	return r.first(func(user models.User) bool {
+		return user.Email != nil && *user.Email == email
+	})
+}
+
+func (r *memoryUserRepository) FindByIDs(ctx context.Context, userIDs []string) ([]models.User, error) {
+	wanted := map[string]bool{}
+	for _, userID := range userIDs {
+		wanted[userID] = true
+	}
+	return r.filter(func(user models.User) bool {
+		return wanted[user.UserID]
+	}), nil
+}
+
+func (r *memoryUserRepository) FindTherapistByReferenceCode(ctx context.Context, referenceCode string) (*models.User, error) {
+	return r.first(func(user models.User) bool {
+		return user.Role == "therapist" && user.ReferenceCode == referenceCode
+	})
+}
+
+func (r *memoryUserRepository) EmailExists(ctx context.Context, email string) (bool, error) {
+	_, err := r.FindByEmail(ctx, email)
+	if err == ErrNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (r *memoryUserRepository) ReferenceCodeExists(ctx context.Context, referenceCode string) (bool, error) {
+	users := r.filter(func(user models.User) bool {
+		return user.ReferenceCode == referenceCode
+	})
+	return len(users) > 0, nil
+}
+
+func (r *memoryUserRepository) List(ctx context.Context, skip int64, limit int64) ([]models.User, int64, error) {
+	users := r.filter(func(models.User) bool { return true })
+	return paginate(users, skip, limit), int64(len(users)), nil
+}
+
+func (r *memoryUserRepository) ListLinkedPatients(ctx context.Context) ([]models.User, error) {
+	return r.filter(func(user models.User) bool {
+		return user.Role == "patient" && user.ReferenceCode != ""
+	}), nil
+}
+
+func (r *memoryUserRepository) Update(ctx context.Context, userID string, update UserUpdate) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.users[userID]
+	if !ok {
+		return ErrNotFound
+	}
+	if update.FirstName != nil {
+		firstName := *update.FirstName
+		user.FirstName = &firstName
+	}
+	if update.LastName != nil {
+		lastName := *update.LastName
+		user.LastName = &lastName
+	}
+	if update.Email != nil {
+		email := *update.Email
+		user.Email = &email
+	}
+	if update.PendingEmail != nil {
+		user.PendingEmail = *update.PendingEmail
+	}
+	if update.Token != nil {
+		token := *update.Token
+		user.Token = &token
+	}
+	if update.RefreshToken != nil {
+		refreshToken := *update.RefreshToken
+		user.RefreshToken = &refreshToken
+	}
+	if update.ReferenceCode != nil {
+		user.ReferenceCode = *update.ReferenceCode
+	}
+	if update.ProfileImage != nil {
+		user.ProfileImage = *update.ProfileImage
+	}
+	if update.AccountStatus != nil {
+		user.AccountStatus = *update.AccountStatus
+	}
+	if update.MFA != nil {
+		mfa := *update.MFA
+		mfa.RecoveryCodes = append([]string(nil), mfa.RecoveryCodes...)
+		user.MFA = mfa
+	}
+	if update.Password != nil {
+		password := *update.Password
+		changedAt := time.Now().UTC()
+		user.Password = &password
+		user.PasswordChangedAt = &changedAt
+	}
+	user.UpdatedAt = time.Now()
+	r.users[userID] = user
+	return nil
+}
+
+func (r *memoryUserRepository) UseMFAStep(ctx context.Context, userID string, step int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.users[userID]
+	if !ok {
+		return ErrNotFound
+	}
+	if user.MFA.LastStep >= step {
+		return ErrConflict
+	}
+	user.MFA.LastStep = step
+	r.users[userID] = user
+	return nil
+}
+
+func (r *memoryUserRepository) ConsumeRecoveryCode(ctx context.Context, userID string, codeHash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.users[userID]
+	if !ok {
+		return ErrNotFound
+	}
+	for i, hash := range user.MFA.RecoveryCodes {
+		if hash == codeHash {
+			codes := append([]string(nil), user.MFA.RecoveryCodes[:i]...)
+			user.MFA.RecoveryCodes = append(codes, user.MFA.RecoveryCodes[i+1:]...)
+			r.users[userID] = user
+			return nil
+		}
+	}
+	return ErrConflict
+}
+
+func (r *memoryUserRepository) UnlinkPatients(ctx context.Context, referenceCode string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for userID, user := range r.users {
+		if user.Role == "patient" && user.ReferenceCode == referenceCode {
+			user.ReferenceCode = ""
+			r.users[userID] = user
+		}
+	}
+	return nil
+}
+
+func (r *memoryUserRepository) MarkDeleted(ctx context.Context, userID string, deletedBy string, at time.Time, purgeAfter time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.users[userID]
+	if !ok {
+		return ErrNotFound
+	}
+	if user.DeletedAt != nil {
+		return ErrConflict
+	}
+	user.DeletedAt = &at
+	user.DeletedBy = deletedBy
+	user.PurgeAfter = &purgeAfter
+	user.UpdatedAt = time.Now()
+	r.users[userID] = user
+	return nil
+}
+
+func (r *memoryUserRepository) Restore(ctx context.Context, userID string, now time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.users[userID]
+	if !ok {
+		return ErrNotFound
+	}
+	if user.PurgeAfter == nil || !user.PurgeAfter.After(now) {
+		return ErrConflict
+	}
+	user.DeletedAt = nil
+	user.DeletedBy = ""
+	user.PurgeAfter = nil
+	user.UpdatedAt = time.Now()
+	r.users[userID] = user
+	return nil
+}
+
+func (r *memoryUserRepository) ListPurgeable(ctx context.Context, now time.Time) ([]models.User, error) {
+	return r.filter(func(user models.User) bool {
+		return user.PurgeAfter != nil && !user.PurgeAfter.After(now)
+	}), nil
+}
+
+func (r *memoryUserRepository) Delete(ctx context.Context, userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.users, userID)
+	return nil
+}
+
+// paginate applies skip and limit to an already ordered slice.
+func paginate[T any](items []T, skip int64, limit int64) []T {
+	if skip >= int64(len(items)) {
+		return []T{}
+	}
+	items = items[skip:]
+	if limit > 0 && limit < int64(len(items)) {
+		items = items[:limit]
+	}
+	return items
+}
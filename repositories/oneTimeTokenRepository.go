@@ -0,0 +1,79 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"golang-speakbackend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// OneTimeTokenRepository stores hashed single-use tokens such as password
+// reset tokens.
+type OneTimeTokenRepository interface {
+	Insert(ctx context.Context, token *models.OneTimeToken) error
+	FindByHash(ctx context.Context, purpose string, tokenHash string) (*models.OneTimeToken, error)
+	// Consume marks the token used. It returns ErrConflict if it already was.
+	Consume(ctx context.Context, tokenID string, usedAt time.Time) error
+	// ConsumeAllForUser marks every unused token of the user with the given
+	// purpose as used.
+	ConsumeAllForUser(ctx context.Context, userID string, purpose string, usedAt time.Time) error
+	DeleteAllForUser(ctx context.Context, userID string) error
+}
+
+type mongoOneTimeTokenRepository struct {
+	collection *mongo.Collection
+}
+
+func (r *mongoOneTimeTokenRepository) Insert(ctx context.Context, token *models.OneTimeToken) error {
+	_, err := r.collection.InsertOne(ctx, token)
+	return err
+}
+
+func (r *mongoOneTimeTokenRepository) FindByHash(ctx context.Context, purpose string, tokenHash string) (*models.OneTimeToken, error) {
+	var token models.OneTimeToken
+	err := r.collection.FindOne(ctx, bson.M{"purpose": purpose, "token_hash": tokenHash}).Decode(&token)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (r *mongoOneTimeTokenRepository) Consume(ctx context.Context, tokenID string, usedAt time.Time) error {
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"token_id": tokenID, "used_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"used_at": usedAt}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		count, err := r.collection.CountDocuments(ctx, bson.M{"token_id": tokenID})
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			return ErrNotFound
+		}
+		return ErrConflict
+	}
+	return nil
+}
+
+func (r *mongoOneTimeTokenRepository) ConsumeAllForUser(ctx context.Context, userID string, purpose string, usedAt time.Time) error {
+	_, err := r.collection.UpdateMany(ctx,
+		bson.M{"user_id": userID, "purpose": purpose, "used_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"used_at": usedAt}},
+	)
+	return err
+}
+
+func (r *mongoOneTimeTokenRepository) DeleteAllForUser(ctx context.Context, userID string) error {
+	_, err := r.collection.DeleteMany(ctx, bson.M{"user_id": userID})
+	return err
+}
@@ -0,0 +1,224 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"golang-speakbackend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// PatientExerciseRepository stores exercises assigned to patients.
+type PatientExerciseRepository interface {
+	InsertMany(ctx context.Context, patientExercises []models.PatientExercise) error
+	FindByID(ctx context.Context, patientExerciseID string) (*models.PatientExercise, error)
+	ListByPatient(ctx context.Context, patientID string) ([]models.PatientExercise, error)
+	ListByTherapist(ctx context.Context, therapistID string) ([]models.PatientExercise, error)
+	ListByExercise(ctx context.Context, exerciseID string) ([]models.PatientExercise, error)
+	// ListExpiredUploads returns the patient exercises still waiting for an
+	// upload whose upload URL expired before the given time.
+	ListExpiredUploads(ctx context.Context, before time.Time) ([]models.PatientExercise, error)
+	Update(ctx context.Context, patientExerciseID string, update PatientExerciseUpdate) error
+	// Transition sets the status to transition.To, applies update and appends
+	// the transition to the history, but only if the stored status is still
+	// fromStatus. Otherwise it returns ErrConflict.
+	Transition(ctx context.Context, patientExerciseID string, fromStatus string, transition models.StatusTransition, update PatientExerciseUpdate) error
+	Delete(ctx context.Context, patientExerciseID string) error
+	DeleteByPatient(ctx context.Context, patientID string) error
+	DeleteByTherapist(ctx context.Context, therapistID string) error
+	// Reassign hands the patient's exercises from one therapist to another,
+	// recording the first therapist as author where none is recorded yet.
+	Reassign(ctx context.Context, patientID string, fromTherapistID string, toTherapistID string) error
+	DeleteByExercise(ctx context.Context, exerciseID string) error
+	// DeleteBySchedule removes the occurrences of a schedule that are due
+	// after dueAfter and still have the given status.
+	DeleteBySchedule(ctx context.Context, scheduleID string, dueAfter time.Time, status string) error
+}
+
+// PatientExerciseUpdate holds the fields to change on a patient exercise.
+// Nil fields are left as is and updated_at is always refreshed. The status
+// only changes through Transition.
+type PatientExerciseUpdate struct {
+	Recording        *string
+	WrappedKey       *string
+	UploadExpiresAt  *time.Time
+	CurrentAttemptID *string
+}
+
+type mongoPatientExerciseRepository struct {
+	collection *mongo.Collection
+}
+
+func (r *mongoPatientExerciseRepository) InsertMany(ctx context.Context, patientExercises []models.PatientExercise) error {
+	documents := make([]interface{}, 0, len(patientExercises))
+	for _, patientExercise := range patientExercises {
+		documents = append(documents, patientExercise)
+	}
+	_, err := r.collection.InsertMany(ctx, documents)
+	return err
+}
+
+func (r *mongoPatientExerciseRepository) FindByID(ctx context.Context, patientExerciseID string) (*models.PatientExercise, error) {
+	var patientExercise models.PatientExercise
+	err := r.collection.FindOne(ctx, bson.M{"patient_exercise_id": patientExerciseID}).Decode(&patientExercise)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &patientExercise, nil
+}
+
+func (r *mongoPatientExerciseRepository) ListByPatient(ctx context.Context, patientID string) ([]models.PatientExercise, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{"patient_id": patientID})
+	if err != nil {
+		return nil, err
+	}
+	patientExercises := []models.PatientExercise{}
+	if err = cursor.All(ctx, &patientExercises); err != nil {
+		return nil, err
+	}
+	return patientExercises, nil
+}
+
+func (r *mongoPatientExerciseRepository) ListByTherapist(ctx context.Context, therapistID string) ([]models.PatientExercise, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{"therapist_id": therapistID})
+	if err != nil {
+		return nil, err
+	}
+	patientExercises := []models.PatientExercise{}
+	if err = cursor.All(ctx, &patientExercises); err != nil {
+		return nil, err
+	}
+	return patientExercises, nil
+}
+
+func (r *mongoPatientExerciseRepository) ListByExercise(ctx context.Context, exerciseID string) ([]models.PatientExercise, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{"exercise_id": exerciseID})
+	if err != nil {
+		return nil, err
+	}
+	patientExercises := []models.PatientExercise{}
+	if err = cursor.All(ctx, &patientExercises); err != nil {
+		return nil, err
+	}
+	return patientExercises, nil
+}
+
+func (r *mongoPatientExerciseRepository) ListExpiredUploads(ctx context.Context, before time.Time) ([]models.PatientExercise, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{
+		"status":            models.StatusUploadPending,
+		"upload_expires_at": bson.M{"$lt": before},
+	})
+	if err != nil {
+		return nil, err
+	}
+	patientExercises := []models.PatientExercise{}
+	if err = cursor.All(ctx, &patientExercises); err != nil {
+		return nil, err
+	}
+	return patientExercises, nil
+}
+
+func patientExerciseSet(update PatientExerciseUpdate) bson.D {
+	set := bson.D{}
+	if update.Recording != nil {
+		set = append(set, bson.E{Key: "recording", Value: *update.Recording})
+	}
+	if update.WrappedKey != nil {
+		set = append(set, bson.E{Key: "wrapped_key", Value: *update.WrappedKey})
+	}
+	if update.UploadExpiresAt != nil {
+		set = append(set, bson.E{Key: "upload_expires_at", Value: *update.UploadExpiresAt})
+	}
+	if update.CurrentAttemptID != nil {
+		set = append(set, bson.E{Key: "current_attempt_id", Value: *update.CurrentAttemptID})
+	}
+	set = append(set, bson.E{Key: "updated_at", Value: time.Now()})
+	return set
+}
+
+func (r *mongoPatientExerciseRepository) Update(ctx context.Context, patientExerciseID string, update PatientExerciseUpdate) error {
+	result, err := r.collection.UpdateOne(ctx, bson.M{"patient_exercise_id": patientExerciseID}, bson.D{{Key: "$set", Value: patientExerciseSet(update)}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoPatientExerciseRepository) Transition(ctx context.Context, patientExerciseID string, fromStatus string, transition models.StatusTransition, update PatientExerciseUpdate) error {
+	set := append(patientExerciseSet(update), bson.E{Key: "status", Value: transition.To})
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"patient_exercise_id": patientExerciseID, "status": fromStatus},
+		bson.D{
+			{Key: "$set", Value: set},
+			{Key: "$push", Value: bson.M{"status_history": transition}},
+		},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		if _, err := r.FindByID(ctx, patientExerciseID); err != nil {
+			return err
+		}
+		return ErrConflict
+	}
+	return nil
+}
+
+func (r *mongoPatientExerciseRepository) Delete(ctx context.Context, patientExerciseID string) error {
+	result, err := r.collection.DeleteOne(ctx, bson.M{"patient_exercise_id": patientExerciseID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoPatientExerciseRepository) DeleteByPatient(ctx context.Context, patientID string) error {
+	_, err := r.collection.DeleteMany(ctx, bson.M{"patient_id": patientID})
+	return err
+}
+
+func (r *mongoPatientExerciseRepository) DeleteByTherapist(ctx context.Context, therapistID string) error {
+	_, err := r.collection.DeleteMany(ctx, bson.M{"therapist_id": therapistID})
+	return err
+}
+
+func (r *mongoPatientExerciseRepository) Reassign(ctx context.Context, patientID string, fromTherapistID string, toTherapistID string) error {
+	_, err := r.collection.UpdateMany(ctx,
+		bson.M{"patient_id": patientID, "therapist_id": fromTherapistID, "authored_by": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"authored_by": fromTherapistID}},
+	)
+	if err != nil {
+		return err
+	}
+	_, err = r.collection.UpdateMany(ctx,
+		bson.M{"patient_id": patientID, "therapist_id": fromTherapistID},
+		bson.M{"$set": bson.M{"therapist_id": toTherapistID, "updated_at": time.Now()}},
+	)
+	return err
+}
+
+func (r *mongoPatientExerciseRepository) DeleteByExercise(ctx context.Context, exerciseID string) error {
+	_, err := r.collection.DeleteMany(ctx, bson.M{"exercise_id": exerciseID})
+	return err
+}
+
+func (r *mongoPatientExerciseRepository) DeleteBySchedule(ctx context.Context, scheduleID string, dueAfter time.Time, status string) error {
+	_, err := r.collection.DeleteMany(ctx, bson.M{
+		"schedule_id": scheduleID,
+		"due_date":    bson.M{"$gt": dueAfter},
+		"status":      status,
+	})
+	return err
+}
@@ -0,0 +1,101 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"golang-speakbackend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// RecordingAttemptRepository stores the recordings uploaded for patient
+// exercises.
+type RecordingAttemptRepository interface {
+	Insert(ctx context.Context, attempt *models.RecordingAttempt) error
+	FindByID(ctx context.Context, attemptID string) (*models.RecordingAttempt, error)
+	// ListByPatientExercise returns the attempts in the order they were made.
+	ListByPatientExercise(ctx context.Context, patientExerciseID string) ([]models.RecordingAttempt, error)
+	Update(ctx context.Context, attemptID string, update RecordingAttemptUpdate) error
+	DeleteByPatientExercises(ctx context.Context, patientExerciseIDs []string) error
+}
+
+// RecordingAttemptUpdate holds the fields to change on an attempt. Nil
+// fields are left as is.
+type RecordingAttemptUpdate struct {
+	Status     *string
+	Size       *int64
+	DurationMs *int64
+	UploadedAt *time.Time
+}
+
+type mongoRecordingAttemptRepository struct {
+	collection *mongo.Collection
+}
+
+func (r *mongoRecordingAttemptRepository) Insert(ctx context.Context, attempt *models.RecordingAttempt) error {
+	_, err := r.collection.InsertOne(ctx, attempt)
+	return err
+}
+
+func (r *mongoRecordingAttemptRepository) FindByID(ctx context.Context, attemptID string) (*models.RecordingAttempt, error) {
+	var attempt models.RecordingAttempt
+	err := r.collection.FindOne(ctx, bson.M{"attempt_id": attemptID}).Decode(&attempt)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &attempt, nil
+}
+
+func (r *mongoRecordingAttemptRepository) ListByPatientExercise(ctx context.Context, patientExerciseID string) ([]models.RecordingAttempt, error) {
+	cursor, err := r.collection.Find(ctx,
+		bson.M{"patient_exercise_id": patientExerciseID},
+		options.Find().SetSort(bson.D{{Key: "number", Value: 1}}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	attempts := []models.RecordingAttempt{}
+	if err = cursor.All(ctx, &attempts); err != nil {
+		return nil, err
+	}
+	return attempts, nil
+}
+
+func (r *mongoRecordingAttemptRepository) Update(ctx context.Context, attemptID string, update RecordingAttemptUpdate) error {
+	set := bson.D{}
+	if update.Status != nil {
+		set = append(set, bson.E{Key: "status", Value: *update.Status})
+	}
+	if update.Size != nil {
+		set = append(set, bson.E{Key: "size", Value: *update.Size})
+	}
+	if update.DurationMs != nil {
+		set = append(set, bson.E{Key: "duration_ms", Value: *update.DurationMs})
+	}
+	if update.UploadedAt != nil {
+		set = append(set, bson.E{Key: "uploaded_at", Value: *update.UploadedAt})
+	}
+	if len(set) == 0 {
+		return nil
+	}
+
+	result, err := r.collection.UpdateOne(ctx, bson.M{"attempt_id": attemptID}, bson.D{{Key: "$set", Value: set}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoRecordingAttemptRepository) DeleteByPatientExercises(ctx context.Context, patientExerciseIDs []string) error {
+	_, err := r.collection.DeleteMany(ctx, bson.M{"patient_exercise_id": bson.M{"$in": patientExerciseIDs}})
+	return err
+}
@@ -0,0 +1,103 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	// ErrNotFound is returned when a lookup or update matches no document.
+	ErrNotFound = errors.New("document not found")
+	// ErrConflict is returned when a conditional update finds the document
+	// in a different state than expected.
+	ErrConflict = errors.New("document was modified concurrently")
+)
+
+// Store groups the repositories the controllers depend on.
+type Store struct {
+	Users            UserRepository
+	Exercises        ExerciseRepository
+	PatientExercises PatientExerciseRepository
+	Schedules        ScheduleRepository
+	Feedback         FeedbackRepository
+	Attempts         RecordingAttemptRepository
+	KeyAccessLog     KeyAccessLogRepository
+	OneTimeTokens    OneTimeTokenRepository
+	TokenFamilies    TokenFamilyRepository
+	LoginThrottles   LoginThrottleRepository
+	CareTeams        CareRelationshipRepository
+	Invites          InviteRepository
+	Transfers        CaseloadTransferRepository
+	Certificates     DeletionCertificateRepository
+
+	transact func(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// WithTransaction runs fn so that the writes it makes through the context it
+// is given happen all together or not at all. MongoDB needs a replica set for
+// this. The memory store only runs one transaction at a time and doesn't roll
+// back.
+func (s *Store) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return s.transact(ctx, fn)
+}
+
+// NewMongoStore returns a Store backed by the collections of db.
+func NewMongoStore(db *mongo.Database) *Store {
+	return &Store{
+		Users:            &mongoUserRepository{collection: db.Collection("user")},
+		Exercises:        &mongoExerciseRepository{collection: db.Collection("exercise")},
+		PatientExercises: &mongoPatientExerciseRepository{collection: db.Collection("patient_exercise")},
+		Schedules:        &mongoScheduleRepository{collection: db.Collection("assignment_schedule")},
+		Feedback:         &mongoFeedbackRepository{collection: db.Collection("feedback")},
+		Attempts:         &mongoRecordingAttemptRepository{collection: db.Collection("recording_attempt")},
+		KeyAccessLog:     &mongoKeyAccessLogRepository{collection: db.Collection("key_access_log")},
+		OneTimeTokens:    &mongoOneTimeTokenRepository{collection: db.Collection("one_time_token")},
+		TokenFamilies:    &mongoTokenFamilyRepository{collection: db.Collection("token_family")},
+		LoginThrottles:   &mongoLoginThrottleRepository{collection: db.Collection("login_throttle")},
+		CareTeams:        &mongoCareRelationshipRepository{collection: db.Collection("care_relationship")},
+		Invites:          &mongoInviteRepository{collection: db.Collection("invite")},
+		Transfers:        &mongoCaseloadTransferRepository{collection: db.Collection("caseload_transfer")},
+		Certificates:     &mongoDeletionCertificateRepository{collection: db.Collection("deletion_certificate")},
+		transact: func(ctx context.Context, fn func(ctx context.Context) error) error {
+			session, err := db.Client().StartSession()
+			if err != nil {
+				return err
+			}
+			defer session.EndSession(ctx)
+			_, err = session.WithTransaction(ctx, func(sessionCtx mongo.SessionContext) (interface{}, error) {
+				return nil, fn(sessionCtx)
+			})
+			return err
+		},
+	}
+}
+
+// NewMemoryStore returns a Store that keeps everything in memory, for tests
+// and local development without a database.
+func NewMemoryStore() *Store {
+	var transactions sync.Mutex
+	return &Store{
+		Users:            newMemoryUserRepository(),
+		Exercises:        newMemoryExerciseRepository(),
+		PatientExercises: newMemoryPatientExerciseRepository(),
+		Schedules:        newMemoryScheduleRepository(),
+		Feedback:         newMemoryFeedbackRepository(),
+		Attempts:         newMemoryRecordingAttemptRepository(),
+		KeyAccessLog:     newMemoryKeyAccessLogRepository(),
+		OneTimeTokens:    newMemoryOneTimeTokenRepository(),
+		TokenFamilies:    newMemoryTokenFamilyRepository(),
+		LoginThrottles:   newMemoryLoginThrottleRepository(),
+		CareTeams:        newMemoryCareRelationshipRepository(),
+		Invites:          newMemoryInviteRepository(),
+		Transfers:        newMemoryCaseloadTransferRepository(),
+		Certificates:     newMemoryDeletionCertificateRepository(),
+		transact: func(ctx context.Context, fn func(ctx context.Context) error) error {
+			transactions.Lock()
+			defer transactions.Unlock()
+			return fn(ctx)
+		},
+	}
+}
@@ -0,0 +1,136 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"golang-speakbackend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ScheduleRepository stores recurring assignment schedules.
+type ScheduleRepository interface {
+	Insert(ctx context.Context, schedule *models.AssignmentSchedule) error
+	FindByID(ctx context.Context, scheduleID string) (*models.AssignmentSchedule, error)
+	ListByPatient(ctx context.Context, patientID string) ([]models.AssignmentSchedule, error)
+	ListByTherapist(ctx context.Context, therapistID string) ([]models.AssignmentSchedule, error)
+	ListByExercise(ctx context.Context, exerciseID string) ([]models.AssignmentSchedule, error)
+	// AdvanceGeneratedThrough moves generated_through from `from` to `to` and
+	// reports false if another caller already moved it.
+	AdvanceGeneratedThrough(ctx context.Context, scheduleID string, from time.Time, to time.Time) (bool, error)
+	End(ctx context.Context, scheduleID string, endDate time.Time) error
+	DeleteByExercise(ctx context.Context, exerciseID string) error
+	DeleteByPatient(ctx context.Context, patientID string) error
+	DeleteByTherapist(ctx context.Context, therapistID string) error
+	// Reassign hands the patient's schedules from one therapist to another,
+	// recording the first therapist as author where none is recorded yet.
+	Reassign(ctx context.Context, patientID string, fromTherapistID string, toTherapistID string) error
+}
+
+type mongoScheduleRepository struct {
+	collection *mongo.Collection
+}
+
+func (r *mongoScheduleRepository) Insert(ctx context.Context, schedule *models.AssignmentSchedule) error {
+	_, err := r.collection.InsertOne(ctx, schedule)
+	return err
+}
+
+func (r *mongoScheduleRepository) FindByID(ctx context.Context, scheduleID string) (*models.AssignmentSchedule, error) {
+	var schedule models.AssignmentSchedule
+	err := r.collection.FindOne(ctx, bson.M{"schedule_id": scheduleID}).Decode(&schedule)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+func (r *mongoScheduleRepository) ListByPatient(ctx context.Context, patientID string) ([]models.AssignmentSchedule, error) {
+	return r.find(ctx, bson.M{"patient_id": patientID})
+}
+
+func (r *mongoScheduleRepository) ListByTherapist(ctx context.Context, therapistID string) ([]models.AssignmentSchedule, error) {
+	return r.find(ctx, bson.M{"therapist_id": therapistID})
+}
+
+func (r *mongoScheduleRepository) ListByExercise(ctx context.Context, exerciseID string) ([]models.AssignmentSchedule, error) {
+	return r.find(ctx, bson.M{"exercise_id": exerciseID})
+}
+
+func (r *mongoScheduleRepository) find(ctx context.Context, filter bson.M) ([]models.AssignmentSchedule, error) {
+	cursor, err := r.collection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	schedules := []models.AssignmentSchedule{}
+	if err = cursor.All(ctx, &schedules); err != nil {
+		return nil, err
+	}
+	return schedules, nil
+}
+
+func (r *mongoScheduleRepository) AdvanceGeneratedThrough(ctx context.Context, scheduleID string, from time.Time, to time.Time) (bool, error) {
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"schedule_id": scheduleID, "generated_through": from},
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "generated_through", Value: to},
+			{Key: "updated_at", Value: time.Now()},
+		}}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+func (r *mongoScheduleRepository) End(ctx context.Context, scheduleID string, endDate time.Time) error {
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"schedule_id": scheduleID},
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "end_date", Value: endDate},
+			{Key: "updated_at", Value: time.Now()},
+		}}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoScheduleRepository) DeleteByExercise(ctx context.Context, exerciseID string) error {
+	_, err := r.collection.DeleteMany(ctx, bson.M{"exercise_id": exerciseID})
+	return err
+}
+
+func (r *mongoScheduleRepository) DeleteByPatient(ctx context.Context, patientID string) error {
+	_, err := r.collection.DeleteMany(ctx, bson.M{"patient_id": patientID})
+	return err
+}
+
+func (r *mongoScheduleRepository) DeleteByTherapist(ctx context.Context, therapistID string) error {
+	_, err := r.collection.DeleteMany(ctx, bson.M{"therapist_id": therapistID})
+	return err
+}
+
+func (r *mongoScheduleRepository) Reassign(ctx context.Context, patientID string, fromTherapistID string, toTherapistID string) error {
+	_, err := r.collection.UpdateMany(ctx,
+		bson.M{"patient_id": patientID, "therapist_id": fromTherapistID, "authored_by": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"authored_by": fromTherapistID}},
+	)
+	if err != nil {
+		return err
+	}
+	_, err = r.collection.UpdateMany(ctx,
+		bson.M{"patient_id": patientID, "therapist_id": fromTherapistID},
+		bson.M{"$set": bson.M{"therapist_id": toTherapistID, "updated_at": time.Now()}},
+	)
+	return err
+}
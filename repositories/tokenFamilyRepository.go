@@ -0,0 +1,92 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"golang-speakbackend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// TokenFamilyRepository stores login sessions and the refresh token each of
+// them currently accepts.
+type TokenFamilyRepository interface {
+	Insert(ctx context.Context, family *models.TokenFamily) error
+	FindByID(ctx context.Context, familyID string) (*models.TokenFamily, error)
+	// Rotate replaces the current refresh token, but only if it is still
+	// fromTokenID and the family is not revoked. Otherwise it returns
+	// ErrConflict.
+	Rotate(ctx context.Context, familyID string, fromTokenID string, toTokenID string, at time.Time) error
+	Revoke(ctx context.Context, familyID string, reason string, at time.Time) error
+	RevokeAllForUser(ctx context.Context, userID string, reason string, at time.Time) error
+	DeleteAllForUser(ctx context.Context, userID string) error
+}
+
+type mongoTokenFamilyRepository struct {
+	collection *mongo.Collection
+}
+
+func (r *mongoTokenFamilyRepository) Insert(ctx context.Context, family *models.TokenFamily) error {
+	_, err := r.collection.InsertOne(ctx, family)
+	return err
+}
+
+func (r *mongoTokenFamilyRepository) FindByID(ctx context.Context, familyID string) (*models.TokenFamily, error) {
+	var family models.TokenFamily
+	err := r.collection.FindOne(ctx, bson.M{"family_id": familyID}).Decode(&family)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &family, nil
+}
+
+func (r *mongoTokenFamilyRepository) Rotate(ctx context.Context, familyID string, fromTokenID string, toTokenID string, at time.Time) error {
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"family_id": familyID, "current_token_id": fromTokenID, "revoked_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"current_token_id": toTokenID, "rotated_at": at}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		if _, err := r.FindByID(ctx, familyID); err != nil {
+			return err
+		}
+		return ErrConflict
+	}
+	return nil
+}
+
+func (r *mongoTokenFamilyRepository) Revoke(ctx context.Context, familyID string, reason string, at time.Time) error {
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"family_id": familyID, "revoked_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revoked_at": at, "revoked_reason": reason}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		// Revoking twice is fine, revoking nothing is not
+		_, err := r.FindByID(ctx, familyID)
+		return err
+	}
+	return nil
+}
+
+func (r *mongoTokenFamilyRepository) RevokeAllForUser(ctx context.Context, userID string, reason string, at time.Time) error {
+	_, err := r.collection.UpdateMany(ctx,
+		bson.M{"user_id": userID, "revoked_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revoked_at": at, "revoked_reason": reason}},
+	)
+	return err
+}
+
+func (r *mongoTokenFamilyRepository) DeleteAllForUser(ctx context.Context, userID string) error {
+	_, err := r.collection.DeleteMany(ctx, bson.M{"user_id": userID})
+	return err
+}
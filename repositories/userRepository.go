@@ -0,0 +1,277 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"golang-speakbackend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// UserRepository stores users of every role.
+type UserRepository interface {
+	Insert(ctx context.Context, user *models.User) error
+	FindByID(ctx context.Context, userID string) (*models.User, error)
+	FindByEmail(ctx context.Context, email string) (*models.User, error)
+	FindByIDs(ctx context.Context, userIDs []string) ([]models.User, error)
+	FindTherapistByReferenceCode(ctx context.Context, referenceCode string) (*models.User, error)
+	EmailExists(ctx context.Context, email string) (bool, error)
+	ReferenceCodeExists(ctx context.Context, referenceCode string) (bool, error)
+	List(ctx context.Context, skip int64, limit int64) ([]models.User, int64, error)
+	// ListLinkedPatients returns the patients that still carry a reference
+	// code from before care teams existed.
+	ListLinkedPatients(ctx context.Context) ([]models.User, error)
+	Update(ctx context.Context, userID string, update UserUpdate) error
+	// UseMFAStep records step as the last accepted TOTP step. It returns
+	// ErrConflict if a code from that step or a later one was already used.
+	UseMFAStep(ctx context.Context, userID string, step int64) error
+	// ConsumeRecoveryCode removes the MFA recovery code with the given hash.
+	// It returns ErrConflict if the user has no such code.
+	ConsumeRecoveryCode(ctx context.Context, userID string, codeHash string) error
+	UnlinkPatients(ctx context.Context, referenceCode string) error
+	// MarkDeleted schedules the account for purging after purgeAfter. It
+	// returns ErrConflict if it already is.
+	MarkDeleted(ctx context.Context, userID string, deletedBy string, at time.Time, purgeAfter time.Time) error
+	// Restore undoes MarkDeleted. It returns ErrConflict if the account isn't
+	// deleted or is due to be purged at the given time.
+	Restore(ctx context.Context, userID string, now time.Time) error
+	// ListPurgeable returns the deleted accounts due to be purged at the
+	// given time.
+	ListPurgeable(ctx context.Context, now time.Time) ([]models.User, error)
+	Delete(ctx context.Context, userID string) error
+}
+
+// UserUpdate holds the fields to change on a user. Nil fields are left as is
+// and updated_at is always refreshed.
+type UserUpdate struct {
+	FirstName     *string
+	LastName      *string
+	Email         *string
+	PendingEmail  *string
+	Token         *string
+	RefreshToken  *string
+	ReferenceCode *string
+	ProfileImage  *string
+	// Password is the bcrypt hash. Setting it also records the change time.
+	Password      *string
+	AccountStatus *string
+	// MFA replaces the whole MFA enrolment.
+	MFA *models.MFASettings
+}
+
+type mongoUserRepository struct {
+	collection *mongo.Collection
+}
+
+func (r *mongoUserRepository) Insert(ctx context.Context, user *models.User) error {
+	_, err := r.collection.InsertOne(ctx, user)
+	return err
+}
+
+func (r *mongoUserRepository) findOne(ctx context.Context, filter bson.M) (*models.User, error) {
+	var user models.User
+	err := r.collection.FindOne(ctx, filter).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *mongoUserRepository) find(ctx context.Context, filter bson.M, opts ...*options.FindOptions) ([]models.User, error) {
+	cursor, err := r.collection.Find(ctx, filter, opts...)
+	if err != nil {
+		return nil, err
+	}
+	users := []models.User{}
+	if err = cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (r *mongoUserRepository) FindByID(ctx context.Context, userID string) (*models.User, error) {
+	return r.findOne(ctx, bson.M{"user_id": userID})
+}
+
+func (r *mongoUserRepository) FindByEmail(ctx context.Context, email string) (*models.User, error) {
+	return r.findOne(ctx, bson.M{"email": email})
+}
+
+func (r *mongoUserRepository) FindByIDs(ctx context.Context, userIDs []string) ([]models.User, error) {
+	return r.find(ctx, bson.M{"user_id": bson.M{"$in": userIDs}})
+}
+
+func (r *mongoUserRepository) FindTherapistByReferenceCode(ctx context.Context, referenceCode string) (*models.User, error) {
+	return r.findOne(ctx, bson.M{"reference_code": referenceCode, "role": "therapist"})
+}
+
+func (r *mongoUserRepository) EmailExists(ctx context.Context, email string) (bool, error) {
+	count, err := r.collection.CountDocuments(ctx, bson.M{"email": email})
+	return count > 0, err
+}
+
+func (r *mongoUserRepository) ReferenceCodeExists(ctx context.Context, referenceCode string) (bool, error) {
+	count, err := r.collection.CountDocuments(ctx, bson.M{"reference_code": referenceCode})
+	return count > 0, err
+}
+
+func (r *mongoUserRepository) List(ctx context.Context, skip int64, limit int64) ([]models.User, int64, error) {
+	total, err := r.collection.CountDocuments(ctx, bson.M{})
+	if err != nil {
+		return nil, 0, err
+	}
+	users, err := r.find(ctx, bson.M{}, options.Find().SetSkip(skip).SetLimit(limit))
+	if err != nil {
+		return nil, 0, err
+	}
+	return users, total, nil
+}
+
+func (r *mongoUserRepository) ListLinkedPatients(ctx context.Context) ([]models.User, error) {
+	return r.find(ctx, bson.M{"reference_code": bson.M{"$nin": bson.A{"", nil}}, "role": "patient"})
+}
+
+func (r *mongoUserRepository) Update(ctx context.Context, userID string, update UserUpdate) error {
+	set := bson.D{}
+	if update.FirstName != nil {
+		set = append(set, bson.E{Key: "first_name", Value: *update.FirstName})
+	}
+	if update.LastName != nil {
+		set = append(set, bson.E{Key: "last_name", Value: *update.LastName})
+	}
+	if update.Email != nil {
+		set = append(set, bson.E{Key: "email", Value: *update.Email})
+	}
+	if update.PendingEmail != nil {
+		set = append(set, bson.E{Key: "pending_email", Value: *update.PendingEmail})
+	}
+	if update.Token != nil {
+		set = append(set, bson.E{Key: "token", Value: *update.Token})
+	}
+	if update.RefreshToken != nil {
+		set = append(set, bson.E{Key: "refresh_token", Value: *update.RefreshToken})
+	}
+	if update.ReferenceCode != nil {
+		set = append(set, bson.E{Key: "reference_code", Value: *update.ReferenceCode})
+	}
+	if update.ProfileImage != nil {
+		set = append(set, bson.E{Key: "profile_image", Value: *update.ProfileImage})
+	}
+	if update.AccountStatus != nil {
+		set = append(set, bson.E{Key: "account_status", Value: *update.AccountStatus})
+	}
+	if update.MFA != nil {
+		set = append(set, bson.E{Key: "mfa", Value: *update.MFA})
+	}
+	if update.Password != nil {
+		set = append(set, bson.E{Key: "password", Value: *update.Password})
+		set = append(set, bson.E{Key: "password_changed_at", Value: time.Now().UTC()})
+	}
+	set = append(set, bson.E{Key: "updated_at", Value: time.Now()})
+
+	result, err := r.collection.UpdateOne(ctx, bson.M{"user_id": userID}, bson.D{{Key: "$set", Value: set}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoUserRepository) UseMFAStep(ctx context.Context, userID string, step int64) error {
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"user_id": userID, "$or": bson.A{
+			bson.M{"mfa.last_step": bson.M{"$lt": step}},
+			bson.M{"mfa.last_step": bson.M{"$exists": false}},
+		}},
+		bson.M{"$set": bson.M{"mfa.last_step": step}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		if _, err := r.FindByID(ctx, userID); err != nil {
+			return err
+		}
+		return ErrConflict
+	}
+	return nil
+}
+
+func (r *mongoUserRepository) ConsumeRecoveryCode(ctx context.Context, userID string, codeHash string) error {
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"user_id": userID, "mfa.recovery_codes": codeHash},
+		bson.M{"$pull": bson.M{"mfa.recovery_codes": codeHash}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		if _, err := r.FindByID(ctx, userID); err != nil {
+			return err
+		}
+		return ErrConflict
+	}
+	return nil
+}
+
+func (r *mongoUserRepository) UnlinkPatients(ctx context.Context, referenceCode string) error {
+	_, err := r.collection.UpdateMany(ctx,
+		bson.M{"reference_code": referenceCode, "role": "patient"},
+		bson.M{"$unset": bson.M{"reference_code": ""}},
+	)
+	return err
+}
+
+func (r *mongoUserRepository) MarkDeleted(ctx context.Context, userID string, deletedBy string, at time.Time, purgeAfter time.Time) error {
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"user_id": userID, "deleted_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"deleted_at": at, "deleted_by": deletedBy, "purge_after": purgeAfter, "updated_at": time.Now()}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		if _, err := r.FindByID(ctx, userID); err != nil {
+			return err
+		}
+		return ErrConflict
+	}
+	return nil
+}
+
+func (r *mongoUserRepository) Restore(ctx context.Context, userID string, now time.Time) error {
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"user_id": userID, "purge_after": bson.M{"$gt": now}},
+		bson.M{
+			"$unset": bson.M{"deleted_at": "", "deleted_by": "", "purge_after": ""},
+			"$set":   bson.M{"updated_at": time.Now()},
+		},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		if _, err := r.FindByID(ctx, userID); err != nil {
+			return err
+		}
+		return ErrConflict
+	}
+	return nil
+}
+
+func (r *mongoUserRepository) ListPurgeable(ctx context.Context, now time.Time) ([]models.User, error) {
+	return r.find(ctx, bson.M{"purge_after": bson.M{"$lte": now}})
+}
+
+func (r *mongoUserRepository) Delete(ctx context.Context, userID string) error {
+	_, err := r.collection.DeleteOne(ctx, bson.M{"user_id": userID})
+	return err
+}
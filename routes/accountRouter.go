@@ -0,0 +1,18 @@
+package routes
+
+import(
+	controller "golang-speakbackend/controllers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AccountRoutes are the session and MFA routes every logged in user can
+// reach, even a therapist who still has to set up MFA.
+func AccountRoutes(incomingRoutes *gin.RouterGroup, uc *controller.UserController, mc *controller.MFAController){
+	incomingRoutes.POST("/logout", uc.Logout())
+	incomingRoutes.POST("/logout/all", uc.LogoutAll())
+	incomingRoutes.POST("/mfa/enroll", mc.StartEnrollment())
+	incomingRoutes.POST("/mfa/enroll/confirm", mc.ConfirmEnrollment())
+	incomingRoutes.POST("/mfa/recovery-codes", mc.RegenerateRecoveryCodes())
+	incomingRoutes.POST("/mfa/disable", mc.Disable())
+}
@@ -0,0 +1,89 @@
+package routes_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"golang-speakbackend/helpers"
+	"golang-speakbackend/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestSignUp(t *testing.T) {
+	s := newTestServer(t)
+
+	s.expect(http.StatusBadRequest, "POST", "/signup", "", gin.H{
+		"first_name": "Test", "last_name": "Admin", "email": "admin@example.com", "password": "secret1", "role": helpers.RoleAdmin,
+	})
+
+	// Only the profile and credentials come from the client
+	s.expect(http.StatusOK, "POST", "/signup", "", gin.H{
+		"first_name": "Test", "last_name": "User", "email": "patient@example.com", "password": "secret1", "role": helpers.RolePatient,
+		"account_status": models.AccountActive, "mfa": gin.H{"enabled": true}, "reference_code": "ABCDEF",
+		"pending_email": "mallory@example.com", "deleted_at": time.Now(), "purge_after": time.Now(),
+	})
+	user, err := s.store.Users.FindByEmail(context.Background(), "patient@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.AccountStatus != models.AccountUnverified || user.MFA.Enabled || user.ReferenceCode != "" ||
+		user.PendingEmail != "" || user.DeletedAt != nil || user.PurgeAfter != nil {
+		t.Errorf("signup stored client-chosen fields: %+v", user)
+	}
+
+	s.expect(http.StatusOK, "POST", "/verify-email", "", gin.H{"token": s.mail.token(t, "patient@example.com")})
+	s.login("patient@example.com", "secret1")
+}
+
+func TestRefreshRotation(t *testing.T) {
+	s := newTestServer(t)
+	patient := s.signUp(helpers.RolePatient, "patient@example.com")
+	other := s.login(patient.Email, "secret1")
+
+	rotated := s.expect(http.StatusOK, "POST", "/refresh", "", gin.H{"refresh_token": patient.RefreshToken})
+	token, refreshToken := rotated["token"].(string), rotated["refresh_token"].(string)
+	if refreshToken == patient.RefreshToken {
+		t.Fatal("refresh returned the same refresh token")
+	}
+	s.expect(http.StatusOK, "GET", "/user/"+patient.ID, token, nil)
+
+	// Presenting the old refresh token again revokes the whole session
+	s.expect(http.StatusUnauthorized, "POST", "/refresh", "", gin.H{"refresh_token": patient.RefreshToken})
+	s.expect(http.StatusUnauthorized, "POST", "/refresh", "", gin.H{"refresh_token": refreshToken})
+	s.expect(http.StatusUnauthorized, "GET", "/user/"+patient.ID, token, nil)
+
+	// but not the user's other sessions
+	s.expect(http.StatusOK, "GET", "/user/"+patient.ID, other.Token, nil)
+	s.expect(http.StatusOK, "POST", "/refresh", "", gin.H{"refresh_token": other.RefreshToken})
+}
+
+func TestLogout(t *testing.T) {
+	s := newTestServer(t)
+	patient := s.signUp(helpers.RolePatient, "patient@example.com")
+	other := s.login(patient.Email, "secret1")
+
+	s.expect(http.StatusOK, "POST", "/logout", patient.Token, nil)
+	s.expect(http.StatusUnauthorized, "GET", "/user/"+patient.ID, patient.Token, nil)
+	s.expect(http.StatusUnauthorized, "POST", "/refresh", "", gin.H{"refresh_token": patient.RefreshToken})
+	s.expect(http.StatusOK, "GET", "/user/"+patient.ID, other.Token, nil)
+
+	s.expect(http.StatusOK, "POST", "/logout/all", other.Token, nil)
+	s.expect(http.StatusUnauthorized, "GET", "/user/"+patient.ID, other.Token, nil)
+}
+
+func TestTokenTypeConfusion(t *testing.T) {
+	s := newTestServer(t)
+	patient := s.signUp(helpers.RolePatient, "patient@example.com")
+
+	// A refresh token doesn't authenticate requests
+	s.expect(http.StatusUnauthorized, "GET", "/user/"+patient.ID, patient.RefreshToken, nil)
+	// and an access token can't be refreshed
+	s.expect(http.StatusUnauthorized, "POST", "/refresh", "", gin.H{"refresh_token": patient.Token})
+	// nor can it stand in for an MFA challenge
+	s.expect(http.StatusUnauthorized, "POST", "/mfa/verify", "", gin.H{"mfa_token": patient.Token, "code": "123456"})
+
+	s.expect(http.StatusOK, "GET", "/user/"+patient.ID, patient.Token, nil)
+}
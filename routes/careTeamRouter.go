@@ -0,0 +1,21 @@
+package routes
+
+import(
+	controller "golang-speakbackend/controllers"
+	"golang-speakbackend/helpers"
+	"golang-speakbackend/middleware"
+	"golang-speakbackend/repositories"
+
+	"github.com/gin-gonic/gin"
+)
+
+func CareTeamRoutes(incomingRoutes *gin.RouterGroup, cc *controller.CareTeamController, store *repositories.Store){
+	therapists := middleware.RequireRoles(helpers.RoleTherapist, helpers.RoleAdmin)
+
+	incomingRoutes.GET("/careteam/:patient_id", middleware.RequirePatientAccess(store, "patient_id"), cc.GetCareTeam())
+	incomingRoutes.POST("/careteam/:patient_id", therapists, cc.AddCareTeamMember())
+	incomingRoutes.DELETE("/careteam/:patient_id/:relationship_id", cc.EndCareTeamMember())
+	incomingRoutes.GET("/linkrequests/:therapist_id", therapists, middleware.RequireSelf("therapist_id"), cc.GetLinkRequests())
+	incomingRoutes.POST("/linkrequests/:relationship_id/accept", therapists, cc.AcceptLinkRequest())
+	incomingRoutes.POST("/linkrequests/:relationship_id/decline", therapists, cc.DeclineLinkRequest())
+}
@@ -0,0 +1,133 @@
+package routes_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"golang-speakbackend/helpers"
+	"golang-speakbackend/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCareTeamRoles(t *testing.T) {
+	s := newTestServer(t)
+	therapist := s.signUp(helpers.RoleTherapist, "therapist@example.com")
+	student := s.signUp(helpers.RoleTherapist, "student@example.com")
+	assistant := s.signUp(helpers.RoleTherapist, "assistant@example.com")
+	patient := s.signUp(helpers.RolePatient, "patient@example.com")
+	s.invite(therapist, patient)
+	patientExerciseID := s.assign(therapist, patient)
+
+	// Only team managers add members
+	s.expect(http.StatusForbidden, "POST", "/careteam/"+patient.ID, student.Token, gin.H{"therapist_id": student.ID, "role": models.CareRoleStudent})
+	s.expect(http.StatusOK, "POST", "/careteam/"+patient.ID, therapist.Token, gin.H{"therapist_id": student.ID, "role": models.CareRoleStudent})
+	s.expect(http.StatusOK, "POST", "/careteam/"+patient.ID, therapist.Token, gin.H{"therapist_id": assistant.ID, "role": models.CareRoleAssistant})
+
+	// Students only look
+	s.expect(http.StatusOK, "GET", "/patientexercise/"+patientExerciseID, student.Token, nil)
+	s.expect(http.StatusForbidden, "PUT", "/patientexercise/"+patientExerciseID, student.Token, gin.H{"status": models.StatusArchived})
+	s.expect(http.StatusForbidden, "POST", "/patientexercise/"+patientExerciseID+"/feedback", student.Token, gin.H{"decision": models.StatusReviewed})
+	s.expect(http.StatusForbidden, "DELETE", "/patientexercise/"+patientExerciseID, student.Token, nil)
+	s.expect(http.StatusForbidden, "GET", "/getdownloadurl/"+patientExerciseID, student.Token, nil)
+
+	// Assistants can change the patient's exercises
+	s.expect(http.StatusOK, "PUT", "/patientexercise/"+patientExerciseID, assistant.Token, gin.H{"status": models.StatusArchived})
+}
+
+func TestCareTeamReviewsAndSchedules(t *testing.T) {
+	s := newTestServer(t)
+	therapist := s.signUp(helpers.RoleTherapist, "therapist@example.com")
+	supervisor := s.signUp(helpers.RoleTherapist, "supervisor@example.com")
+	student := s.signUp(helpers.RoleTherapist, "student@example.com")
+	patient := s.signUp(helpers.RolePatient, "patient@example.com")
+	s.invite(therapist, patient)
+	s.expect(http.StatusOK, "POST", "/careteam/"+patient.ID, therapist.Token, gin.H{"therapist_id": supervisor.ID, "role": models.CareRoleSupervisor})
+	s.expect(http.StatusOK, "POST", "/careteam/"+patient.ID, therapist.Token, gin.H{"therapist_id": student.ID, "role": models.CareRoleStudent})
+
+	// Anyone on the team who may assign reviews, not just who assigned it
+	patientExerciseID := s.assign(therapist, patient)
+	s.expect(http.StatusOK, "PUT", "/patientexercise/"+patientExerciseID, patient.Token, gin.H{"status": models.StatusInProgress})
+	if code := s.upload(patient, patientExerciseID, "take.mp4"); code != http.StatusOK {
+		t.Fatalf("upload: got %d", code)
+	}
+	feedback := s.expect(http.StatusOK, "POST", "/patientexercise/"+patientExerciseID+"/feedback", supervisor.Token, gin.H{"decision": models.StatusReviewed})
+	if feedback["therapist_id"] != supervisor.ID {
+		t.Errorf("feedback by %v, want %s", feedback["therapist_id"], supervisor.ID)
+	}
+
+	// and ends schedules
+	exercise := s.expect(http.StatusOK, "POST", "/exercise", therapist.Token, gin.H{"name": "Lip trills", "description": "d"})
+	schedule := s.expect(http.StatusOK, "POST", "/schedule", therapist.Token, gin.H{
+		"patient_id": patient.ID, "therapist_id": therapist.ID, "exercise_id": exercise["InsertedID"],
+		"start_date": time.Now(), "end_date": time.Now().AddDate(0, 1, 0), "frequency": helpers.FrequencyDaily, "repetitions_per_session": 1,
+	})
+	path := "/schedule/" + schedule["schedule_id"].(string)
+	s.expect(http.StatusForbidden, "DELETE", path, student.Token, nil)
+	s.expect(http.StatusOK, "DELETE", path, supervisor.Token, nil)
+}
+
+func TestLinkRequests(t *testing.T) {
+	s := newTestServer(t)
+	therapist := s.signUp(helpers.RoleTherapist, "therapist@example.com")
+	patient := s.signUp(helpers.RolePatient, "patient@example.com")
+	declined := s.signUp(helpers.RolePatient, "declined@example.com")
+
+	// A reference code only asks the therapist
+	request := s.expect(http.StatusOK, "POST", "/user/linkToTherapist/"+patient.ID, patient.Token, gin.H{"reference_code": therapist.ReferenceCode})
+	if request["status"] != models.CareStatusPending {
+		t.Fatalf("link request status = %v, want %s", request["status"], models.CareStatusPending)
+	}
+	s.expect(http.StatusForbidden, "GET", "/user/"+patient.ID, therapist.Token, nil)
+
+	var requests []map[string]any
+	if code := s.do("GET", "/linkrequests/"+therapist.ID, therapist.Token, nil, &requests); code != http.StatusOK || len(requests) != 1 {
+		t.Fatalf("link requests: got %d %v", code, requests)
+	}
+
+	// Only the therapist asked can answer
+	other := s.signUp(helpers.RoleTherapist, "other@example.com")
+	s.expect(http.StatusForbidden, "POST", "/linkrequests/"+request["relationship_id"].(string)+"/accept", other.Token, nil)
+
+	s.expect(http.StatusOK, "POST", "/linkrequests/"+request["relationship_id"].(string)+"/accept", therapist.Token, nil)
+	s.expect(http.StatusOK, "GET", "/user/"+patient.ID, therapist.Token, nil)
+	s.expect(http.StatusConflict, "POST", "/linkrequests/"+request["relationship_id"].(string)+"/accept", therapist.Token, nil)
+
+	// A declined request grants nothing
+	request = s.expect(http.StatusOK, "POST", "/user/linkToTherapist/"+declined.ID, declined.Token, gin.H{"reference_code": therapist.ReferenceCode})
+	s.expect(http.StatusOK, "POST", "/linkrequests/"+request["relationship_id"].(string)+"/decline", therapist.Token, nil)
+	s.expect(http.StatusForbidden, "GET", "/user/"+declined.ID, therapist.Token, nil)
+}
+
+func TestInviteKeepsPrimaryTherapist(t *testing.T) {
+	s := newTestServer(t)
+	therapist := s.signUp(helpers.RoleTherapist, "therapist@example.com")
+	other := s.signUp(helpers.RoleTherapist, "other@example.com")
+	patient := s.signUp(helpers.RolePatient, "patient@example.com")
+	s.invite(therapist, patient)
+
+	// Another therapist's code doesn't take the patient away
+	invite := s.expect(http.StatusOK, "POST", "/invites", other.Token, gin.H{})
+	redeemed := s.expect(http.StatusOK, "POST", "/invites/redeem", patient.Token, gin.H{"code": invite["code"]})
+	if redeemed["role"] != models.CareRoleAssistant {
+		t.Errorf("redeemed as %v, want %s", redeemed["role"], models.CareRoleAssistant)
+	}
+	s.expect(http.StatusOK, "GET", "/user/"+patient.ID, other.Token, nil)
+	s.expect(http.StatusForbidden, "POST", "/careteam/"+patient.ID, other.Token, gin.H{"therapist_id": other.ID, "role": models.CareRolePrimary})
+
+	// The primary therapist keeps the patient
+	var team []models.CareRelationship
+	if code := s.do("GET", "/careteam/"+patient.ID, patient.Token, nil, &team); code != http.StatusOK {
+		t.Fatalf("care team: got %d", code)
+	}
+	roles := map[string]string{}
+	for _, member := range team {
+		if member.Status == models.CareStatusActive && member.EndDate == nil {
+			roles[member.TherapistID] = member.Role
+		}
+	}
+	if roles[therapist.ID] != models.CareRolePrimary || roles[other.ID] != models.CareRoleAssistant {
+		t.Errorf("care team roles = %v", roles)
+	}
+}
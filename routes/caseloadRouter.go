@@ -0,0 +1,17 @@
+package routes
+
+import(
+	controller "golang-speakbackend/controllers"
+	"golang-speakbackend/helpers"
+	"golang-speakbackend/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func CaseloadRoutes(incomingRoutes *gin.RouterGroup, cc *controller.CaseloadController){
+	therapists := middleware.RequireRoles(helpers.RoleTherapist, helpers.RoleAdmin)
+
+	incomingRoutes.POST("/caseload/transfer", therapists, cc.TransferCaseload())
+	incomingRoutes.GET("/caseload/transfer/:transfer_id", therapists, cc.GetTransfer())
+	incomingRoutes.GET("/caseload/transfers/:therapist_id", therapists, middleware.RequireSelf("therapist_id"), cc.GetTransfers())
+}
@@ -0,0 +1,64 @@
+package routes_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"golang-speakbackend/helpers"
+	"golang-speakbackend/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCaseloadTransfer(t *testing.T) {
+	s := newTestServer(t)
+	from := s.signUp(helpers.RoleTherapist, "from@example.com")
+	to := s.signUp(helpers.RoleTherapist, "to@example.com")
+	student := s.signUp(helpers.RoleTherapist, "student@example.com")
+	patient := s.signUp(helpers.RolePatient, "patient@example.com")
+	s.invite(from, patient)
+	patientExerciseID := s.assign(from, patient)
+	s.expect(http.StatusOK, "POST", "/careteam/"+patient.ID, from.Token, gin.H{"therapist_id": student.ID, "role": models.CareRoleStudent})
+
+	// Only team managers and admins hand patients over
+	s.expect(http.StatusForbidden, "POST", "/caseload/transfer", student.Token, gin.H{
+		"from_therapist_id": student.ID, "to_therapist_id": to.ID, "patient_ids": []string{patient.ID},
+	})
+	s.expect(http.StatusForbidden, "POST", "/caseload/transfer", to.Token, gin.H{
+		"from_therapist_id": from.ID, "to_therapist_id": to.ID,
+	})
+	s.expect(http.StatusBadRequest, "POST", "/caseload/transfer", from.Token, gin.H{
+		"from_therapist_id": from.ID, "to_therapist_id": to.ID, "patient_ids": []string{to.ID},
+	})
+
+	var transfer models.CaseloadTransfer
+	code := s.do("POST", "/caseload/transfer", from.Token, gin.H{"from_therapist_id": from.ID, "to_therapist_id": to.ID}, &transfer)
+	if code != http.StatusOK {
+		t.Fatalf("transfer: got %d", code)
+	}
+	if len(transfer.Patients) != 1 || transfer.Patients[0].PatientID != patient.ID || transfer.Patients[0].OpenAssignments != 1 {
+		t.Fatalf("transfer report = %+v", transfer.Patients)
+	}
+	if transfer.Patients[0].Role != models.CareRolePrimary {
+		t.Errorf("transferred role = %s, want %s", transfer.Patients[0].Role, models.CareRolePrimary)
+	}
+
+	patientExercise, err := s.store.PatientExercises.FindByID(context.Background(), patientExerciseID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *patientExercise.TherapistID != to.ID || patientExercise.AuthoredBy != from.ID {
+		t.Errorf("patient exercise therapist = %s, authored by %s", *patientExercise.TherapistID, patientExercise.AuthoredBy)
+	}
+
+	s.expect(http.StatusForbidden, "GET", "/user/"+patient.ID, from.Token, nil)
+	s.expect(http.StatusOK, "GET", "/user/"+patient.ID, to.Token, nil)
+	s.expect(http.StatusOK, "PUT", "/patientexercise/"+patientExerciseID, to.Token, gin.H{"status": models.StatusArchived})
+
+	// The student stays on the team and the report stays readable for both
+	s.expect(http.StatusOK, "GET", "/user/"+patient.ID, student.Token, nil)
+	s.expect(http.StatusOK, "GET", "/caseload/transfer/"+transfer.TransferID, from.Token, nil)
+	s.expect(http.StatusOK, "GET", "/caseload/transfer/"+transfer.TransferID, to.Token, nil)
+	s.expect(http.StatusForbidden, "GET", "/caseload/transfer/"+transfer.TransferID, student.Token, nil)
+}
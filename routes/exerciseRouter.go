@@ -2,14 +2,18 @@ package routes
 
 import(
 	controller "golang-speakbackend/controllers"
+	"golang-speakbackend/helpers"
+	"golang-speakbackend/middleware"
 	
 	"github.com/gin-gonic/gin"
 )
 
-func ExerciseRoutes(incomingRoutes *gin.RouterGroup){
-	incomingRoutes.POST("/exercise", controller.CreateExercise())
-	incomingRoutes.GET("/exercise/:exercise_id", controller.GetExercise())
-	incomingRoutes.GET("/exercises", controller.GetExercises())
-	incomingRoutes.PUT("/exercise/:exercise_id", controller.UpdateExercise())
-	incomingRoutes.DELETE("/exercise/:exercise_id", controller.DeleteExercise())
-}
\ No newline at end of file
+func ExerciseRoutes(incomingRoutes *gin.RouterGroup, ec *controller.ExerciseController){
+	authors := middleware.RequireRoles(helpers.RoleTherapist, helpers.RoleAdmin)
+
+	incomingRoutes.POST("/exercise", authors, ec.CreateExercise())
+	incomingRoutes.GET("/exercise/:exercise_id", ec.GetExercise())
+	incomingRoutes.GET("/exercises", ec.GetExercises())
+	incomingRoutes.PUT("/exercise/:exercise_id", authors, ec.UpdateExercise())
+	incomingRoutes.DELETE("/exercise/:exercise_id", authors, ec.DeleteExercise())
+}
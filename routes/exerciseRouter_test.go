@@ -0,0 +1,38 @@
+package routes_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"golang-speakbackend/helpers"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestExerciseAuthors(t *testing.T) {
+	s := newTestServer(t)
+	author := s.signUp(helpers.RoleTherapist, "author@example.com")
+	other := s.signUp(helpers.RoleTherapist, "other@example.com")
+	admin := s.admin()
+
+	// Exercises start out in the catalogue
+	created := s.expect(http.StatusOK, "POST", "/exercise", author.Token, gin.H{"name": "Lip trills", "description": "d", "archived_at": time.Now()})
+	path := "/exercise/" + created["InsertedID"].(string)
+	exercise := s.expect(http.StatusOK, "GET", path, other.Token, nil)
+	if exercise["archived_at"] != nil || exercise["created_by"] != author.ID {
+		t.Fatalf("created exercise = %v", exercise)
+	}
+
+	// Only the author and admins change or remove it
+	s.expect(http.StatusForbidden, "PUT", path, other.Token, gin.H{"name": "Lip rolls"})
+	s.expect(http.StatusForbidden, "DELETE", path, other.Token, nil)
+	s.expect(http.StatusOK, "PUT", path, author.Token, gin.H{"name": "Lip rolls"})
+	s.expect(http.StatusOK, "PUT", path, admin.Token, gin.H{"description": "Roll for a minute"})
+	exercise = s.expect(http.StatusOK, "GET", path, other.Token, nil)
+	if exercise["name"] != "Lip rolls" || exercise["description"] != "Roll for a minute" {
+		t.Errorf("updated exercise = %v", exercise)
+	}
+	s.expect(http.StatusOK, "DELETE", path, author.Token, nil)
+	s.expect(http.StatusNotFound, "PUT", path, author.Token, gin.H{"name": "Lip trills"})
+}
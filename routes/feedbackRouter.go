@@ -0,0 +1,17 @@
+package routes
+
+import(
+	controller "golang-speakbackend/controllers"
+	"golang-speakbackend/helpers"
+	"golang-speakbackend/middleware"
+	"golang-speakbackend/repositories"
+
+	"github.com/gin-gonic/gin"
+)
+
+func FeedbackRoutes(incomingRoutes *gin.RouterGroup, fc *controller.FeedbackController, store *repositories.Store){
+	therapists := middleware.RequireRoles(helpers.RoleTherapist, helpers.RoleAdmin)
+
+	incomingRoutes.POST("/patientexercise/:id/feedback", therapists, middleware.RequirePatientExerciseCapability(store, "id", helpers.CareCapabilityAssign), fc.CreateFeedback())
+	incomingRoutes.GET("/patientexercise/:id/feedback", middleware.RequirePatientExerciseAccess(store, "id"), fc.GetFeedback())
+}
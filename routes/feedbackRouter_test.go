@@ -0,0 +1,43 @@
+package routes_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"golang-speakbackend/helpers"
+	"golang-speakbackend/models"
+	"golang-speakbackend/repositories"
+
+	"github.com/gin-gonic/gin"
+)
+
+// racingPatientExercises has every status change lose to a concurrent one.
+type racingPatientExercises struct {
+	repositories.PatientExerciseRepository
+}
+
+func (r racingPatientExercises) Transition(ctx context.Context, patientExerciseID string, fromStatus string, transition models.StatusTransition, update repositories.PatientExerciseUpdate) error {
+	return repositories.ErrConflict
+}
+
+func TestFeedbackLosingRace(t *testing.T) {
+	s := newTestServer(t)
+	therapist := s.signUp(helpers.RoleTherapist, "therapist@example.com")
+	patient := s.signUp(helpers.RolePatient, "patient@example.com")
+	s.invite(therapist, patient)
+	patientExerciseID := s.assign(therapist, patient)
+	path := "/patientexercise/" + patientExerciseID
+	s.expect(http.StatusOK, "PUT", path, patient.Token, gin.H{"status": models.StatusInProgress})
+	if code := s.upload(patient, patientExerciseID, "take.mp4"); code != http.StatusOK {
+		t.Fatalf("upload: got %d", code)
+	}
+
+	// A review of an exercise whose status changed meanwhile isn't kept
+	s.store.PatientExercises = racingPatientExercises{s.store.PatientExercises}
+	s.expect(http.StatusConflict, "POST", path+"/feedback", therapist.Token, gin.H{"decision": models.StatusReviewed})
+	var feedback []models.Feedback
+	if code := s.do("GET", path+"/feedback", therapist.Token, nil, &feedback); code != http.StatusOK || len(feedback) != 0 {
+		t.Errorf("feedback: got %d with %d reviews, want none", code, len(feedback))
+	}
+}
@@ -0,0 +1,18 @@
+package routes
+
+import(
+	controller "golang-speakbackend/controllers"
+	"golang-speakbackend/helpers"
+	"golang-speakbackend/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func InviteRoutes(incomingRoutes *gin.RouterGroup, ic *controller.InviteController){
+	therapists := middleware.RequireRoles(helpers.RoleTherapist)
+
+	incomingRoutes.POST("/invites", therapists, ic.CreateInvite())
+	incomingRoutes.GET("/invites", therapists, ic.GetInvites())
+	incomingRoutes.DELETE("/invites/:invite_id", middleware.RequireRoles(helpers.RoleTherapist, helpers.RoleAdmin), ic.RevokeInvite())
+	incomingRoutes.POST("/invites/redeem", middleware.RequireRoles(helpers.RolePatient), ic.RedeemInvite())
+}
@@ -0,0 +1,65 @@
+package routes_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"golang-speakbackend/helpers"
+	"golang-speakbackend/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestInviteRedemption(t *testing.T) {
+	s := newTestServer(t)
+	therapist := s.signUp(helpers.RoleTherapist, "therapist@example.com")
+	patient := s.signUp(helpers.RolePatient, "patient@example.com")
+	second := s.signUp(helpers.RolePatient, "second@example.com")
+	exercise := s.expect(http.StatusOK, "POST", "/exercise", therapist.Token, gin.H{"name": "Lip trills", "description": "d"})
+
+	invite := s.expect(http.StatusOK, "POST", "/invites", therapist.Token, gin.H{"exercise_ids": []any{exercise["InsertedID"]}})
+	code := invite["code"].(string)
+
+	// Patients only
+	s.expect(http.StatusForbidden, "POST", "/invites/redeem", therapist.Token, gin.H{"code": code})
+
+	// Codes are not case sensitive and dashes are optional
+	redeemed := s.expect(http.StatusOK, "POST", "/invites/redeem", patient.Token, gin.H{"code": strings.ToLower(strings.ReplaceAll(code, "-", ""))})
+	if redeemed["status"] != models.CareStatusActive || redeemed["therapist_id"] != therapist.ID {
+		t.Fatalf("redeemed = %v", redeemed)
+	}
+	if ids := redeemed["patient_exercise_ids"].([]any); len(ids) != 1 {
+		t.Fatalf("assigned %d exercises on redemption, want 1", len(ids))
+	}
+	s.expect(http.StatusOK, "GET", "/user/"+patient.ID, therapist.Token, nil)
+
+	// Invites are single-use by default
+	s.expect(http.StatusBadRequest, "POST", "/invites/redeem", second.Token, gin.H{"code": code})
+	s.expect(http.StatusForbidden, "GET", "/user/"+second.ID, therapist.Token, nil)
+
+	// An invite made out to an email only works for that account
+	invite = s.expect(http.StatusOK, "POST", "/invites", therapist.Token, gin.H{"email": "someone@example.com"})
+	s.expect(http.StatusForbidden, "POST", "/invites/redeem", second.Token, gin.H{"code": invite["code"]})
+	// once it has verified it
+	s.expect(http.StatusOK, "POST", "/signup", "", gin.H{
+		"first_name": "Test", "last_name": "User", "email": "someone@example.com", "password": "secret1", "role": helpers.RolePatient,
+	})
+	invited := s.login("someone@example.com", "secret1")
+	s.expect(http.StatusForbidden, "POST", "/invites/redeem", invited.Token, gin.H{"code": invite["code"]})
+	s.expect(http.StatusForbidden, "GET", "/user/"+invited.ID, therapist.Token, nil)
+	s.expect(http.StatusOK, "POST", "/verify-email", "", gin.H{"token": s.mail.token(t, "someone@example.com")})
+	s.expect(http.StatusOK, "POST", "/invites/redeem", invited.Token, gin.H{"code": invite["code"]})
+
+	// Revoked invites get the same answer as unknown ones
+	invite = s.expect(http.StatusOK, "POST", "/invites", therapist.Token, gin.H{"max_uses": 5})
+	s.expect(http.StatusOK, "DELETE", "/invites/"+invite["invite"].(map[string]any)["invite_id"].(string), therapist.Token, nil)
+	s.expect(http.StatusBadRequest, "POST", "/invites/redeem", second.Token, gin.H{"code": invite["code"]})
+	s.expect(http.StatusBadRequest, "POST", "/invites/redeem", second.Token, gin.H{"code": "ZZZZZ-ZZZZZ"})
+
+	// Expiries can be chosen up to INVITE_MAX_TTL away
+	s.expect(http.StatusBadRequest, "POST", "/invites", therapist.Token, gin.H{"expires_at": time.Now().Add(s.config.Account.InviteMaxTTL.Duration + time.Hour)})
+	invite = s.expect(http.StatusOK, "POST", "/invites", therapist.Token, gin.H{"expires_at": time.Now().Add(time.Hour)})
+	s.expect(http.StatusOK, "POST", "/invites/redeem", second.Token, gin.H{"code": invite["code"]})
+}
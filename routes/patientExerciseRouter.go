@@ -2,19 +2,30 @@ package routes
 
 import(
 	controller "golang-speakbackend/controllers"
+	"golang-speakbackend/helpers"
+	"golang-speakbackend/middleware"
+	"golang-speakbackend/repositories"
 
 	"github.com/gin-gonic/gin"
 )
 
-func PatientExerciseRoutes(incomingRoutes *gin.RouterGroup){
-	incomingRoutes.GET("/patientexercise/:id", controller.GetPatientExercise())
+func PatientExerciseRoutes(incomingRoutes *gin.RouterGroup, pc *controller.PatientExerciseController, store *repositories.Store){
+	therapists := middleware.RequireRoles(helpers.RoleTherapist, helpers.RoleAdmin)
+	patients := middleware.RequireRoles(helpers.RolePatient, helpers.RoleAdmin)
+	assigners := middleware.RequirePatientExerciseCapability(store, "id", helpers.CareCapabilityAssign)
+
+	incomingRoutes.GET("/patientexercise/:id", middleware.RequirePatientExerciseAccess(store, "id"), pc.GetPatientExercise())
 	// incomingRoutes.GET("/patientexercises", controller.GetPatientExercises())
-	incomingRoutes.POST("/patientexercise", controller.CreatePatientExercise())
-	incomingRoutes.PUT("/patientexercise/:id", controller.UpdatePatientExercise())
-	incomingRoutes.DELETE("/patientexercise/:id", controller.DeletePatientExercise())
-	incomingRoutes.POST("/patientexercise/uploadrecording/:patient_exercise_id", controller.UploadRecording())
-	incomingRoutes.GET("/patientexercises/:patient_id", controller.GetPatientExercisesByUser())
-	incomingRoutes.POST("/getuploadurl/:patient_exercise_id", controller.RecordingPresignPost())
-	incomingRoutes.GET("/getdownloadurl/:patient_exercise_id", controller.GetRecordingPresignURL())
+	incomingRoutes.POST("/patientexercise", therapists, pc.CreatePatientExercise())
+	incomingRoutes.PUT("/patientexercise/:id", assigners, pc.UpdatePatientExercise())
+	incomingRoutes.DELETE("/patientexercise/:id", therapists, assigners, pc.DeletePatientExercise())
+	incomingRoutes.POST("/patientexercise/uploadrecording/:patient_exercise_id", patients, middleware.RequirePatientExerciseAccess(store, "patient_exercise_id"), pc.UploadRecording())
+	incomingRoutes.GET("/patientexercises/:patient_id", middleware.RequirePatientAccess(store, "patient_id"), pc.GetPatientExercisesByUser())
+	incomingRoutes.POST("/getuploadurl/:patient_exercise_id", patients, middleware.RequireRecordingKeyAccess(store, "patient_exercise_id"), pc.RecordingPresignPost())
+	incomingRoutes.POST("/confirmupload/:patient_exercise_id", patients, middleware.RequirePatientExerciseAccess(store, "patient_exercise_id"), pc.ConfirmRecordingUpload())
+	incomingRoutes.GET("/getdownloadurl/:patient_exercise_id", middleware.RequireRecordingKeyAccess(store, "patient_exercise_id"), pc.GetRecordingPresignURL())
+	incomingRoutes.GET("/patientexercise/:id/attempts", middleware.RequirePatientExerciseAccess(store, "id"), pc.ListRecordingAttempts())
+	incomingRoutes.GET("/patientexercise/:id/attempts/:attempt_id/downloadurl", middleware.RequireRecordingKeyAccess(store, "id"), pc.GetRecordingAttemptURL())
+	incomingRoutes.GET("/keyaccesslog/:patient_id", therapists, middleware.RequirePatientAccess(store, "patient_id"), pc.GetKeyAccessLog())
 	
-}
\ No newline at end of file
+}
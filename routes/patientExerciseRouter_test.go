@@ -0,0 +1,114 @@
+package routes_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"golang-speakbackend/helpers"
+	"golang-speakbackend/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestPatientExerciseLifecycle(t *testing.T) {
+	s := newTestServer(t)
+	therapist := s.signUp(helpers.RoleTherapist, "therapist@example.com")
+	patient := s.signUp(helpers.RolePatient, "patient@example.com")
+	s.invite(therapist, patient)
+	patientExerciseID := s.assign(therapist, patient)
+	path := "/patientexercise/" + patientExerciseID
+
+	// Each side only makes its own moves
+	s.expect(http.StatusConflict, "PUT", path, therapist.Token, gin.H{"status": models.StatusInProgress})
+	s.expect(http.StatusOK, "PUT", path, patient.Token, gin.H{"status": models.StatusInProgress})
+	s.expect(http.StatusConflict, "PUT", path, therapist.Token, gin.H{"status": models.StatusReviewed})
+	s.expect(http.StatusConflict, "POST", path+"/feedback", therapist.Token, gin.H{"decision": models.StatusReviewed})
+
+	if code := s.upload(patient, patientExerciseID, "take.mp4"); code != http.StatusOK {
+		t.Fatalf("upload: got %d, want %d", code, http.StatusOK)
+	}
+	if !bucket.has("recordings/" + patientExerciseID + "/") {
+		t.Fatal("recording was not stored")
+	}
+
+	s.expect(http.StatusBadRequest, "POST", path+"/feedback", therapist.Token, gin.H{"decision": models.StatusArchived})
+	s.expect(http.StatusOK, "POST", path+"/feedback", therapist.Token, gin.H{"decision": models.StatusNeedsRedo, "comments": "Slower please"})
+	s.expect(http.StatusOK, "PUT", path, patient.Token, gin.H{"status": models.StatusInProgress})
+	if code := s.upload(patient, patientExerciseID, "take.mp4"); code != http.StatusOK {
+		t.Fatalf("second upload: got %d, want %d", code, http.StatusOK)
+	}
+	s.expect(http.StatusOK, "POST", path+"/feedback", therapist.Token, gin.H{"decision": models.StatusReviewed})
+	s.expect(http.StatusConflict, "PUT", path, patient.Token, gin.H{"status": models.StatusArchived})
+	s.expect(http.StatusOK, "PUT", path, therapist.Token, gin.H{"status": models.StatusArchived})
+	s.expect(http.StatusConflict, "PUT", path, therapist.Token, gin.H{"status": models.StatusReviewed})
+
+	patientExercise, err := s.store.PatientExercises.FindByID(context.Background(), patientExerciseID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		models.StatusInProgress, models.StatusSubmitted, models.StatusNeedsRedo,
+		models.StatusInProgress, models.StatusSubmitted, models.StatusReviewed, models.StatusArchived,
+	}
+	if len(patientExercise.StatusHistory) != len(want) {
+		t.Fatalf("status history has %d transitions, want %d", len(patientExercise.StatusHistory), len(want))
+	}
+	for i, transition := range patientExercise.StatusHistory {
+		if transition.To != want[i] {
+			t.Errorf("transition %d went to %s, want %s", i, transition.To, want[i])
+		}
+	}
+}
+
+func TestSubmitOnlyByUpload(t *testing.T) {
+	s := newTestServer(t)
+	therapist := s.signUp(helpers.RoleTherapist, "therapist@example.com")
+	patient := s.signUp(helpers.RolePatient, "patient@example.com")
+	s.invite(therapist, patient)
+	patientExerciseID := s.assign(therapist, patient)
+	path := "/patientexercise/" + patientExerciseID
+
+	s.expect(http.StatusOK, "PUT", path, patient.Token, gin.H{"status": models.StatusInProgress})
+	s.expect(http.StatusConflict, "PUT", path, patient.Token, gin.H{"status": models.StatusSubmitted})
+	s.expect(http.StatusConflict, "PUT", path, patient.Token, gin.H{"status": models.StatusUploadPending})
+	s.expect(http.StatusBadRequest, "PUT", path, patient.Token, gin.H{"recording": "https://example.com/take.mp4"})
+	s.expect(http.StatusBadRequest, "PUT", path, patient.Token, gin.H{})
+
+	if code := s.upload(patient, patientExerciseID, "take.mp4"); code != http.StatusOK {
+		t.Fatalf("upload: got %d, want %d", code, http.StatusOK)
+	}
+	patientExercise := s.expect(http.StatusOK, "GET", path, patient.Token, nil)
+	if patientExercise["status"] != models.StatusSubmitted {
+		t.Errorf("status after upload = %v, want %s", patientExercise["status"], models.StatusSubmitted)
+	}
+}
+
+func TestUploadRecording(t *testing.T) {
+	s := newTestServer(t)
+	therapist := s.signUp(helpers.RoleTherapist, "therapist@example.com")
+	patient := s.signUp(helpers.RolePatient, "patient@example.com")
+	s.invite(therapist, patient)
+	patientExerciseID := s.assign(therapist, patient)
+	s.expect(http.StatusOK, "PUT", "/patientexercise/"+patientExerciseID, patient.Token, gin.H{"status": models.StatusInProgress})
+
+	if code := s.upload(patient, patientExerciseID, "take"); code != http.StatusBadRequest {
+		t.Fatalf("upload without an extension: got %d, want %d", code, http.StatusBadRequest)
+	}
+	if code := s.upload(patient, patientExerciseID, "take.exe"); code != http.StatusBadRequest {
+		t.Fatalf("upload of an exe: got %d, want %d", code, http.StatusBadRequest)
+	}
+
+	// A direct upload replaces a presigned one still pending
+	presigned := s.expect(http.StatusOK, "POST", "/getuploadurl/"+patientExerciseID, patient.Token, nil)
+	if code := s.upload(patient, patientExerciseID, "take.mp4"); code != http.StatusOK {
+		t.Fatalf("upload: got %d, want %d", code, http.StatusOK)
+	}
+	attempt, err := s.store.Attempts.FindByID(context.Background(), presigned["attempt_id"].(string))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempt.Status != models.AttemptExpired {
+		t.Errorf("presigned attempt is %s, want %s", attempt.Status, models.AttemptExpired)
+	}
+}
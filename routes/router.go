@@ -0,0 +1,105 @@
+package routes
+
+import (
+	"golang-speakbackend/config"
+	controller "golang-speakbackend/controllers"
+	"golang-speakbackend/helpers"
+	"golang-speakbackend/middleware"
+	"golang-speakbackend/repositories"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+)
+
+// Dependencies are the services the controllers are built from.
+type Dependencies struct {
+	Config  config.Config
+	Store   *repositories.Store
+	Storage *helpers.Storage
+	Keys    helpers.KeyWrapper
+	Tokens  *helpers.TokenMaker
+	Mailer  helpers.Mailer
+}
+
+// NewRouter wires every controller to the given dependencies. Passing
+// repositories.NewMemoryStore() as the store gives a router that can be
+// driven with httptest without a database.
+func NewRouter(deps Dependencies) (*gin.Engine, error) {
+	store := deps.Store
+	loginGuard := helpers.NewLoginGuard(store, deps.Config.Account)
+	userController := controller.NewUserController(store, deps.Tokens, deps.Storage, deps.Mailer, deps.Config.Account, loginGuard)
+	exerciseController := controller.NewExerciseController(store, deps.Storage)
+	patientExerciseController := controller.NewPatientExerciseController(store, deps.Storage, deps.Keys)
+	scheduleController := controller.NewScheduleController(store, deps.Config.Schedule)
+	feedbackController := controller.NewFeedbackController(store)
+	careTeamController := controller.NewCareTeamController(store)
+	inviteController := controller.NewInviteController(store, deps.Mailer, deps.Config.Account)
+	caseloadController := controller.NewCaseloadController(store)
+	mfaController := controller.NewMFAController(store, deps.Tokens, deps.Keys, deps.Config.Auth, loginGuard)
+
+	router := gin.New()
+	router.Use(gin.Logger())
+
+	// The client IP keys the login throttle, so X-Forwarded-For is only
+	// believed when it comes from one of our own proxies
+	trustedProxies := deps.Config.TrustedProxies
+	if len(trustedProxies) == 0 {
+		trustedProxies = nil
+	}
+	if err := router.SetTrustedProxies(trustedProxies); err != nil {
+		return nil, err
+	}
+
+	// Enable CORS
+	router.Use(cors.New(cors.Config{
+		AllowOrigins:     []string{"*"},
+		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "token"},
+		ExposeHeaders:    []string{"Content-Length"},
+		AllowCredentials: true,
+	}))
+
+	// Public routes
+	publicRoutes := router.Group("/")
+	{
+		publicRoutes.POST("/signup", userController.SignUp())
+		publicRoutes.POST("/login", userController.Login())
+		publicRoutes.POST("/refresh", userController.RefreshToken()) // Refresh token doesn't need auth middleware
+		publicRoutes.POST("/mfa/verify", mfaController.VerifyLogin())
+		publicRoutes.POST("/password/forgot", userController.ForgotPassword())
+		publicRoutes.POST("/password/reset", userController.ResetPassword())
+		publicRoutes.POST("/verify-email", userController.VerifyEmail())
+		publicRoutes.POST("/verify-email/resend", userController.ResendVerificationEmail())
+		publicRoutes.POST("/verify-email/change", userController.ConfirmEmailChange())
+		publicRoutes.POST("/restore-account", userController.RestoreAccount())
+		if deps.Config.Auth.PublishJWKS {
+			publicRoutes.GET("/.well-known/jwks.json", controller.NewJWKSController(deps.Tokens).GetJWKS())
+		}
+	}
+
+	// Private routes
+	privateRoutes := router.Group("/")
+	privateRoutes.Use(middleware.Authentication(deps.Tokens, store))
+	{
+		AccountRoutes(privateRoutes, userController, mfaController)
+	}
+
+	// Where MFA is enforced, therapists only get to the routes above until
+	// they log in with a second factor
+	protectedRoutes := privateRoutes.Group("/")
+	if deps.Config.Auth.RequireTherapistMFA {
+		protectedRoutes.Use(middleware.RequireTherapistMFA())
+	}
+	{
+		UserRoutes(protectedRoutes, userController, store)
+		ExerciseRoutes(protectedRoutes, exerciseController)
+		PatientExerciseRoutes(protectedRoutes, patientExerciseController, store)
+		ScheduleRoutes(protectedRoutes, scheduleController, store)
+		FeedbackRoutes(protectedRoutes, feedbackController, store)
+		CareTeamRoutes(protectedRoutes, careTeamController, store)
+		InviteRoutes(protectedRoutes, inviteController)
+		CaseloadRoutes(protectedRoutes, caseloadController)
+	}
+
+	return router, nil
+}
@@ -0,0 +1,297 @@
+package routes_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"golang-speakbackend/config"
+	"golang-speakbackend/controllers"
+	"golang-speakbackend/helpers"
+	"golang-speakbackend/models"
+	"golang-speakbackend/repositories"
+	"golang-speakbackend/routes"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// bucket stands in for the S3 bucket. The storage client addresses the
+// bucket by host name, so requests reach it through HTTP_PROXY.
+var bucket = &fakeBucket{objects: map[string]bool{}}
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	server := httptest.NewServer(bucket)
+	os.Setenv("HTTP_PROXY", server.URL)
+	code := m.Run()
+	server.Close()
+	os.Exit(code)
+}
+
+// fakeBucket implements the S3 calls the service makes: PUT, listing and
+// batch deletes. HEAD and GET of a stored object succeed.
+type fakeBucket struct {
+	mu      sync.Mutex
+	objects map[string]bool
+}
+
+func (b *fakeBucket) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	key := strings.TrimPrefix(r.URL.Path, "/")
+	switch {
+	case r.Method == http.MethodPut:
+		b.objects[key] = true
+	case r.Method == http.MethodGet && r.URL.Query().Get("list-type") == "2":
+		var body strings.Builder
+		body.WriteString(`<?xml version="1.0" encoding="UTF-8"?><ListBucketResult><IsTruncated>false</IsTruncated>`)
+		for stored := range b.objects {
+			if strings.HasPrefix(stored, r.URL.Query().Get("prefix")) {
+				fmt.Fprintf(&body, "<Contents><Key>%s</Key></Contents>", stored)
+			}
+		}
+		body.WriteString("</ListBucketResult>")
+		w.Write([]byte(body.String()))
+	case r.Method == http.MethodPost && r.URL.Query().Has("delete"):
+		var request struct {
+			Objects []struct{ Key string } `xml:"Object"`
+		}
+		data, _ := io.ReadAll(r.Body)
+		xml.Unmarshal(data, &request)
+		for _, object := range request.Objects {
+			delete(b.objects, object.Key)
+		}
+		w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?><DeleteResult></DeleteResult>`))
+	case b.objects[key]:
+		w.Header().Set("Content-Type", "video/mp4")
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (b *fakeBucket) has(prefix string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for key := range b.objects {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// mailbox keeps the emails the service sends.
+type mailbox struct {
+	mu       sync.Mutex
+	messages []helpers.MailMessage
+}
+
+func (m *mailbox) Send(ctx context.Context, message helpers.MailMessage) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, message)
+	return nil
+}
+
+// token returns the token in the link of the last email sent to the address.
+func (m *mailbox) token(t *testing.T, to string) string {
+	t.Helper()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if m.messages[i].To != to {
+			continue
+		}
+		_, link, ok := strings.Cut(m.messages[i].Body, "token=")
+		if ok {
+			return strings.Fields(link)[0]
+		}
+	}
+	t.Fatalf("no email with a token sent to %s", to)
+	return ""
+}
+
+type testServer struct {
+	t       *testing.T
+	router  *gin.Engine
+	store   *repositories.Store
+	storage *helpers.Storage
+	mail    *mailbox
+	config  config.Config
+}
+
+// testUser is a logged in user.
+type testUser struct {
+	ID            string
+	Email         string
+	Token         string
+	RefreshToken  string
+	ReferenceCode string
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	cfg := config.Default()
+	cfg.Mongo.URI = "mongodb://unused"
+	cfg.Auth.SecretKey = "test-access-secret"
+	cfg.Auth.RefreshSecretKey = "test-refresh-secret"
+	cfg.KMS.Provider = "local"
+	cfg.KMS.MasterKey = "unused"
+	cfg.Storage.Endpoint = "http://s3.test"
+	cfg.Storage.AccessKey = "test"
+	cfg.Storage.SecretKey = "test"
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	tokens, err := helpers.NewTokenMaker(cfg.Auth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	storage, err := helpers.NewStorage(cfg.Storage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := helpers.NewLocalKeyWrapper(bytes.Repeat([]byte{7}, 32), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := &testServer{t: t, store: repositories.NewMemoryStore(), storage: storage, mail: &mailbox{}, config: cfg}
+	server.router, err = routes.NewRouter(routes.Dependencies{
+		Config:  cfg,
+		Store:   server.store,
+		Storage: storage,
+		Keys:    keys,
+		Tokens:  tokens,
+		Mailer:  server.mail,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return server
+}
+
+// do sends a JSON request and decodes the JSON response into out, if given.
+func (s *testServer) do(method string, path string, token string, body any, out any) int {
+	s.t.Helper()
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			s.t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	request := httptest.NewRequest(method, path, reader)
+	request.Header.Set("Content-Type", "application/json")
+	return s.serve(request, token, out)
+}
+
+func (s *testServer) serve(request *http.Request, token string, out any) int {
+	s.t.Helper()
+	if token != "" {
+		request.Header.Set("token", token)
+	}
+	recorder := httptest.NewRecorder()
+	s.router.ServeHTTP(recorder, request)
+	if out != nil {
+		if err := json.Unmarshal(recorder.Body.Bytes(), out); err != nil {
+			s.t.Fatalf("%s %s: decoding %q: %v", request.Method, request.URL, recorder.Body.String(), err)
+		}
+	}
+	return recorder.Code
+}
+
+// expect fails the test unless the request gets the given status.
+func (s *testServer) expect(status int, method string, path string, token string, body any) map[string]any {
+	s.t.Helper()
+	var response map[string]any
+	if code := s.do(method, path, token, body, &response); code != status {
+		s.t.Fatalf("%s %s: got %d %v, want %d", method, path, code, response, status)
+	}
+	return response
+}
+
+// signUp registers and verifies a user, then logs them in.
+func (s *testServer) signUp(role string, email string) *testUser {
+	s.t.Helper()
+	s.expect(http.StatusOK, "POST", "/signup", "", gin.H{
+		"first_name": "Test", "last_name": "User", "email": email, "password": "secret1", "role": role,
+	})
+	s.expect(http.StatusOK, "POST", "/verify-email", "", gin.H{"token": s.mail.token(s.t, email)})
+	return s.login(email, "secret1")
+}
+
+func (s *testServer) login(email string, password string) *testUser {
+	s.t.Helper()
+	var user struct {
+		UserID        string `json:"user_id"`
+		Token         string `json:"token"`
+		RefreshToken  string `json:"refresh_token"`
+		ReferenceCode string `json:"reference_code"`
+	}
+	if code := s.do("POST", "/login", "", gin.H{"email": email, "password": password}, &user); code != http.StatusOK {
+		s.t.Fatalf("login %s: got %d", email, code)
+	}
+	return &testUser{ID: user.UserID, Email: email, Token: user.Token, RefreshToken: user.RefreshToken, ReferenceCode: user.ReferenceCode}
+}
+
+// admin creates an admin directly, since they can't sign up.
+func (s *testServer) admin() *testUser {
+	s.t.Helper()
+	id := primitive.NewObjectID()
+	firstName, lastName, email := "Test", "Admin", "admin@example.com"
+	password := controllers.HashPassword("secret1")
+	err := s.store.Users.Insert(context.Background(), &models.User{
+		ID: id, UserID: id.Hex(), FirstName: &firstName, LastName: &lastName, Email: &email,
+		Password: &password, Role: helpers.RoleAdmin, AccountStatus: models.AccountActive,
+	})
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return s.login(email, "secret1")
+}
+
+// invite links the patient to the therapist as their primary therapist.
+func (s *testServer) invite(therapist *testUser, patient *testUser) {
+	s.t.Helper()
+	invite := s.expect(http.StatusOK, "POST", "/invites", therapist.Token, gin.H{})
+	s.expect(http.StatusOK, "POST", "/invites/redeem", patient.Token, gin.H{"code": invite["code"]})
+}
+
+// assign creates an exercise as the therapist and assigns it to the
+// patient, returning the patient exercise ID.
+func (s *testServer) assign(therapist *testUser, patient *testUser) string {
+	s.t.Helper()
+	exercise := s.expect(http.StatusOK, "POST", "/exercise", therapist.Token, gin.H{"name": "Lip trills", "description": "Trill for a minute"})
+	assigned := s.expect(http.StatusOK, "POST", "/patientexercise", therapist.Token, gin.H{
+		"patient_id": patient.ID, "therapist_id": therapist.ID, "exercise_ids": []any{exercise["InsertedID"]},
+	})
+	return assigned["inserted_ids"].([]any)[0].(string)
+}
+
+// upload submits a recording for the patient exercise.
+func (s *testServer) upload(patient *testUser, patientExerciseID string, filename string) int {
+	s.t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	file, _ := form.CreateFormFile("file", filename)
+	file.Write([]byte("recording"))
+	form.Close()
+	request := httptest.NewRequest("POST", "/patientexercise/uploadrecording/"+patientExerciseID, &body)
+	request.Header.Set("Content-Type", form.FormDataContentType())
+	return s.serve(request, patient.Token, nil)
+}
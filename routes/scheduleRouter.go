@@ -0,0 +1,19 @@
+package routes
+
+import(
+	controller "golang-speakbackend/controllers"
+	"golang-speakbackend/helpers"
+	"golang-speakbackend/middleware"
+	"golang-speakbackend/repositories"
+
+	"github.com/gin-gonic/gin"
+)
+
+func ScheduleRoutes(incomingRoutes *gin.RouterGroup, sc *controller.ScheduleController, store *repositories.Store){
+	therapists := middleware.RequireRoles(helpers.RoleTherapist, helpers.RoleAdmin)
+
+	incomingRoutes.POST("/schedule", therapists, sc.CreateSchedule())
+	incomingRoutes.GET("/schedule/:schedule_id", sc.GetSchedule())
+	incomingRoutes.DELETE("/schedule/:schedule_id", therapists, sc.EndSchedule())
+	incomingRoutes.GET("/schedules/:patient_id", middleware.RequirePatientAccess(store, "patient_id"), sc.GetSchedulesByPatient())
+}
@@ -0,0 +1,42 @@
+package routes_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"golang-speakbackend/helpers"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestScheduleLimits(t *testing.T) {
+	s := newTestServer(t)
+	therapist := s.signUp(helpers.RoleTherapist, "therapist@example.com")
+	patient := s.signUp(helpers.RolePatient, "patient@example.com")
+	s.invite(therapist, patient)
+	exercise := s.expect(http.StatusOK, "POST", "/exercise", therapist.Token, gin.H{"name": "Lip trills", "description": "d"})
+	schedule := func(start time.Time, end time.Time) gin.H {
+		return gin.H{
+			"patient_id": patient.ID, "therapist_id": therapist.ID, "exercise_id": exercise["InsertedID"],
+			"start_date": start, "end_date": end, "frequency": helpers.FrequencyDaily, "repetitions_per_session": 1,
+		}
+	}
+	now := time.Now().UTC()
+	day := 24 * time.Hour
+
+	// Creating a schedule generates its past occurrences, so how far back it
+	// starts and how long it runs are capped
+	s.expect(http.StatusBadRequest, "POST", "/schedule", therapist.Token, schedule(now.AddDate(-5, 0, 0), now))
+	s.expect(http.StatusBadRequest, "POST", "/schedule", therapist.Token, schedule(now, now.Add(s.config.Schedule.MaxLength.Duration+day)))
+
+	s.expect(http.StatusOK, "POST", "/schedule", therapist.Token, schedule(now.Add(-2*day), now.Add(30*day)))
+	var patientExercises []map[string]any
+	if code := s.do("GET", "/patientexercises/"+patient.ID, patient.Token, nil, &patientExercises); code != http.StatusOK {
+		t.Fatalf("patient exercises: got %d", code)
+	}
+	// Two days back, today and a week ahead
+	if len(patientExercises) != 10 {
+		t.Errorf("generated %d occurrences, want 10", len(patientExercises))
+	}
+}
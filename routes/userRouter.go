@@ -2,19 +2,25 @@ package routes
 
 import(
 	controller "golang-speakbackend/controllers"
+	"golang-speakbackend/helpers"
+	"golang-speakbackend/middleware"
+	"golang-speakbackend/repositories"
 
 	"github.com/gin-gonic/gin"
 )
 
-func UserRoutes(incomingRoutes *gin.RouterGroup){
+func UserRoutes(incomingRoutes *gin.RouterGroup, uc *controller.UserController, store *repositories.Store){
 	// incomingRoutes.POST("/signup", controller.SignUp())
 	// incomingRoutes.POST("/login", controller.Login())
-	incomingRoutes.GET("/user/:user_id", controller.GetUser())
-	incomingRoutes.GET("/users", controller.GetUsers())
-	incomingRoutes.PUT("/user/:user_id", controller.UpdateUser())
-	incomingRoutes.DELETE("/user/:user_id", controller.DeleteUser())
-	incomingRoutes.POST("/user/linkToTherapist/:user_id", controller.LinkToTherapist())
-	incomingRoutes.GET("/patients/:therapist_id", controller.GetPatients())
-	incomingRoutes.POST("/user/uploadprofile/:user_id", controller.UploadProfile())
+	incomingRoutes.GET("/user/:user_id", middleware.RequirePatientAccess(store, "user_id"), uc.GetUser())
+	incomingRoutes.GET("/users", middleware.RequireRoles(helpers.RoleAdmin), uc.GetUsers())
+	incomingRoutes.PUT("/user/:user_id", middleware.RequireSelf("user_id"), uc.UpdateUser())
+	incomingRoutes.DELETE("/user/:user_id", middleware.RequireSelf("user_id"), uc.DeleteUser())
+	incomingRoutes.POST("/user/:user_id/restore", middleware.RequireRoles(helpers.RoleAdmin), uc.RestoreUser())
+	incomingRoutes.GET("/deletioncertificates", middleware.RequireRoles(helpers.RoleAdmin), uc.GetDeletionCertificates())
+	incomingRoutes.GET("/deletioncertificates/:user_id", middleware.RequireRoles(helpers.RoleAdmin), uc.GetDeletionCertificate())
+	incomingRoutes.POST("/user/linkToTherapist/:user_id", middleware.RequireRoles(helpers.RolePatient), middleware.RequireSelf("user_id"), uc.LinkToTherapist())
+	incomingRoutes.GET("/patients/:therapist_id", middleware.RequireRoles(helpers.RoleTherapist, helpers.RoleAdmin), middleware.RequireSelf("therapist_id"), uc.GetPatients())
+	incomingRoutes.POST("/user/uploadprofile/:user_id", middleware.RequireSelf("user_id"), uc.UploadProfile())
 	// incomingRoutes.POST("/user/refreshtoken", controller.RefreshToken())
-}
\ No newline at end of file
+}
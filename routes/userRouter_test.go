@@ -0,0 +1,185 @@
+package routes_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"golang-speakbackend/helpers"
+	"golang-speakbackend/models"
+	"golang-speakbackend/repositories"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestRoleAuthorization(t *testing.T) {
+	s := newTestServer(t)
+	therapist := s.signUp(helpers.RoleTherapist, "therapist@example.com")
+	patient := s.signUp(helpers.RolePatient, "patient@example.com")
+	other := s.signUp(helpers.RolePatient, "other@example.com")
+	s.invite(therapist, patient)
+
+	s.expect(http.StatusUnauthorized, "GET", "/exercises", "", nil)
+	s.expect(http.StatusForbidden, "POST", "/exercise", patient.Token, gin.H{"name": "Lip trills", "description": "d"})
+	s.expect(http.StatusForbidden, "GET", "/users", therapist.Token, nil)
+	s.expect(http.StatusForbidden, "GET", "/user/"+patient.ID, other.Token, nil)
+	s.expect(http.StatusForbidden, "PUT", "/user/"+patient.ID, other.Token, gin.H{"first_name": "Mallory"})
+
+	// Other users only get the profile
+	profile := s.expect(http.StatusOK, "GET", "/user/"+patient.ID, therapist.Token, nil)
+	for _, secret := range []string{"password", "token", "refresh_token", "mfa"} {
+		if _, ok := profile[secret]; ok {
+			t.Errorf("profile includes %s", secret)
+		}
+	}
+	s.expect(http.StatusForbidden, "GET", "/user/"+other.ID, therapist.Token, nil)
+
+	// Patients can't assign to themselves
+	exercise := s.expect(http.StatusOK, "POST", "/exercise", therapist.Token, gin.H{"name": "Lip trills", "description": "d"})
+	s.expect(http.StatusForbidden, "POST", "/patientexercise", patient.Token, gin.H{
+		"patient_id": patient.ID, "therapist_id": therapist.ID, "exercise_ids": []any{exercise["InsertedID"]},
+	})
+	// nor can a therapist outside the care team
+	outsider := s.signUp(helpers.RoleTherapist, "outsider@example.com")
+	s.expect(http.StatusForbidden, "POST", "/patientexercise", outsider.Token, gin.H{
+		"patient_id": patient.ID, "therapist_id": outsider.ID, "exercise_ids": []any{exercise["InsertedID"]},
+	})
+}
+
+func TestPatientExerciseWithoutPatient(t *testing.T) {
+	s := newTestServer(t)
+	therapist := s.signUp(helpers.RoleTherapist, "therapist@example.com")
+	admin := s.admin()
+	id := primitive.NewObjectID()
+	err := s.store.PatientExercises.InsertMany(context.Background(), []models.PatientExercise{{
+		ID: id, PatientExerciseID: id.Hex(), TherapistID: &therapist.ID, Status: models.StatusAssigned,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.expect(http.StatusForbidden, "GET", "/patientexercise/"+id.Hex(), therapist.Token, nil)
+	s.expect(http.StatusForbidden, "DELETE", "/patientexercise/"+id.Hex(), therapist.Token, nil)
+	s.expect(http.StatusOK, "GET", "/patientexercise/"+id.Hex(), admin.Token, nil)
+}
+
+func TestSoftDeleteAndRestore(t *testing.T) {
+	s := newTestServer(t)
+	patient := s.signUp(helpers.RolePatient, "patient@example.com")
+	other := s.signUp(helpers.RolePatient, "other@example.com")
+
+	s.expect(http.StatusForbidden, "DELETE", "/user/"+patient.ID, other.Token, nil)
+	deleted := s.expect(http.StatusOK, "DELETE", "/user/"+patient.ID, patient.Token, nil)
+	if deleted["purge_after"] == nil {
+		t.Fatal("no purge_after in the response")
+	}
+
+	// Deleted accounts are logged out and can't log back in
+	s.expect(http.StatusUnauthorized, "GET", "/user/"+patient.ID, patient.Token, nil)
+	s.expect(http.StatusUnauthorized, "POST", "/refresh", "", gin.H{"refresh_token": patient.RefreshToken})
+	s.expect(http.StatusForbidden, "POST", "/login", "", gin.H{"email": patient.Email, "password": "secret1"})
+
+	s.expect(http.StatusOK, "POST", "/restore-account", "", gin.H{"token": s.mail.token(t, patient.Email)})
+	patient = s.login(patient.Email, "secret1")
+	s.expect(http.StatusOK, "GET", "/user/"+patient.ID, patient.Token, nil)
+
+	// Admins can restore too
+	admin := s.admin()
+	s.expect(http.StatusOK, "DELETE", "/user/"+patient.ID, patient.Token, nil)
+	s.expect(http.StatusConflict, "DELETE", "/user/"+patient.ID, admin.Token, nil)
+	s.expect(http.StatusForbidden, "POST", "/user/"+patient.ID+"/restore", other.Token, nil)
+	s.expect(http.StatusOK, "POST", "/user/"+patient.ID+"/restore", admin.Token, nil)
+	s.login(patient.Email, "secret1")
+}
+
+func TestPurgePatient(t *testing.T) {
+	s := newTestServer(t)
+	therapist := s.signUp(helpers.RoleTherapist, "therapist@example.com")
+	patient := s.signUp(helpers.RolePatient, "patient@example.com")
+	admin := s.admin()
+	s.invite(therapist, patient)
+	patientExerciseID := s.assign(therapist, patient)
+	s.expect(http.StatusOK, "PUT", "/patientexercise/"+patientExerciseID, patient.Token, gin.H{"status": "in_progress"})
+	if code := s.upload(patient, patientExerciseID, "take.mp4"); code != http.StatusOK {
+		t.Fatalf("upload: got %d", code)
+	}
+	s.expect(http.StatusOK, "DELETE", "/user/"+patient.ID, patient.Token, nil)
+
+	// Nothing goes before the grace period is over
+	ctx := context.Background()
+	purged, err := helpers.PurgeDeletedAccounts(ctx, s.store, s.storage, time.Now())
+	if err != nil || purged != 0 {
+		t.Fatalf("purged %d accounts (%v) during the grace period", purged, err)
+	}
+
+	purged, err = helpers.PurgeDeletedAccounts(ctx, s.store, s.storage, time.Now().Add(s.config.Account.DeletionGrace.Duration+time.Hour))
+	if err != nil || purged != 1 {
+		t.Fatalf("purged %d accounts (%v), want 1", purged, err)
+	}
+	if _, err := s.store.Users.FindByID(ctx, patient.ID); err != repositories.ErrNotFound {
+		t.Errorf("user still stored: %v", err)
+	}
+	if _, err := s.store.PatientExercises.FindByID(ctx, patientExerciseID); err != repositories.ErrNotFound {
+		t.Errorf("patient exercise still stored: %v", err)
+	}
+	if bucket.has("recordings/" + patientExerciseID) {
+		t.Error("recording still stored")
+	}
+
+	certificate := s.expect(http.StatusOK, "GET", "/deletioncertificates/"+patient.ID, admin.Token, nil)
+	documents := certificate["documents"].(map[string]any)
+	if documents["patient_exercise"] != 1.0 || documents["recording_attempt"] != 1.0 || documents["user"] != 1.0 {
+		t.Errorf("certificate documents = %v", documents)
+	}
+	s.expect(http.StatusForbidden, "GET", "/deletioncertificates/"+patient.ID, therapist.Token, nil)
+}
+
+func TestTherapistDeletion(t *testing.T) {
+	s := newTestServer(t)
+	therapist := s.signUp(helpers.RoleTherapist, "therapist@example.com")
+	successor := s.signUp(helpers.RoleTherapist, "successor@example.com")
+	patient := s.signUp(helpers.RolePatient, "patient@example.com")
+	s.invite(therapist, patient)
+	patientExerciseID := s.assign(therapist, patient)
+	s.expect(http.StatusOK, "PUT", "/patientexercise/"+patientExerciseID, patient.Token, gin.H{"status": "in_progress"})
+	if code := s.upload(patient, patientExerciseID, "take.mp4"); code != http.StatusOK {
+		t.Fatalf("upload: got %d", code)
+	}
+	s.expect(http.StatusOK, "POST", "/invites", therapist.Token, gin.H{})
+
+	// Patients can't be left without their therapist
+	refused := s.expect(http.StatusConflict, "DELETE", "/user/"+therapist.ID, therapist.Token, nil)
+	if refused["active_patients"] != 1.0 {
+		t.Errorf("active_patients = %v, want 1", refused["active_patients"])
+	}
+	s.expect(http.StatusOK, "DELETE", "/user/"+therapist.ID+"?transfer_to="+successor.ID, therapist.Token, nil)
+
+	ctx := context.Background()
+	purged, err := helpers.PurgeDeletedAccounts(ctx, s.store, s.storage, time.Now().Add(s.config.Account.DeletionGrace.Duration+time.Hour))
+	if err != nil || purged != 1 {
+		t.Fatalf("purged %d accounts (%v), want 1", purged, err)
+	}
+
+	// The patient's records stay with the patient
+	patientExercise, err := s.store.PatientExercises.FindByID(ctx, patientExerciseID)
+	if err != nil {
+		t.Fatalf("patient exercise was purged with the therapist: %v", err)
+	}
+	if *patientExercise.TherapistID != successor.ID {
+		t.Errorf("patient exercise therapist = %s, want %s", *patientExercise.TherapistID, successor.ID)
+	}
+	if !bucket.has("recordings/" + patientExerciseID) {
+		t.Error("the patient's recording was purged with the therapist")
+	}
+	attempts, err := s.store.Attempts.ListByPatientExercise(ctx, patientExerciseID)
+	if err != nil || len(attempts) != 1 {
+		t.Errorf("patient exercise has %d attempts (%v), want 1", len(attempts), err)
+	}
+	invites, err := s.store.Invites.ListByTherapist(ctx, therapist.ID)
+	if err != nil || len(invites) != 0 {
+		t.Errorf("therapist still has %d invites (%v)", len(invites), err)
+	}
+	s.expect(http.StatusOK, "GET", "/patientexercise/"+patientExerciseID, successor.Token, nil)
+}